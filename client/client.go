@@ -8,6 +8,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"sync"
 	"time"
 )
 
@@ -20,8 +21,14 @@ const (
 type Client struct {
 	baseURL    string
 	httpClient *http.Client
-	token      string
+	tokens     TokenSource
 	userAgent  string
+	retry      *RetryPolicy
+	limiter    RateLimiter
+	apiErrors  bool
+
+	mu             sync.RWMutex
+	onUnauthorized func(ctx context.Context) error
 }
 
 type Option func(*Client)
@@ -50,12 +57,39 @@ func WithUserAgent(userAgent string) Option {
 	}
 }
 
+func WithTokenSource(tokens TokenSource) Option {
+	return func(c *Client) {
+		c.tokens = tokens
+	}
+}
+
+func WithRetryPolicy(policy *RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = policy
+	}
+}
+
+func WithRateLimiter(limiter RateLimiter) Option {
+	return func(c *Client) {
+		c.limiter = limiter
+	}
+}
+
+// WithAPIErrors makes services return an *services.APIError alongside the
+// response whenever the API reports success=false.
+func WithAPIErrors(enabled bool) Option {
+	return func(c *Client) {
+		c.apiErrors = enabled
+	}
+}
+
 func NewClient(opts ...Option) *Client {
 	c := &Client{
 		baseURL: DefaultBaseURL,
 		httpClient: &http.Client{
 			Timeout: DefaultTimeout,
 		},
+		tokens:    NewMemoryTokenSource(""),
 		userAgent: "projectx-go-client/1.0.0",
 	}
 
@@ -67,11 +101,37 @@ func NewClient(opts ...Option) *Client {
 }
 
 func (c *Client) SetToken(token string) {
-	c.token = token
+	if setter, ok := c.tokens.(TokenSetter); ok {
+		setter.SetToken(token)
+	}
 }
 
 func (c *Client) GetToken() string {
-	return c.token
+	token, err := c.tokens.Token(context.Background())
+	if err != nil {
+		return ""
+	}
+	return token
+}
+
+func (c *Client) Token(ctx context.Context) (string, error) {
+	return c.tokens.Token(ctx)
+}
+
+func (c *Client) TokenSource() TokenSource {
+	return c.tokens
+}
+
+// SetUnauthorizedHandler registers a callback that is invoked when a request
+// is rejected with 401. If the callback succeeds the request is retried once.
+func (c *Client) SetUnauthorizedHandler(handler func(ctx context.Context) error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onUnauthorized = handler
+}
+
+func (c *Client) APIErrorsEnabled() bool {
+	return c.apiErrors
 }
 
 type Request struct {
@@ -80,6 +140,10 @@ type Request struct {
 	Headers map[string]string
 	Query   url.Values
 	Body    interface{}
+	// Idempotent marks the request as safe to retry regardless of its path.
+	Idempotent bool
+	// SkipAuthRefresh disables the unauthorized handler for this request.
+	SkipAuthRefresh bool
 }
 
 type Response struct {
@@ -98,16 +162,70 @@ func (c *Client) Do(ctx context.Context, req *Request) (*Response, error) {
 		u.RawQuery = req.Query.Encode()
 	}
 
-	var body io.Reader
+	var jsonBody []byte
 	if req.Body != nil {
-		jsonBody, err := json.Marshal(req.Body)
+		jsonBody, err = json.Marshal(req.Body)
 		if err != nil {
 			return nil, fmt.Errorf("failed to marshal request body: %w", err)
 		}
+	}
+
+	resp, err := c.doWithRetry(ctx, req, u.String(), jsonBody)
+	if resp == nil || resp.StatusCode != http.StatusUnauthorized || req.SkipAuthRefresh {
+		return resp, err
+	}
+
+	c.mu.RLock()
+	onUnauthorized := c.onUnauthorized
+	c.mu.RUnlock()
+
+	if onUnauthorized == nil {
+		return resp, err
+	}
+	if refreshErr := onUnauthorized(ctx); refreshErr != nil {
+		return resp, err
+	}
+
+	return c.doWithRetry(ctx, req, u.String(), jsonBody)
+}
+
+func (c *Client) doWithRetry(ctx context.Context, req *Request, rawURL string, jsonBody []byte) (*Response, error) {
+	attempts := c.retry.attempts(req)
+	for attempt := 1; ; attempt++ {
+		if c.limiter != nil {
+			if err := c.limiter.Wait(ctx, req); err != nil {
+				return nil, fmt.Errorf("rate limiter: %w", err)
+			}
+		}
+
+		resp, err := c.do(ctx, req, rawURL, jsonBody)
+		if attempt >= attempts {
+			return resp, err
+		}
+
+		var httpResp *http.Response
+		transportErr := err
+		if resp != nil {
+			httpResp = resp.Response
+			transportErr = nil
+		}
+		if !c.retry.shouldRetry(ctx, httpResp, transportErr) {
+			return resp, err
+		}
+
+		if err := sleepContext(ctx, c.retry.backoff(attempt, httpResp)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (c *Client) do(ctx context.Context, req *Request, rawURL string, jsonBody []byte) (*Response, error) {
+	var body io.Reader
+	if jsonBody != nil {
 		body = bytes.NewReader(jsonBody)
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, req.Method, u.String(), body)
+	httpReq, err := http.NewRequestWithContext(ctx, req.Method, rawURL, body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -118,8 +236,12 @@ func (c *Client) Do(ctx context.Context, req *Request) (*Response, error) {
 	httpReq.Header.Set("Accept", "application/json")
 	httpReq.Header.Set("User-Agent", c.userAgent)
 
-	if c.token != "" {
-		httpReq.Header.Set("Authorization", "Bearer "+c.token)
+	token, err := c.tokens.Token(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token: %w", err)
+	}
+	if token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+token)
 	}
 
 	for k, v := range req.Headers {
@@ -143,23 +265,28 @@ func (c *Client) Do(ctx context.Context, req *Request) (*Response, error) {
 	}
 
 	if httpResp.StatusCode >= 400 {
-		return resp, fmt.Errorf("HTTP %d: %s", httpResp.StatusCode, string(respBody))
+		return resp, newHTTPError(req, httpResp, respBody)
 	}
 
 	return resp, nil
 }
 
 func (c *Client) DoJSON(ctx context.Context, req *Request, v interface{}) error {
+	_, err := c.DoJSONResponse(ctx, req, v)
+	return err
+}
+
+func (c *Client) DoJSONResponse(ctx context.Context, req *Request, v interface{}) (*Response, error) {
 	resp, err := c.Do(ctx, req)
 	if err != nil {
-		return err
+		return resp, err
 	}
 
 	if v != nil && len(resp.Body) > 0 {
 		if err := json.Unmarshal(resp.Body, v); err != nil {
-			return fmt.Errorf("failed to unmarshal response: %w", err)
+			return resp, fmt.Errorf("failed to unmarshal response: %w", err)
 		}
 	}
 
-	return nil
+	return resp, nil
 }
@@ -0,0 +1,30 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+)
+
+type HTTPError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Status     string
+	Header     http.Header
+	Body       []byte
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("HTTP %d: %s", e.StatusCode, string(e.Body))
+}
+
+func newHTTPError(req *Request, resp *http.Response, body []byte) *HTTPError {
+	return &HTTPError{
+		Method:     req.Method,
+		Path:       req.Path,
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     resp.Header,
+		Body:       body,
+	}
+}
@@ -0,0 +1,207 @@
+package client
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	EndpointClassDefault = "default"
+	EndpointClassHistory = "history"
+	EndpointClassOrder   = "order"
+)
+
+type RateLimiter interface {
+	Wait(ctx context.Context, req *Request) error
+}
+
+type TokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func NewTokenBucket(limit int, per time.Duration, burst int) *TokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &TokenBucket{
+		rate:   float64(limit) / per.Seconds(),
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait blocks until a token is available and returns how long it waited.
+func (b *TokenBucket) Wait(ctx context.Context) (time.Duration, error) {
+	delay := b.reserve()
+	if delay <= 0 {
+		return 0, nil
+	}
+
+	if err := sleepContext(ctx, delay); err != nil {
+		b.cancel()
+		return 0, err
+	}
+
+	return delay, nil
+}
+
+func (b *TokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	b.tokens--
+	if b.tokens >= 0 || b.rate <= 0 {
+		return 0
+	}
+
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+func (b *TokenBucket) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens++
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
+
+type RateLimitStats struct {
+	Requests  int64
+	Delayed   int64
+	TotalWait time.Duration
+	MaxWait   time.Duration
+}
+
+func (s RateLimitStats) AverageWait() time.Duration {
+	if s.Requests == 0 {
+		return 0
+	}
+	return s.TotalWait / time.Duration(s.Requests)
+}
+
+type EndpointRateLimiter struct {
+	mu       sync.Mutex
+	classify func(path string) string
+	buckets  map[string]*TokenBucket
+	stats    map[string]*RateLimitStats
+}
+
+func NewEndpointRateLimiter(classify func(path string) string, buckets map[string]*TokenBucket) *EndpointRateLimiter {
+	if classify == nil {
+		classify = ClassifyEndpoint
+	}
+
+	l := &EndpointRateLimiter{
+		classify: classify,
+		buckets:  make(map[string]*TokenBucket),
+		stats:    make(map[string]*RateLimitStats),
+	}
+	for class, bucket := range buckets {
+		l.buckets[class] = bucket
+	}
+	return l
+}
+
+// DefaultRateLimiter splits the documented API limits (50 requests per 30
+// seconds for history, 200 requests per minute for everything else) into
+// history, order and default budgets.
+func DefaultRateLimiter() *EndpointRateLimiter {
+	return NewEndpointRateLimiter(ClassifyEndpoint, map[string]*TokenBucket{
+		EndpointClassHistory: NewTokenBucket(50, 30*time.Second, 10),
+		EndpointClassOrder:   NewTokenBucket(100, time.Minute, 20),
+		EndpointClassDefault: NewTokenBucket(100, time.Minute, 20),
+	})
+}
+
+func ClassifyEndpoint(path string) string {
+	switch {
+	case strings.HasPrefix(path, "/api/History/"):
+		return EndpointClassHistory
+	case strings.EqualFold(path, "/api/Order/place"),
+		strings.EqualFold(path, "/api/Order/modify"),
+		strings.EqualFold(path, "/api/Order/cancel"),
+		strings.HasPrefix(path, "/api/Position/close"),
+		strings.HasPrefix(path, "/api/Position/partialClose"):
+		return EndpointClassOrder
+	default:
+		return EndpointClassDefault
+	}
+}
+
+func (l *EndpointRateLimiter) SetBucket(class string, bucket *TokenBucket) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.buckets[class] = bucket
+}
+
+func (l *EndpointRateLimiter) Wait(ctx context.Context, req *Request) error {
+	class := l.classify(req.Path)
+
+	l.mu.Lock()
+	bucket, ok := l.buckets[class]
+	if !ok {
+		bucket = l.buckets[EndpointClassDefault]
+	}
+	l.mu.Unlock()
+
+	var waited time.Duration
+	if bucket != nil {
+		var err error
+		waited, err = bucket.Wait(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	stats, ok := l.stats[class]
+	if !ok {
+		stats = &RateLimitStats{}
+		l.stats[class] = stats
+	}
+	stats.Requests++
+	if waited > 0 {
+		stats.Delayed++
+		stats.TotalWait += waited
+		if waited > stats.MaxWait {
+			stats.MaxWait = waited
+		}
+	}
+
+	return nil
+}
+
+func (l *EndpointRateLimiter) Stats() map[string]RateLimitStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	result := make(map[string]RateLimitStats, len(l.stats))
+	for class, stats := range l.stats {
+		result[class] = *stats
+	}
+	return result
+}
+
+func (l *EndpointRateLimiter) ResetStats() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.stats = make(map[string]*RateLimitStats)
+}
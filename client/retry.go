@@ -0,0 +1,178 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultMaxAttempts    = 3
+	DefaultInitialBackoff = 500 * time.Millisecond
+	DefaultMaxBackoff     = 10 * time.Second
+)
+
+var DefaultIdempotentPaths = []string{
+	"/api/Account/search",
+	"/api/Auth/validate",
+	"/api/Contract/search",
+	"/api/Contract/searchById",
+	"/api/History/retrieveBars",
+	"/api/Order/search",
+	"/api/Order/searchOpen",
+	"/api/Position/searchOpen",
+	"/api/Status/ping",
+	"/api/Trade/search",
+}
+
+var DefaultRetryableStatuses = []int{
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	// Jitter is the fraction of each backoff that is randomized, between 0 and 1.
+	Jitter            float64
+	RetryableStatuses []int
+	IdempotentPaths   []string
+	// RetryAll retries every endpoint, including non-idempotent ones such as
+	// order placement. Leave disabled unless duplicate orders are acceptable.
+	RetryAll          bool
+	RespectRetryAfter bool
+}
+
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:       DefaultMaxAttempts,
+		InitialBackoff:    DefaultInitialBackoff,
+		MaxBackoff:        DefaultMaxBackoff,
+		Multiplier:        2,
+		Jitter:            0.2,
+		RetryableStatuses: append([]int(nil), DefaultRetryableStatuses...),
+		IdempotentPaths:   append([]string(nil), DefaultIdempotentPaths...),
+		RespectRetryAfter: true,
+	}
+}
+
+func (p *RetryPolicy) attempts(req *Request) int {
+	if p == nil || p.MaxAttempts < 1 {
+		return 1
+	}
+	if !p.RetryAll && !req.Idempotent && !p.isIdempotentPath(req.Path) {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+func (p *RetryPolicy) isIdempotentPath(path string) bool {
+	for _, idempotent := range p.IdempotentPaths {
+		if strings.EqualFold(idempotent, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *RetryPolicy) isRetryableStatus(status int) bool {
+	for _, retryable := range p.RetryableStatuses {
+		if retryable == status {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *RetryPolicy) shouldRetry(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	return p.isRetryableStatus(resp.StatusCode)
+}
+
+func (p *RetryPolicy) backoff(attempt int, resp *http.Response) time.Duration {
+	if p.RespectRetryAfter && resp != nil {
+		if delay, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+				return p.MaxBackoff
+			}
+			return delay
+		}
+	}
+
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	delay := float64(p.InitialBackoff)
+	for i := 1; i < attempt; i++ {
+		delay *= multiplier
+		if p.MaxBackoff > 0 && delay > float64(p.MaxBackoff) {
+			delay = float64(p.MaxBackoff)
+			break
+		}
+	}
+
+	if p.Jitter > 0 {
+		jitter := p.Jitter
+		if jitter > 1 {
+			jitter = 1
+		}
+		delay = delay*(1-jitter) + delay*jitter*rand.Float64()
+	}
+
+	return time.Duration(delay)
+}
+
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if at, err := http.ParseTime(value); err == nil {
+		delay := time.Until(at)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+
+	return 0, false
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
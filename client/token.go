@@ -0,0 +1,43 @@
+package client
+
+import (
+	"context"
+	"sync"
+)
+
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// TokenSetter is implemented by token sources that accept tokens issued by
+// the auth endpoints. Read-only sources simply ignore login results.
+type TokenSetter interface {
+	SetToken(token string)
+}
+
+type TokenSourceFunc func(ctx context.Context) (string, error)
+
+func (f TokenSourceFunc) Token(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+type MemoryTokenSource struct {
+	mu    sync.RWMutex
+	token string
+}
+
+func NewMemoryTokenSource(token string) *MemoryTokenSource {
+	return &MemoryTokenSource{token: token}
+}
+
+func (s *MemoryTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.token, nil
+}
+
+func (s *MemoryTokenSource) SetToken(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = token
+}
@@ -0,0 +1,229 @@
+package datafile
+
+import (
+	"time"
+
+	"github.com/tradingiq/projectx-client/models"
+)
+
+type kind int
+
+const (
+	kindTime kind = iota
+	kindFloat
+	kindInt
+	kindString
+	kindBool
+)
+
+type value struct {
+	null bool
+	t    time.Time
+	f    float64
+	i    int64
+	s    string
+	b    bool
+}
+
+type column[T any] struct {
+	name     string
+	kind     kind
+	optional bool
+	get      func(*T) value
+	set      func(*T, value)
+}
+
+func timeColumn[T any](name string, field func(*T) *time.Time) column[T] {
+	return column[T]{
+		name: name,
+		kind: kindTime,
+		get:  func(r *T) value { return value{t: *field(r)} },
+		set:  func(r *T, v value) { *field(r) = v.t },
+	}
+}
+
+func optionalTimeColumn[T any](name string, field func(*T) **time.Time) column[T] {
+	return column[T]{
+		name:     name,
+		kind:     kindTime,
+		optional: true,
+		get: func(r *T) value {
+			if p := *field(r); p != nil {
+				return value{t: *p}
+			}
+			return value{null: true}
+		},
+		set: func(r *T, v value) {
+			if v.null {
+				*field(r) = nil
+				return
+			}
+			t := v.t
+			*field(r) = &t
+		},
+	}
+}
+
+func floatColumn[T any](name string, field func(*T) *float64) column[T] {
+	return column[T]{
+		name: name,
+		kind: kindFloat,
+		get:  func(r *T) value { return value{f: *field(r)} },
+		set:  func(r *T, v value) { *field(r) = v.f },
+	}
+}
+
+func optionalFloatColumn[T any](name string, field func(*T) **float64) column[T] {
+	return column[T]{
+		name:     name,
+		kind:     kindFloat,
+		optional: true,
+		get: func(r *T) value {
+			if p := *field(r); p != nil {
+				return value{f: *p}
+			}
+			return value{null: true}
+		},
+		set: func(r *T, v value) {
+			if v.null {
+				*field(r) = nil
+				return
+			}
+			f := v.f
+			*field(r) = &f
+		},
+	}
+}
+
+func intColumn[T any, I ~int | ~int32 | ~int64](name string, field func(*T) *I) column[T] {
+	return column[T]{
+		name: name,
+		kind: kindInt,
+		get:  func(r *T) value { return value{i: int64(*field(r))} },
+		set:  func(r *T, v value) { *field(r) = I(v.i) },
+	}
+}
+
+func stringColumn[T any](name string, field func(*T) *string) column[T] {
+	return column[T]{
+		name: name,
+		kind: kindString,
+		get:  func(r *T) value { return value{s: *field(r)} },
+		set:  func(r *T, v value) { *field(r) = v.s },
+	}
+}
+
+func optionalStringColumn[T any](name string, field func(*T) **string) column[T] {
+	return column[T]{
+		name:     name,
+		kind:     kindString,
+		optional: true,
+		get: func(r *T) value {
+			if p := *field(r); p != nil {
+				return value{s: *p}
+			}
+			return value{null: true}
+		},
+		set: func(r *T, v value) {
+			if v.null {
+				*field(r) = nil
+				return
+			}
+			s := v.s
+			*field(r) = &s
+		},
+	}
+}
+
+func boolColumn[T any](name string, field func(*T) *bool) column[T] {
+	return column[T]{
+		name: name,
+		kind: kindBool,
+		get:  func(r *T) value { return value{b: *field(r)} },
+		set:  func(r *T, v value) { *field(r) = v.b },
+	}
+}
+
+// Column names follow the JSON field names of the models.
+
+var barColumns = []column[models.AggregateBarModel]{
+	timeColumn("t", func(r *models.AggregateBarModel) *time.Time { return &r.T }),
+	floatColumn("open", func(r *models.AggregateBarModel) *float64 { return &r.Open }),
+	floatColumn("high", func(r *models.AggregateBarModel) *float64 { return &r.High }),
+	floatColumn("low", func(r *models.AggregateBarModel) *float64 { return &r.Low }),
+	floatColumn("close", func(r *models.AggregateBarModel) *float64 { return &r.Close }),
+	intColumn("volume", func(r *models.AggregateBarModel) *int64 { return &r.Volume }),
+}
+
+var halfTradeColumns = []column[models.HalfTradeModel]{
+	intColumn("id", func(r *models.HalfTradeModel) *int32 { return &r.ID }),
+	intColumn("accountId", func(r *models.HalfTradeModel) *int32 { return &r.AccountID }),
+	stringColumn("contractId", func(r *models.HalfTradeModel) *string { return &r.ContractID }),
+	timeColumn("creationTimestamp", func(r *models.HalfTradeModel) *time.Time { return &r.CreationTimestamp }),
+	floatColumn("price", func(r *models.HalfTradeModel) *float64 { return &r.Price }),
+	optionalFloatColumn("profitAndLoss", func(r *models.HalfTradeModel) **float64 { return &r.ProfitAndLoss }),
+	floatColumn("fees", func(r *models.HalfTradeModel) *float64 { return &r.Fees }),
+	intColumn("side", func(r *models.HalfTradeModel) *models.OrderSide { return &r.Side }),
+	intColumn("size", func(r *models.HalfTradeModel) *int32 { return &r.Size }),
+	boolColumn("voided", func(r *models.HalfTradeModel) *bool { return &r.Voided }),
+	intColumn("orderId", func(r *models.HalfTradeModel) *int32 { return &r.OrderID }),
+}
+
+var orderColumns = []column[models.OrderModel]{
+	intColumn("id", func(r *models.OrderModel) *int32 { return &r.ID }),
+	intColumn("accountId", func(r *models.OrderModel) *int32 { return &r.AccountID }),
+	stringColumn("contractId", func(r *models.OrderModel) *string { return &r.ContractID }),
+	timeColumn("creationTimestamp", func(r *models.OrderModel) *time.Time { return &r.CreationTimestamp }),
+	optionalTimeColumn("updateTimestamp", func(r *models.OrderModel) **time.Time { return &r.UpdateTimestamp }),
+	intColumn("status", func(r *models.OrderModel) *models.OrderStatus { return &r.Status }),
+	intColumn("type", func(r *models.OrderModel) *models.OrderType { return &r.Type }),
+	intColumn("side", func(r *models.OrderModel) *models.OrderSide { return &r.Side }),
+	intColumn("size", func(r *models.OrderModel) *int32 { return &r.Size }),
+	optionalFloatColumn("limitPrice", func(r *models.OrderModel) **float64 { return &r.LimitPrice }),
+	optionalFloatColumn("stopPrice", func(r *models.OrderModel) **float64 { return &r.StopPrice }),
+	intColumn("fillVolume", func(r *models.OrderModel) *int32 { return &r.FillVolume }),
+	optionalFloatColumn("filledPrice", func(r *models.OrderModel) **float64 { return &r.FilledPrice }),
+	optionalStringColumn("customTag", func(r *models.OrderModel) **string { return &r.CustomTag }),
+}
+
+var quoteColumns = []column[models.Quote]{
+	stringColumn("symbol", func(r *models.Quote) *string { return &r.Symbol }),
+	stringColumn("symbolName", func(r *models.Quote) *string { return &r.SymbolName }),
+	floatColumn("lastPrice", func(r *models.Quote) *float64 { return &r.LastPrice }),
+	floatColumn("bestBid", func(r *models.Quote) *float64 { return &r.BestBid }),
+	floatColumn("bestAsk", func(r *models.Quote) *float64 { return &r.BestAsk }),
+	floatColumn("change", func(r *models.Quote) *float64 { return &r.Change }),
+	floatColumn("changePercent", func(r *models.Quote) *float64 { return &r.ChangePercent }),
+	floatColumn("open", func(r *models.Quote) *float64 { return &r.Open }),
+	floatColumn("high", func(r *models.Quote) *float64 { return &r.High }),
+	floatColumn("low", func(r *models.Quote) *float64 { return &r.Low }),
+	intColumn("volume", func(r *models.Quote) *int { return &r.Volume }),
+	timeColumn("lastUpdated", func(r *models.Quote) *time.Time { return &r.LastUpdated }),
+	timeColumn("timestamp", func(r *models.Quote) *time.Time { return &r.Timestamp }),
+}
+
+var tradeColumns = []column[models.Trade]{
+	stringColumn("symbolId", func(r *models.Trade) *string { return &r.SymbolID }),
+	floatColumn("price", func(r *models.Trade) *float64 { return &r.Price }),
+	intColumn("volume", func(r *models.Trade) *int { return &r.Volume }),
+	intColumn("type", func(r *models.Trade) *int { return &r.Type }),
+	timeColumn("timestamp", func(r *models.Trade) *time.Time { return &r.Timestamp }),
+}
+
+func columnsFor[T Record]() []column[T] {
+	var columns interface{}
+	var zero T
+	switch any(zero).(type) {
+	case models.AggregateBarModel:
+		columns = barColumns
+	case models.HalfTradeModel:
+		columns = halfTradeColumns
+	case models.OrderModel:
+		columns = orderColumns
+	case models.Quote:
+		columns = quoteColumns
+	case models.Trade:
+		columns = tradeColumns
+	}
+	return columns.([]column[T])
+}
@@ -0,0 +1,105 @@
+package datafile
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+)
+
+type csvWriter struct {
+	w      *csv.Writer
+	specs  []columnSpec
+	cfg    config
+	record []string
+}
+
+func newCSVWriter(w io.Writer, specs []columnSpec, cfg config) (*csvWriter, error) {
+	cw := &csvWriter{
+		w:      csv.NewWriter(w),
+		specs:  specs,
+		cfg:    cfg,
+		record: make([]string, len(specs)),
+	}
+
+	for i, spec := range specs {
+		cw.record[i] = spec.name
+	}
+	if err := cw.w.Write(cw.record); err != nil {
+		return nil, fmt.Errorf("failed to write csv header: %w", err)
+	}
+	return cw, nil
+}
+
+func (w *csvWriter) writeRow(row []value) error {
+	for i, spec := range w.specs {
+		w.record[i] = formatText(row[i], spec.kind, w.cfg)
+	}
+	return w.w.Write(w.record)
+}
+
+func (w *csvWriter) flush() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+func (w *csvWriter) close() error {
+	return w.flush()
+}
+
+type csvReader struct {
+	r     *csv.Reader
+	specs []columnSpec
+	cfg   config
+	// index maps each column to its position in the file, or -1.
+	index []int
+}
+
+func newCSVReader(r io.Reader, specs []columnSpec, cfg config) (*csvReader, error) {
+	cr := &csvReader{
+		r:     csv.NewReader(r),
+		specs: specs,
+		cfg:   cfg,
+		index: make([]int, len(specs)),
+	}
+	cr.r.ReuseRecord = true
+
+	header, err := cr.r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+	positions := make(map[string]int, len(header))
+	for i, name := range header {
+		positions[name] = i
+	}
+	for i, spec := range specs {
+		pos, ok := positions[spec.name]
+		if !ok {
+			pos = -1
+		}
+		cr.index[i] = pos
+	}
+	cr.r.FieldsPerRecord = len(header)
+	return cr, nil
+}
+
+func (r *csvReader) readRow() ([]value, error) {
+	record, err := r.r.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	row := make([]value, len(r.specs))
+	for i, spec := range r.specs {
+		if r.index[i] < 0 {
+			row[i] = value{null: spec.optional}
+			continue
+		}
+		v, err := parseText(record[r.index[i]], spec, r.cfg)
+		if err != nil {
+			line, _ := r.r.FieldPos(r.index[i])
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		row[i] = v
+	}
+	return row, nil
+}
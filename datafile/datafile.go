@@ -0,0 +1,365 @@
+// Package datafile exports and imports bars, fills, orders, quotes and trades
+// as CSV, JSON Lines or Parquet. Writers stream rows to the underlying writer
+// so large ranges do not need to fit in memory.
+//
+// Parquet support is self-contained and limited to what the writer produces:
+// a flat schema, plain encoding and no compression. Files written by other
+// tools are only readable when they use the same subset.
+package datafile
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"strconv"
+	"time"
+
+	"github.com/tradingiq/projectx-client/models"
+)
+
+type Format int
+
+const (
+	CSV Format = iota
+	JSONL
+	Parquet
+)
+
+func (f Format) String() string {
+	switch f {
+	case CSV:
+		return "csv"
+	case JSONL:
+		return "jsonl"
+	case Parquet:
+		return "parquet"
+	default:
+		return fmt.Sprintf("Format(%d)", int(f))
+	}
+}
+
+// Record lists the models that can be exported.
+type Record interface {
+	models.AggregateBarModel | models.HalfTradeModel | models.OrderModel | models.Quote | models.Trade
+}
+
+// Timestamp layouts besides Go time layouts. They write integers counting
+// from the Unix epoch.
+const (
+	LayoutUnix      = "unix"
+	LayoutUnixMilli = "unixmilli"
+	LayoutUnixMicro = "unixmicro"
+	LayoutUnixNano  = "unixnano"
+)
+
+const DefaultRowGroupSize = 64 * 1024
+
+var ErrUnsupported = errors.New("unsupported")
+
+type config struct {
+	layout       string
+	location     *time.Location
+	rowGroupSize int
+}
+
+type Option func(*config)
+
+// WithTimeLayout sets how CSV and JSON Lines timestamps are written and
+// parsed: a Go time layout or one of the Layout constants. Defaults to
+// time.RFC3339Nano. Parquet always stores UTC microseconds.
+func WithTimeLayout(layout string) Option {
+	return func(c *config) {
+		c.layout = layout
+	}
+}
+
+// WithLocation sets the time zone timestamps are written in and read into,
+// and the zone assumed for layouts without an offset. Defaults to UTC.
+func WithLocation(loc *time.Location) Option {
+	return func(c *config) {
+		c.location = loc
+	}
+}
+
+// WithRowGroupSize sets how many rows a Parquet writer buffers per row group.
+func WithRowGroupSize(n int) Option {
+	return func(c *config) {
+		c.rowGroupSize = n
+	}
+}
+
+func newConfig(opts []Option) config {
+	cfg := config{
+		layout:       time.RFC3339Nano,
+		location:     time.UTC,
+		rowGroupSize: DefaultRowGroupSize,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.location == nil {
+		cfg.location = time.UTC
+	}
+	if cfg.rowGroupSize < 1 {
+		cfg.rowGroupSize = 1
+	}
+	return cfg
+}
+
+type rowWriter interface {
+	writeRow(row []value) error
+	flush() error
+	close() error
+}
+
+type rowReader interface {
+	// readRow returns values in column order and io.EOF after the last row.
+	readRow() ([]value, error)
+}
+
+type Writer[T Record] struct {
+	columns []column[T]
+	rows    rowWriter
+	row     []value
+}
+
+// NewWriter writes records of type T to w. Close must be called to finish the
+// file; it does not close w.
+func NewWriter[T Record](w io.Writer, format Format, opts ...Option) (*Writer[T], error) {
+	cfg := newConfig(opts)
+	columns := columnsFor[T]()
+	specs := columnSpecs(columns)
+
+	var rows rowWriter
+	var err error
+	switch format {
+	case CSV:
+		rows, err = newCSVWriter(w, specs, cfg)
+	case JSONL:
+		rows = newJSONLWriter(w, specs, cfg)
+	case Parquet:
+		rows, err = newParquetWriter(w, specs, cfg)
+	default:
+		return nil, fmt.Errorf("%w format %v", ErrUnsupported, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Writer[T]{
+		columns: columns,
+		rows:    rows,
+		row:     make([]value, len(columns)),
+	}, nil
+}
+
+func (w *Writer[T]) Write(record T) error {
+	for i, col := range w.columns {
+		w.row[i] = col.get(&record)
+	}
+	return w.rows.writeRow(w.row)
+}
+
+// WriteAll writes every record yielded by records.
+func (w *Writer[T]) WriteAll(records iter.Seq[T]) error {
+	for record := range records {
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush writes buffered rows. For Parquet it ends the current row group.
+func (w *Writer[T]) Flush() error {
+	return w.rows.flush()
+}
+
+func (w *Writer[T]) Close() error {
+	return w.rows.close()
+}
+
+type Reader[T Record] struct {
+	columns []column[T]
+	rows    rowReader
+}
+
+// NewReader reads records of type T from r. Columns are matched by name, so
+// their order may differ and unknown columns are ignored. Parquet needs r to
+// implement io.ReadSeeker.
+func NewReader[T Record](r io.Reader, format Format, opts ...Option) (*Reader[T], error) {
+	cfg := newConfig(opts)
+	columns := columnsFor[T]()
+	specs := columnSpecs(columns)
+
+	var rows rowReader
+	var err error
+	switch format {
+	case CSV:
+		rows, err = newCSVReader(r, specs, cfg)
+	case JSONL:
+		rows = newJSONLReader(r, specs, cfg)
+	case Parquet:
+		rs, ok := r.(io.ReadSeeker)
+		if !ok {
+			return nil, fmt.Errorf("%w: parquet needs an io.ReadSeeker", ErrUnsupported)
+		}
+		rows, err = newParquetReader(rs, specs, cfg)
+	default:
+		return nil, fmt.Errorf("%w format %v", ErrUnsupported, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Reader[T]{columns: columns, rows: rows}, nil
+}
+
+// Read returns the next record, or io.EOF after the last one.
+func (r *Reader[T]) Read() (T, error) {
+	var record T
+	row, err := r.rows.readRow()
+	if err != nil {
+		return record, err
+	}
+	for i, col := range r.columns {
+		col.set(&record, row[i])
+	}
+	return record, nil
+}
+
+// All yields every remaining record and stops after the first error.
+func (r *Reader[T]) All() iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for {
+			record, err := r.Read()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if !yield(record, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// ReadAll collects the remaining records into a slice.
+func (r *Reader[T]) ReadAll() ([]T, error) {
+	var records []T
+	for record, err := range r.All() {
+		if err != nil {
+			return records, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+type columnSpec struct {
+	name     string
+	kind     kind
+	optional bool
+}
+
+func columnSpecs[T any](columns []column[T]) []columnSpec {
+	specs := make([]columnSpec, len(columns))
+	for i, col := range columns {
+		specs[i] = columnSpec{name: col.name, kind: col.kind, optional: col.optional}
+	}
+	return specs
+}
+
+// formatText renders v for CSV and JSON Lines. Null values render empty.
+func formatText(v value, k kind, cfg config) string {
+	if v.null {
+		return ""
+	}
+	switch k {
+	case kindTime:
+		return formatTime(v.t, cfg)
+	case kindFloat:
+		return strconv.FormatFloat(v.f, 'f', -1, 64)
+	case kindInt:
+		return strconv.FormatInt(v.i, 10)
+	case kindBool:
+		return strconv.FormatBool(v.b)
+	default:
+		return v.s
+	}
+}
+
+func parseText(text string, spec columnSpec, cfg config) (value, error) {
+	if text == "" && (spec.optional || spec.kind != kindString) {
+		return value{null: spec.optional}, nil
+	}
+
+	var v value
+	var err error
+	switch spec.kind {
+	case kindTime:
+		v.t, err = parseTime(text, cfg)
+	case kindFloat:
+		v.f, err = strconv.ParseFloat(text, 64)
+	case kindInt:
+		v.i, err = strconv.ParseInt(text, 10, 64)
+	case kindBool:
+		v.b, err = strconv.ParseBool(text)
+	default:
+		v.s = text
+	}
+	if err != nil {
+		return value{}, fmt.Errorf("column %s: %w", spec.name, err)
+	}
+	return v, nil
+}
+
+func unixLayout(layout string) bool {
+	switch layout {
+	case LayoutUnix, LayoutUnixMilli, LayoutUnixMicro, LayoutUnixNano:
+		return true
+	}
+	return false
+}
+
+func formatTime(t time.Time, cfg config) string {
+	switch cfg.layout {
+	case LayoutUnix:
+		return strconv.FormatInt(t.Unix(), 10)
+	case LayoutUnixMilli:
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	case LayoutUnixMicro:
+		return strconv.FormatInt(t.UnixMicro(), 10)
+	case LayoutUnixNano:
+		return strconv.FormatInt(t.UnixNano(), 10)
+	default:
+		return t.In(cfg.location).Format(cfg.layout)
+	}
+}
+
+func parseTime(text string, cfg config) (time.Time, error) {
+	if !unixLayout(cfg.layout) {
+		t, err := time.ParseInLocation(cfg.layout, text, cfg.location)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return t.In(cfg.location), nil
+	}
+
+	n, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var t time.Time
+	switch cfg.layout {
+	case LayoutUnix:
+		t = time.Unix(n, 0)
+	case LayoutUnixMilli:
+		t = time.UnixMilli(n)
+	case LayoutUnixMicro:
+		t = time.UnixMicro(n)
+	default:
+		t = time.Unix(0, n)
+	}
+	return t.In(cfg.location), nil
+}
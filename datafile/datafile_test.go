@@ -0,0 +1,146 @@
+package datafile
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tradingiq/projectx-client/models"
+)
+
+var formats = []Format{CSV, JSONL, Parquet}
+
+func float(v float64) *float64 { return &v }
+
+func text(v string) *string { return &v }
+
+func timestamp(v time.Time) *time.Time { return &v }
+
+// testFills covers a null and a present optional column, both boolean values
+// and enough rows for several row groups of size 2.
+func testFills() []models.HalfTradeModel {
+	at := time.Date(2025, 3, 14, 14, 30, 0, 123456000, time.UTC)
+	return []models.HalfTradeModel{
+		{ID: 1, AccountID: 7, ContractID: "CON.F.US.MES.Z25", CreationTimestamp: at, Price: 5012.25, Fees: 0.37, Side: models.OrderSideBid, Size: 1, OrderID: 101},
+		{ID: 2, AccountID: 7, ContractID: "CON.F.US.MES.Z25", CreationTimestamp: at.Add(time.Second), Price: 5013.5, ProfitAndLoss: float(6.25), Fees: 0.37, Side: models.OrderSideAsk, Size: 1, OrderID: 102},
+		{ID: 3, AccountID: 7, ContractID: "CON.F.US.MNQ.Z25", CreationTimestamp: at.Add(time.Minute), Price: 18250, Fees: 0.74, Side: models.OrderSideBid, Size: 2, Voided: true, OrderID: 103},
+		{ID: 4, AccountID: 7, ContractID: "CON.F.US.MNQ.Z25", CreationTimestamp: at.Add(2 * time.Minute), Price: 18244.75, ProfitAndLoss: float(-21), Fees: 0.74, Side: models.OrderSideAsk, Size: 2, OrderID: 104},
+		{ID: 5, AccountID: 8, ContractID: "CON.F.US.MES.Z25", CreationTimestamp: at.Add(time.Hour), Price: 5020, ProfitAndLoss: float(0), Fees: 0.37, Side: models.OrderSideAsk, Size: 1, Voided: true, OrderID: 105},
+	}
+}
+
+func testOrders() []models.OrderModel {
+	at := time.Date(2025, 3, 14, 14, 30, 0, 0, time.UTC)
+	return []models.OrderModel{
+		{ID: 101, AccountID: 7, ContractID: "CON.F.US.MES.Z25", CreationTimestamp: at, Status: models.OrderStatusOpen, Type: models.OrderTypeLimit, Side: models.OrderSideBid, Size: 1, LimitPrice: float(5000.25)},
+		{ID: 102, AccountID: 7, ContractID: "CON.F.US.MES.Z25", CreationTimestamp: at, UpdateTimestamp: timestamp(at.Add(time.Second)), Status: models.OrderStatusFilled, Type: models.OrderTypeMarket, Side: models.OrderSideAsk, Size: 1, FillVolume: 1, FilledPrice: float(5001), CustomTag: text("exit, \"tp\"")},
+		{ID: 103, AccountID: 7, ContractID: "CON.F.US.MNQ.Z25", CreationTimestamp: at.Add(time.Minute), Status: models.OrderStatusCancelled, Type: models.OrderTypeStop, Side: models.OrderSideAsk, Size: 2, StopPrice: float(18200)},
+	}
+}
+
+func roundTrip[T Record](t *testing.T, format Format, records []T, opts ...Option) []T {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w, err := NewWriter[T](&buf, format, opts...)
+	if err != nil {
+		t.Fatalf("%v: NewWriter: %v", format, err)
+	}
+	for _, record := range records {
+		if err := w.Write(record); err != nil {
+			t.Fatalf("%v: Write: %v", format, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("%v: Close: %v", format, err)
+	}
+
+	r, err := NewReader[T](bytes.NewReader(buf.Bytes()), format, opts...)
+	if err != nil {
+		t.Fatalf("%v: NewReader: %v", format, err)
+	}
+	got, err := r.ReadAll()
+	if err != nil {
+		t.Fatalf("%v: ReadAll: %v", format, err)
+	}
+	return got
+}
+
+func TestRoundTrip(t *testing.T) {
+	at := time.Date(2025, 3, 14, 14, 30, 0, 0, time.UTC)
+	bars := []models.AggregateBarModel{
+		{T: at, Open: 5000, High: 5004.5, Low: 4999.75, Close: 5003, Volume: 1200},
+		{T: at.Add(time.Minute), Open: 5003, High: 5003.25, Low: 4998, Close: 4998.5, Volume: 845},
+	}
+	quotes := []models.Quote{
+		{Symbol: "F.US.MES", SymbolName: "/MES", LastPrice: 5003, BestBid: 5002.75, BestAsk: 5003, Change: 12.5, ChangePercent: 0.25, Open: 4990.5, High: 5010, Low: 4985, Volume: 153000, LastUpdated: at, Timestamp: at.Add(-time.Hour)},
+	}
+	trades := []models.Trade{
+		{SymbolID: "F.US.MES", Price: 5003, Volume: 3, Type: 1, Timestamp: at},
+		{SymbolID: "F.US.MES", Price: 5002.75, Volume: 1, Type: 0, Timestamp: at.Add(time.Millisecond)},
+	}
+
+	for _, format := range formats {
+		for _, size := range []int{1, 2, DefaultRowGroupSize} {
+			opts := []Option{WithRowGroupSize(size)}
+			checkRecords(t, format, bars, roundTrip(t, format, bars, opts...))
+			checkRecords(t, format, testFills(), roundTrip(t, format, testFills(), opts...))
+			checkRecords(t, format, testOrders(), roundTrip(t, format, testOrders(), opts...))
+			checkRecords(t, format, quotes, roundTrip(t, format, quotes, opts...))
+			checkRecords(t, format, trades, roundTrip(t, format, trades, opts...))
+		}
+	}
+}
+
+func TestRoundTripEmpty(t *testing.T) {
+	for _, format := range formats {
+		if got := roundTrip[models.OrderModel](t, format, nil); len(got) != 0 {
+			t.Errorf("%v: read %d records from an empty file", format, len(got))
+		}
+	}
+}
+
+func TestRoundTripTimeLayout(t *testing.T) {
+	loc := time.FixedZone("EST", -5*60*60)
+	for _, layout := range []string{time.RFC3339, LayoutUnix, LayoutUnixMilli, LayoutUnixMicro, LayoutUnixNano} {
+		for _, format := range []Format{CSV, JSONL} {
+			orders := testOrders()
+			got := roundTrip(t, format, orders, WithTimeLayout(layout), WithLocation(loc))
+			for i := range orders {
+				orders[i].CreationTimestamp = orders[i].CreationTimestamp.In(loc)
+				if orders[i].UpdateTimestamp != nil {
+					orders[i].UpdateTimestamp = timestamp(orders[i].UpdateTimestamp.In(loc))
+				}
+			}
+			checkRecords(t, format, orders, got)
+		}
+	}
+}
+
+func TestRoundTripEmptyString(t *testing.T) {
+	orders := testOrders()[:1]
+	orders[0].CustomTag = text("")
+	for _, format := range formats {
+		got := roundTrip(t, format, orders)
+		if len(got) != 1 {
+			t.Fatalf("%v: read %d records, want 1", format, len(got))
+		}
+		// CSV has no null, so an empty optional string reads back as nil.
+		if tag := got[0].CustomTag; format == CSV && tag != nil || format != CSV && (tag == nil || *tag != "") {
+			t.Errorf("%v: custom tag %v", format, tag)
+		}
+	}
+}
+
+func checkRecords[T Record](t *testing.T, format Format, want, got []T) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%v: read %d records, want %d", format, len(got), len(want))
+	}
+	for i := range want {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("%v: record %d\n got %+v\nwant %+v", format, i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package datafile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+type jsonlWriter struct {
+	w     *bufio.Writer
+	specs []columnSpec
+	cfg   config
+	buf   []byte
+}
+
+func newJSONLWriter(w io.Writer, specs []columnSpec, cfg config) *jsonlWriter {
+	return &jsonlWriter{w: bufio.NewWriter(w), specs: specs, cfg: cfg}
+}
+
+func (w *jsonlWriter) writeRow(row []value) error {
+	buf := append(w.buf[:0], '{')
+	for i, spec := range w.specs {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = strconv.AppendQuote(buf, spec.name)
+		buf = append(buf, ':')
+
+		v := row[i]
+		switch {
+		case v.null:
+			buf = append(buf, "null"...)
+		case spec.kind == kindString:
+			encoded, err := json.Marshal(v.s)
+			if err != nil {
+				return err
+			}
+			buf = append(buf, encoded...)
+		case spec.kind == kindTime && !unixLayout(w.cfg.layout):
+			buf = strconv.AppendQuote(buf, formatTime(v.t, w.cfg))
+		case spec.kind == kindFloat && (math.IsNaN(v.f) || math.IsInf(v.f, 0)):
+			return fmt.Errorf("column %s: %v is not valid JSON", spec.name, v.f)
+		default:
+			buf = append(buf, formatText(v, spec.kind, w.cfg)...)
+		}
+	}
+	buf = append(buf, '}', '\n')
+	w.buf = buf
+
+	_, err := w.w.Write(buf)
+	return err
+}
+
+func (w *jsonlWriter) flush() error {
+	return w.w.Flush()
+}
+
+func (w *jsonlWriter) close() error {
+	return w.flush()
+}
+
+type jsonlReader struct {
+	dec   *json.Decoder
+	specs []columnSpec
+	cfg   config
+	line  int
+}
+
+func newJSONLReader(r io.Reader, specs []columnSpec, cfg config) *jsonlReader {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	return &jsonlReader{dec: dec, specs: specs, cfg: cfg}
+}
+
+func (r *jsonlReader) readRow() ([]value, error) {
+	var object map[string]json.RawMessage
+	if err := r.dec.Decode(&object); err != nil {
+		return nil, err
+	}
+	r.line++
+
+	row := make([]value, len(r.specs))
+	for i, spec := range r.specs {
+		raw, ok := object[spec.name]
+		if !ok || bytes.Equal(raw, []byte("null")) {
+			row[i] = value{null: spec.optional}
+			continue
+		}
+
+		text := string(raw)
+		if len(raw) > 0 && raw[0] == '"' {
+			if err := json.Unmarshal(raw, &text); err != nil {
+				return nil, fmt.Errorf("record %d: column %s: %w", r.line, spec.name, err)
+			}
+			// Unlike CSV, JSON keeps an empty string apart from null.
+			if spec.kind == kindString {
+				row[i] = value{s: text}
+				continue
+			}
+		}
+		v, err := parseText(text, spec, r.cfg)
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", r.line, err)
+		}
+		row[i] = v
+	}
+	return row, nil
+}
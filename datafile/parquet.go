@@ -0,0 +1,665 @@
+package datafile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+const parquetMagic = "PAR1"
+
+// Parquet enums from the format's thrift definition.
+const (
+	parquetBoolean   = 0
+	parquetInt32     = 1
+	parquetInt64     = 2
+	parquetFloat     = 4
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetRequired = 0
+	parquetOptional = 1
+
+	parquetUTF8            = 0
+	parquetTimestampMillis = 9
+	parquetTimestampMicros = 10
+
+	// LogicalType union members and TimeUnit MICROS.
+	parquetLogicalString    = 1
+	parquetLogicalTimestamp = 8
+	parquetUnitMicros       = 2
+
+	parquetPlain = 0
+	parquetRLE   = 3
+
+	parquetUncompressed = 0
+	parquetDataPage     = 0
+)
+
+var ErrParquetCorrupt = errors.New("parquet file corrupt")
+
+func parquetType(k kind) (physical int32, converted int32) {
+	switch k {
+	case kindTime:
+		return parquetInt64, parquetTimestampMicros
+	case kindFloat:
+		return parquetDouble, -1
+	case kindInt:
+		return parquetInt64, -1
+	case kindString:
+		return parquetByteArray, parquetUTF8
+	default:
+		return parquetBoolean, -1
+	}
+}
+
+type parquetChunk struct {
+	offset int64
+	size   int64
+	values int64
+}
+
+type parquetRowGroup struct {
+	chunks []parquetChunk
+	rows   int64
+	size   int64
+}
+
+type parquetWriter struct {
+	w         io.Writer
+	offset    int64
+	specs     []columnSpec
+	cfg       config
+	columns   [][]value
+	rows      int
+	rowGroups []parquetRowGroup
+	totalRows int64
+}
+
+func newParquetWriter(w io.Writer, specs []columnSpec, cfg config) (*parquetWriter, error) {
+	pw := &parquetWriter{
+		w:       w,
+		specs:   specs,
+		cfg:     cfg,
+		columns: make([][]value, len(specs)),
+	}
+	if err := pw.write([]byte(parquetMagic)); err != nil {
+		return nil, fmt.Errorf("failed to write parquet header: %w", err)
+	}
+	return pw, nil
+}
+
+func (w *parquetWriter) write(b []byte) error {
+	n, err := w.w.Write(b)
+	w.offset += int64(n)
+	return err
+}
+
+func (w *parquetWriter) writeRow(row []value) error {
+	for i := range w.specs {
+		w.columns[i] = append(w.columns[i], row[i])
+	}
+	w.rows++
+	if w.rows >= w.cfg.rowGroupSize {
+		return w.flush()
+	}
+	return nil
+}
+
+// flush writes the buffered rows as one row group with a single data page
+// per column.
+func (w *parquetWriter) flush() error {
+	if w.rows == 0 {
+		return nil
+	}
+
+	group := parquetRowGroup{rows: int64(w.rows)}
+	for i, spec := range w.specs {
+		page, err := encodeParquetPage(w.columns[i], spec)
+		if err != nil {
+			return fmt.Errorf("column %s: %w", spec.name, err)
+		}
+
+		chunk := parquetChunk{offset: w.offset, size: int64(len(page)), values: int64(w.rows)}
+		if err := w.write(page); err != nil {
+			return fmt.Errorf("failed to write parquet page: %w", err)
+		}
+		group.chunks = append(group.chunks, chunk)
+		group.size += chunk.size
+		w.columns[i] = w.columns[i][:0]
+	}
+
+	w.rowGroups = append(w.rowGroups, group)
+	w.totalRows += group.rows
+	w.rows = 0
+	return nil
+}
+
+func (w *parquetWriter) close() error {
+	if err := w.flush(); err != nil {
+		return err
+	}
+
+	footer := w.footer()
+	var trailer [8]byte
+	binary.LittleEndian.PutUint32(trailer[:4], uint32(len(footer)))
+	copy(trailer[4:], parquetMagic)
+
+	if err := w.write(footer); err != nil {
+		return fmt.Errorf("failed to write parquet footer: %w", err)
+	}
+	if err := w.write(trailer[:]); err != nil {
+		return fmt.Errorf("failed to write parquet footer: %w", err)
+	}
+	return nil
+}
+
+func (w *parquetWriter) footer() []byte {
+	var t thriftWriter
+	t.beginStruct()
+	t.i32(1, 1)
+
+	t.list(2, compactStruct, len(w.specs)+1)
+	t.beginStruct()
+	t.binary(4, "schema")
+	t.i32(5, int32(len(w.specs)))
+	t.endStruct()
+	for _, spec := range w.specs {
+		physical, converted := parquetType(spec.kind)
+		repetition := int32(parquetRequired)
+		if spec.optional {
+			repetition = parquetOptional
+		}
+
+		t.beginStruct()
+		t.i32(1, physical)
+		t.i32(3, repetition)
+		t.binary(4, spec.name)
+		if converted >= 0 {
+			t.i32(6, converted)
+		}
+		writeLogicalType(&t, spec.kind)
+		t.endStruct()
+	}
+
+	t.i64(3, w.totalRows)
+
+	t.list(4, compactStruct, len(w.rowGroups))
+	for _, group := range w.rowGroups {
+		t.beginStruct()
+		t.list(1, compactStruct, len(group.chunks))
+		for i, chunk := range group.chunks {
+			physical, _ := parquetType(w.specs[i].kind)
+
+			t.beginStruct()
+			t.i64(2, chunk.offset)
+			t.structField(3)
+			t.i32(1, physical)
+			t.list(2, compactI32, 2)
+			t.i32Element(parquetPlain)
+			t.i32Element(parquetRLE)
+			t.list(3, compactBinary, 1)
+			t.binaryElement(w.specs[i].name)
+			t.i32(4, parquetUncompressed)
+			t.i64(5, chunk.values)
+			t.i64(6, chunk.size)
+			t.i64(7, chunk.size)
+			t.i64(9, chunk.offset)
+			t.endStruct()
+			t.endStruct()
+		}
+		t.i64(2, group.size)
+		t.i64(3, group.rows)
+		t.endStruct()
+	}
+
+	t.binary(6, "projectx-client datafile")
+	t.endStruct()
+	return t.buf.Bytes()
+}
+
+// writeLogicalType adds the logical type next to the legacy converted type.
+// TIMESTAMP_MICROS alone leaves open whether values are UTC, so readers
+// such as pyarrow would load them as naive timestamps.
+func writeLogicalType(t *thriftWriter, k kind) {
+	switch k {
+	case kindTime:
+		t.structField(10)
+		t.structField(parquetLogicalTimestamp)
+		t.bool(1, true)
+		t.structField(2)
+		t.structField(parquetUnitMicros)
+		t.endStruct()
+		t.endStruct()
+		t.endStruct()
+		t.endStruct()
+	case kindString:
+		t.structField(10)
+		t.structField(parquetLogicalString)
+		t.endStruct()
+		t.endStruct()
+	}
+}
+
+func encodeParquetPage(values []value, spec columnSpec) ([]byte, error) {
+	var data bytes.Buffer
+	if spec.optional {
+		levels := make([]bool, len(values))
+		for i, v := range values {
+			levels[i] = !v.null
+		}
+		encoded := encodeLevels(levels)
+		binary.Write(&data, binary.LittleEndian, uint32(len(encoded)))
+		data.Write(encoded)
+	}
+
+	var bits []bool
+	for _, v := range values {
+		if v.null {
+			continue
+		}
+		switch spec.kind {
+		case kindTime:
+			binary.Write(&data, binary.LittleEndian, v.t.UnixMicro())
+		case kindFloat:
+			binary.Write(&data, binary.LittleEndian, math.Float64bits(v.f))
+		case kindInt:
+			binary.Write(&data, binary.LittleEndian, v.i)
+		case kindString:
+			if len(v.s) > math.MaxInt32 {
+				return nil, fmt.Errorf("string of %d bytes is too long", len(v.s))
+			}
+			binary.Write(&data, binary.LittleEndian, uint32(len(v.s)))
+			data.WriteString(v.s)
+		case kindBool:
+			bits = append(bits, v.b)
+		}
+	}
+	if spec.kind == kindBool {
+		data.Write(packBits(bits))
+	}
+
+	var header thriftWriter
+	header.beginStruct()
+	header.i32(1, parquetDataPage)
+	header.i32(2, int32(data.Len()))
+	header.i32(3, int32(data.Len()))
+	header.structField(5)
+	header.i32(1, int32(len(values)))
+	header.i32(2, parquetPlain)
+	header.i32(3, parquetRLE)
+	header.i32(4, parquetRLE)
+	header.endStruct()
+	header.endStruct()
+
+	return append(header.buf.Bytes(), data.Bytes()...), nil
+}
+
+// encodeLevels writes definition levels of bit width one as RLE runs of the
+// RLE/bit-packing hybrid encoding.
+func encodeLevels(levels []bool) []byte {
+	var out []byte
+	for i := 0; i < len(levels); {
+		j := i
+		for j < len(levels) && levels[j] == levels[i] {
+			j++
+		}
+		out = binary.AppendUvarint(out, uint64(j-i)<<1)
+		if levels[i] {
+			out = append(out, 1)
+		} else {
+			out = append(out, 0)
+		}
+		i = j
+	}
+	return out
+}
+
+func decodeLevels(data []byte, n int) ([]bool, error) {
+	r := bytes.NewReader(data)
+	levels := make([]bool, 0, n)
+	for len(levels) < n {
+		header, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, fmt.Errorf("%w: definition levels: %v", ErrParquetCorrupt, err)
+		}
+
+		if header&1 == 0 {
+			b, err := r.ReadByte()
+			if err != nil {
+				return nil, fmt.Errorf("%w: definition levels: %v", ErrParquetCorrupt, err)
+			}
+			for count := header >> 1; count > 0 && len(levels) < n; count-- {
+				levels = append(levels, b != 0)
+			}
+			continue
+		}
+
+		// Bit-packed groups of eight levels, one byte per group.
+		for groups := header >> 1; groups > 0; groups-- {
+			b, err := r.ReadByte()
+			if err != nil {
+				return nil, fmt.Errorf("%w: definition levels: %v", ErrParquetCorrupt, err)
+			}
+			for bit := 0; bit < 8 && len(levels) < n; bit++ {
+				levels = append(levels, b&(1<<bit) != 0)
+			}
+		}
+	}
+	return levels, nil
+}
+
+func packBits(bits []bool) []byte {
+	out := make([]byte, (len(bits)+7)/8)
+	for i, b := range bits {
+		if b {
+			out[i/8] |= 1 << (i % 8)
+		}
+	}
+	return out
+}
+
+type parquetLeaf struct {
+	name       string
+	physical   int32
+	optional   bool
+	converted  int32
+	chunkIndex int
+}
+
+type parquetReader struct {
+	r      io.ReadSeeker
+	specs  []columnSpec
+	cfg    config
+	leaves []*parquetLeaf
+
+	rowGroups []thriftStruct
+	group     int
+	columns   [][]value
+	rows      int
+	row       int
+}
+
+func newParquetReader(r io.ReadSeeker, specs []columnSpec, cfg config) (*parquetReader, error) {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if size < 12 {
+		return nil, fmt.Errorf("%w: file too short", ErrParquetCorrupt)
+	}
+
+	var header [4]byte
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+
+	var trailer [8]byte
+	if _, err := r.Seek(size-8, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(r, trailer[:]); err != nil {
+		return nil, err
+	}
+	if string(header[:]) != parquetMagic || string(trailer[4:]) != parquetMagic {
+		return nil, fmt.Errorf("%w: missing magic", ErrParquetCorrupt)
+	}
+	footerSize := int64(binary.LittleEndian.Uint32(trailer[:4]))
+	if footerSize > size-12 {
+		return nil, fmt.Errorf("%w: footer of %d bytes", ErrParquetCorrupt, footerSize)
+	}
+
+	footer := make([]byte, footerSize)
+	if _, err := r.Seek(size-8-footerSize, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(r, footer); err != nil {
+		return nil, err
+	}
+	meta, err := newThriftReader(bytes.NewReader(footer)).readStruct()
+	if err != nil {
+		return nil, fmt.Errorf("%w: footer: %v", ErrParquetCorrupt, err)
+	}
+
+	schema := meta.list(2)
+	if len(schema) == 0 {
+		return nil, fmt.Errorf("%w: empty schema", ErrParquetCorrupt)
+	}
+	if root, _ := schema[0].(thriftStruct); int(root.int(5)) != len(schema)-1 {
+		return nil, fmt.Errorf("%w: nested schemas", ErrUnsupported)
+	}
+	byName := make(map[string]*parquetLeaf)
+	for i, element := range schema[1:] {
+		s, _ := element.(thriftStruct)
+		leaf := &parquetLeaf{
+			name:       s.string(4),
+			physical:   int32(s.int(1)),
+			optional:   s.int(3) == parquetOptional,
+			converted:  -1,
+			chunkIndex: i,
+		}
+		if _, ok := s[6]; ok {
+			leaf.converted = int32(s.int(6))
+		}
+		byName[leaf.name] = leaf
+	}
+
+	pr := &parquetReader{r: r, specs: specs, cfg: cfg, leaves: make([]*parquetLeaf, len(specs))}
+	for i, spec := range specs {
+		leaf, ok := byName[spec.name]
+		if !ok {
+			continue
+		}
+		if !parquetCompatible(spec.kind, leaf) {
+			return nil, fmt.Errorf("%w: column %s has parquet type %d", ErrUnsupported, spec.name, leaf.physical)
+		}
+		pr.leaves[i] = leaf
+	}
+	for _, group := range meta.list(4) {
+		s, _ := group.(thriftStruct)
+		pr.rowGroups = append(pr.rowGroups, s)
+	}
+	return pr, nil
+}
+
+func parquetCompatible(k kind, leaf *parquetLeaf) bool {
+	switch k {
+	case kindTime:
+		return leaf.physical == parquetInt64
+	case kindFloat:
+		return leaf.physical == parquetDouble || leaf.physical == parquetFloat
+	case kindInt:
+		return leaf.physical == parquetInt64 || leaf.physical == parquetInt32
+	case kindString:
+		return leaf.physical == parquetByteArray
+	default:
+		return leaf.physical == parquetBoolean
+	}
+}
+
+func (r *parquetReader) readRow() ([]value, error) {
+	for r.row >= r.rows {
+		if r.group >= len(r.rowGroups) {
+			return nil, io.EOF
+		}
+		if err := r.loadGroup(r.rowGroups[r.group]); err != nil {
+			return nil, err
+		}
+		r.group++
+	}
+
+	row := make([]value, len(r.specs))
+	for i, spec := range r.specs {
+		if r.columns[i] == nil {
+			row[i] = value{null: spec.optional}
+			continue
+		}
+		row[i] = r.columns[i][r.row]
+	}
+	r.row++
+	return row, nil
+}
+
+func (r *parquetReader) loadGroup(group thriftStruct) error {
+	rows := int(group.int(3))
+	chunks := group.list(1)
+
+	r.columns = make([][]value, len(r.specs))
+	for i, leaf := range r.leaves {
+		if leaf == nil {
+			continue
+		}
+		if leaf.chunkIndex >= len(chunks) {
+			return fmt.Errorf("%w: missing column chunk %s", ErrParquetCorrupt, leaf.name)
+		}
+		chunk, _ := chunks[leaf.chunkIndex].(thriftStruct)
+		values, err := r.readChunk(chunk.structure(3), leaf, rows)
+		if err != nil {
+			return fmt.Errorf("column %s: %w", leaf.name, err)
+		}
+		r.columns[i] = values
+	}
+
+	r.rows = rows
+	r.row = 0
+	return nil
+}
+
+func (r *parquetReader) readChunk(meta thriftStruct, leaf *parquetLeaf, rows int) ([]value, error) {
+	if codec := meta.int(4); codec != parquetUncompressed {
+		return nil, fmt.Errorf("%w: compression codec %d", ErrUnsupported, codec)
+	}
+	if _, ok := meta[11]; ok {
+		return nil, fmt.Errorf("%w: dictionary encoding", ErrUnsupported)
+	}
+
+	size := meta.int(7)
+	if size < 0 || size > 1<<31 {
+		return nil, fmt.Errorf("%w: chunk of %d bytes", ErrParquetCorrupt, size)
+	}
+	data := make([]byte, size)
+	if _, err := r.r.Seek(meta.int(9), io.SeekStart); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		return nil, err
+	}
+
+	pages := bytes.NewReader(data)
+	values := make([]value, 0, rows)
+	for len(values) < rows {
+		header, err := newThriftReader(pages).readStruct()
+		if err != nil {
+			return nil, fmt.Errorf("%w: page header: %v", ErrParquetCorrupt, err)
+		}
+		if pageType := header.int(1); pageType != parquetDataPage {
+			return nil, fmt.Errorf("%w: page type %d", ErrUnsupported, pageType)
+		}
+		page := make([]byte, header.int(3))
+		if _, err := io.ReadFull(pages, page); err != nil {
+			return nil, fmt.Errorf("%w: page data: %v", ErrParquetCorrupt, err)
+		}
+
+		dataHeader := header.structure(5)
+		if encoding := dataHeader.int(2); encoding != parquetPlain {
+			return nil, fmt.Errorf("%w: encoding %d", ErrUnsupported, encoding)
+		}
+		pageValues, err := r.decodePage(page, leaf, int(dataHeader.int(1)))
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, pageValues...)
+	}
+	return values[:rows], nil
+}
+
+func (r *parquetReader) decodePage(page []byte, leaf *parquetLeaf, n int) ([]value, error) {
+	defined := make([]bool, n)
+	for i := range defined {
+		defined[i] = true
+	}
+	if leaf.optional {
+		if len(page) < 4 {
+			return nil, fmt.Errorf("%w: short page", ErrParquetCorrupt)
+		}
+		size := int(binary.LittleEndian.Uint32(page))
+		if size > len(page)-4 {
+			return nil, fmt.Errorf("%w: definition levels of %d bytes", ErrParquetCorrupt, size)
+		}
+		levels, err := decodeLevels(page[4:4+size], n)
+		if err != nil {
+			return nil, err
+		}
+		defined = levels
+		page = page[4+size:]
+	}
+
+	data := bytes.NewReader(page)
+	values := make([]value, n)
+	bit := 0
+	for i := range values {
+		if !defined[i] {
+			values[i].null = true
+			continue
+		}
+
+		var err error
+		switch leaf.physical {
+		case parquetBoolean:
+			if bit/8 >= len(page) {
+				return nil, fmt.Errorf("%w: short boolean page", ErrParquetCorrupt)
+			}
+			values[i].b = page[bit/8]&(1<<(bit%8)) != 0
+			bit++
+		case parquetInt32:
+			var v int32
+			err = binary.Read(data, binary.LittleEndian, &v)
+			values[i].i = int64(v)
+		case parquetInt64:
+			var v int64
+			err = binary.Read(data, binary.LittleEndian, &v)
+			values[i].i = v
+			values[i].t = r.timestamp(v, leaf)
+		case parquetFloat:
+			var v float32
+			err = binary.Read(data, binary.LittleEndian, &v)
+			values[i].f = float64(v)
+		case parquetDouble:
+			var v float64
+			err = binary.Read(data, binary.LittleEndian, &v)
+			values[i].f = v
+		case parquetByteArray:
+			var size uint32
+			if err = binary.Read(data, binary.LittleEndian, &size); err == nil {
+				if int64(size) > int64(data.Len()) {
+					return nil, fmt.Errorf("%w: byte array of %d bytes", ErrParquetCorrupt, size)
+				}
+				b := make([]byte, size)
+				_, err = io.ReadFull(data, b)
+				values[i].s = string(b)
+			}
+		default:
+			return nil, fmt.Errorf("%w: parquet type %d", ErrUnsupported, leaf.physical)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrParquetCorrupt, err)
+		}
+	}
+	return values, nil
+}
+
+// timestamp reads INT64 as milliseconds when annotated so, and as
+// microseconds otherwise.
+func (r *parquetReader) timestamp(v int64, leaf *parquetLeaf) time.Time {
+	if leaf.converted == parquetTimestampMillis {
+		return time.UnixMilli(v).In(r.cfg.location)
+	}
+	return time.UnixMicro(v).In(r.cfg.location)
+}
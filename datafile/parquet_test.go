@@ -0,0 +1,197 @@
+package datafile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/tradingiq/projectx-client/models"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// fills.parquet holds testFills in row groups of two. TestParquetPyarrow runs
+// testdata/check_pyarrow.py to confirm pyarrow accepts the writer's output.
+const goldenFills = "testdata/fills.parquet"
+
+func writeParquet[T Record](t *testing.T, records []T, opts ...Option) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w, err := NewWriter[T](&buf, Parquet, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range records {
+		if err := w.Write(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestParquetGolden(t *testing.T) {
+	data := writeParquet(t, testFills(), WithRowGroupSize(2))
+	if *update {
+		if err := os.WriteFile(goldenFills, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	golden, err := os.ReadFile(goldenFills)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, golden) {
+		t.Errorf("writer output differs from %s; run go test -update if the change is intended", goldenFills)
+	}
+
+	r, err := NewReader[models.HalfTradeModel](bytes.NewReader(golden), Parquet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := r.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, Parquet, testFills(), got)
+}
+
+// TestParquetPyarrow checks the golden file with pyarrow and reads back a file
+// pyarrow wrote. It is skipped without python3 and pyarrow unless
+// DATAFILE_REQUIRE_PYARROW is set, as it is in CI.
+func TestParquetPyarrow(t *testing.T) {
+	require := os.Getenv("DATAFILE_REQUIRE_PYARROW") != ""
+	python, err := exec.LookPath("python3")
+	if err == nil {
+		err = exec.Command(python, "-c", "import pyarrow").Run()
+	}
+	if err != nil {
+		if require {
+			t.Fatalf("pyarrow unavailable: %v", err)
+		}
+		t.Skipf("pyarrow unavailable: %v", err)
+	}
+
+	out := filepath.Join(t.TempDir(), "fills.parquet")
+	cmd := exec.Command(python, "check_pyarrow.py", out)
+	cmd.Dir = "testdata"
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("check_pyarrow.py: %v\n%s", err, output)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReader[models.HalfTradeModel](bytes.NewReader(data), Parquet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := r.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, Parquet, testFills(), got)
+}
+
+func TestParquetRowGroups(t *testing.T) {
+	for _, size := range []int{1, 2, 3, 5, 6} {
+		data := writeParquet(t, testFills(), WithRowGroupSize(size))
+		r, err := newParquetReader(bytes.NewReader(data), columnSpecs(halfTradeColumns), newConfig(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := (5 + size - 1) / size; len(r.rowGroups) != want {
+			t.Errorf("row group size %d: wrote %d row groups, want %d", size, len(r.rowGroups), want)
+		}
+	}
+}
+
+func TestParquetFlushEndsRowGroup(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter[models.HalfTradeModel](&buf, Parquet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, fill := range testFills() {
+		if err := w.Write(fill); err != nil {
+			t.Fatal(err)
+		}
+		if i == 2 {
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader[models.HalfTradeModel](bytes.NewReader(buf.Bytes()), Parquet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := r.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, Parquet, testFills(), got)
+}
+
+func TestParquetCorrupt(t *testing.T) {
+	data := writeParquet(t, testFills(), WithRowGroupSize(2))
+	tests := map[string][]byte{
+		"empty":        nil,
+		"bad magic":    append([]byte("PAR0"), data[4:]...),
+		"truncated":    data[:len(data)-20],
+		"short footer": append(append([]byte{}, data[:len(data)-8]...), 0xff, 0xff, 0xff, 0x7f, 'P', 'A', 'R', '1'),
+	}
+	for name, data := range tests {
+		r, err := NewReader[models.HalfTradeModel](bytes.NewReader(data), Parquet)
+		if err == nil {
+			_, err = r.ReadAll()
+		}
+		if err == nil {
+			t.Errorf("%s: read without error", name)
+		}
+	}
+
+}
+
+func TestParquetSchema(t *testing.T) {
+	data := writeParquet(t, testFills())
+	footerSize := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footer := data[len(data)-8-footerSize : len(data)-8]
+	meta, err := newThriftReader(bytes.NewReader(footer)).readStruct()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	elements := make(map[string]thriftStruct)
+	for _, element := range meta.list(2)[1:] {
+		s := element.(thriftStruct)
+		elements[s.string(4)] = s
+	}
+
+	timestamp := elements["creationTimestamp"].structure(10).structure(parquetLogicalTimestamp)
+	if utc, _ := timestamp[1].(bool); !utc || timestamp.structure(2).structure(parquetUnitMicros) == nil {
+		t.Errorf("creationTimestamp logical type %v, want UTC microseconds", timestamp)
+	}
+	if elements["contractId"].structure(10).structure(parquetLogicalString) == nil {
+		t.Error("contractId has no string logical type")
+	}
+	if elements["profitAndLoss"].int(3) != parquetOptional || elements["voided"].int(3) != parquetRequired {
+		t.Error("wrong repetition for profitAndLoss or voided")
+	}
+	if elements["voided"].int(1) != parquetBoolean {
+		t.Errorf("voided has physical type %d", elements["voided"].int(1))
+	}
+}
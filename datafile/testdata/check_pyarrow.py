@@ -0,0 +1,72 @@
+"""Reads fills.parquet with pyarrow and compares it to testFills in
+datafile_test.go. Given a path, it also writes testFills there with pyarrow,
+using the plain uncompressed encoding the datafile reader supports, so
+TestParquetPyarrow can read a file this package did not write.
+
+Run from this directory: python3 check_pyarrow.py [out.parquet]"""
+
+import sys
+from datetime import datetime, timedelta, timezone
+
+import pyarrow as pa
+import pyarrow.parquet as pq
+
+at = datetime(2025, 3, 14, 14, 30, 0, 123456, tzinfo=timezone.utc)
+
+expected = {
+    "id": [1, 2, 3, 4, 5],
+    "accountId": [7, 7, 7, 7, 8],
+    "contractId": ["CON.F.US.MES.Z25"] * 2 + ["CON.F.US.MNQ.Z25"] * 2 + ["CON.F.US.MES.Z25"],
+    "creationTimestamp": [
+        at,
+        at + timedelta(seconds=1),
+        at + timedelta(minutes=1),
+        at + timedelta(minutes=2),
+        at + timedelta(hours=1),
+    ],
+    "price": [5012.25, 5013.5, 18250.0, 18244.75, 5020.0],
+    "profitAndLoss": [None, 6.25, None, -21.0, 0.0],
+    "fees": [0.37, 0.37, 0.74, 0.74, 0.37],
+    "side": [0, 1, 0, 1, 1],
+    "size": [1, 1, 2, 2, 1],
+    "voided": [False, False, True, False, True],
+    "orderId": [101, 102, 103, 104, 105],
+}
+
+schema = pa.schema([
+    pa.field("id", pa.int64(), nullable=False),
+    pa.field("accountId", pa.int64(), nullable=False),
+    pa.field("contractId", pa.string(), nullable=False),
+    pa.field("creationTimestamp", pa.timestamp("us", tz="UTC"), nullable=False),
+    pa.field("price", pa.float64(), nullable=False),
+    pa.field("profitAndLoss", pa.float64(), nullable=True),
+    pa.field("fees", pa.float64(), nullable=False),
+    pa.field("side", pa.int64(), nullable=False),
+    pa.field("size", pa.int64(), nullable=False),
+    pa.field("voided", pa.bool_(), nullable=False),
+    pa.field("orderId", pa.int64(), nullable=False),
+])
+
+f = pq.ParquetFile("fills.parquet")
+assert f.metadata.num_row_groups == 3, f.metadata.num_row_groups
+assert f.schema_arrow.field("creationTimestamp").type == pa.timestamp("us", tz="UTC")
+assert f.schema_arrow.field("profitAndLoss").nullable
+assert not f.schema_arrow.field("voided").nullable
+
+table = f.read()
+assert table.column_names == list(expected), table.column_names
+for name, values in expected.items():
+    got = table.column(name).to_pylist()
+    assert got == values, (name, got, values)
+
+if len(sys.argv) > 1:
+    pq.write_table(
+        pa.table(expected, schema=schema),
+        sys.argv[1],
+        row_group_size=2,
+        compression="NONE",
+        use_dictionary=False,
+        data_page_version="1.0",
+    )
+
+print("ok")
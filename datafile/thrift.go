@@ -0,0 +1,291 @@
+package datafile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Thrift compact protocol types, as used by the Parquet footer and page
+// headers.
+const (
+	compactStop      = 0
+	compactTrue      = 1
+	compactFalse     = 2
+	compactByte      = 3
+	compactI16       = 4
+	compactI32       = 5
+	compactI64       = 6
+	compactDouble    = 7
+	compactBinary    = 8
+	compactList      = 9
+	compactSet       = 10
+	compactMap       = 11
+	compactStruct    = 12
+	compactMaxNested = 64
+)
+
+type thriftWriter struct {
+	buf  bytes.Buffer
+	last []int16
+}
+
+func (w *thriftWriter) varint(v uint64) {
+	w.buf.Write(binary.AppendUvarint(nil, v))
+}
+
+func (w *thriftWriter) zigzag(v int64) {
+	w.varint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (w *thriftWriter) field(id int16, typ byte) {
+	last := &w.last[len(w.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		w.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		w.buf.WriteByte(typ)
+		w.zigzag(int64(id))
+	}
+	*last = id
+}
+
+func (w *thriftWriter) beginStruct() {
+	w.last = append(w.last, 0)
+}
+
+func (w *thriftWriter) endStruct() {
+	w.buf.WriteByte(compactStop)
+	w.last = w.last[:len(w.last)-1]
+}
+
+func (w *thriftWriter) bool(id int16, v bool) {
+	if v {
+		w.field(id, compactTrue)
+	} else {
+		w.field(id, compactFalse)
+	}
+}
+
+func (w *thriftWriter) i32(id int16, v int32) {
+	w.field(id, compactI32)
+	w.zigzag(int64(v))
+}
+
+func (w *thriftWriter) i64(id int16, v int64) {
+	w.field(id, compactI64)
+	w.zigzag(v)
+}
+
+func (w *thriftWriter) binary(id int16, v string) {
+	w.field(id, compactBinary)
+	w.varint(uint64(len(v)))
+	w.buf.WriteString(v)
+}
+
+func (w *thriftWriter) structField(id int16) {
+	w.field(id, compactStruct)
+	w.beginStruct()
+}
+
+func (w *thriftWriter) list(id int16, elem byte, n int) {
+	w.field(id, compactList)
+	if n < 15 {
+		w.buf.WriteByte(byte(n)<<4 | elem)
+		return
+	}
+	w.buf.WriteByte(0xf0 | elem)
+	w.varint(uint64(n))
+}
+
+func (w *thriftWriter) i32Element(v int32) {
+	w.zigzag(int64(v))
+}
+
+func (w *thriftWriter) binaryElement(v string) {
+	w.varint(uint64(len(v)))
+	w.buf.WriteString(v)
+}
+
+// thriftStruct holds a decoded struct by field id. Values are int64 for
+// integers, bool, float64, []byte, []interface{} for lists and sets, and
+// thriftStruct for nested structs. Maps are skipped.
+type thriftStruct map[int16]interface{}
+
+func (s thriftStruct) int(id int16) int64 {
+	v, _ := s[id].(int64)
+	return v
+}
+
+func (s thriftStruct) string(id int16) string {
+	v, _ := s[id].([]byte)
+	return string(v)
+}
+
+func (s thriftStruct) structure(id int16) thriftStruct {
+	v, _ := s[id].(thriftStruct)
+	return v
+}
+
+func (s thriftStruct) list(id int16) []interface{} {
+	v, _ := s[id].([]interface{})
+	return v
+}
+
+var errThriftCorrupt = errors.New("corrupt thrift data")
+
+type thriftReader struct {
+	r     io.ByteReader
+	depth int
+}
+
+func newThriftReader(r io.Reader) *thriftReader {
+	if br, ok := r.(io.ByteReader); ok {
+		return &thriftReader{r: br}
+	}
+	return &thriftReader{r: bufio.NewReader(r)}
+}
+
+func (r *thriftReader) varint() (uint64, error) {
+	return binary.ReadUvarint(r.r)
+}
+
+func (r *thriftReader) zigzag() (int64, error) {
+	v, err := r.varint()
+	if err != nil {
+		return 0, err
+	}
+	return int64(v>>1) ^ -int64(v&1), nil
+}
+
+func (r *thriftReader) readStruct() (thriftStruct, error) {
+	r.depth++
+	defer func() { r.depth-- }()
+	if r.depth > compactMaxNested {
+		return nil, fmt.Errorf("%w: nested too deeply", errThriftCorrupt)
+	}
+
+	s := make(thriftStruct)
+	var last int16
+	for {
+		header, err := r.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		typ := header & 0x0f
+		if typ == compactStop {
+			return s, nil
+		}
+
+		id := last + int16(header>>4)
+		if header>>4 == 0 {
+			v, err := r.zigzag()
+			if err != nil {
+				return nil, err
+			}
+			id = int16(v)
+		}
+		last = id
+
+		var value interface{}
+		switch typ {
+		case compactTrue:
+			value = true
+		case compactFalse:
+			value = false
+		default:
+			value, err = r.readValue(typ)
+			if err != nil {
+				return nil, err
+			}
+		}
+		s[id] = value
+	}
+}
+
+func (r *thriftReader) readValue(typ byte) (interface{}, error) {
+	switch typ {
+	case compactTrue, compactFalse:
+		// Booleans inside lists are encoded as a byte.
+		b, err := r.r.ReadByte()
+		return b == compactTrue, err
+	case compactByte:
+		b, err := r.r.ReadByte()
+		return int64(int8(b)), err
+	case compactI16, compactI32, compactI64:
+		return r.zigzag()
+	case compactDouble:
+		var b [8]byte
+		for i := range b {
+			c, err := r.r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			b[i] = c
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(b[:])), nil
+	case compactBinary:
+		n, err := r.varint()
+		if err != nil {
+			return nil, err
+		}
+		if n > 1<<26 {
+			return nil, fmt.Errorf("%w: binary of %d bytes", errThriftCorrupt, n)
+		}
+		b := make([]byte, n)
+		for i := range b {
+			if b[i], err = r.r.ReadByte(); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case compactList, compactSet:
+		header, err := r.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		n := uint64(header >> 4)
+		if n == 15 {
+			if n, err = r.varint(); err != nil {
+				return nil, err
+			}
+		}
+		if n > math.MaxInt32 {
+			return nil, fmt.Errorf("%w: list of %d elements", errThriftCorrupt, n)
+		}
+		list := make([]interface{}, 0, min(n, 1024))
+		for i := uint64(0); i < n; i++ {
+			v, err := r.readValue(header & 0x0f)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	case compactMap:
+		n, err := r.varint()
+		if err != nil || n == 0 {
+			return nil, err
+		}
+		types, err := r.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		for i := uint64(0); i < n; i++ {
+			if _, err := r.readValue(types >> 4); err != nil {
+				return nil, err
+			}
+			if _, err := r.readValue(types & 0x0f); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	case compactStruct:
+		return r.readStruct()
+	default:
+		return nil, fmt.Errorf("%w: unknown type %d", errThriftCorrupt, typ)
+	}
+}
@@ -0,0 +1,296 @@
+package projectx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tradingiq/projectx-client/models"
+)
+
+const (
+	DefaultFlattenConcurrency    = 8
+	DefaultFlattenVerifyAttempts = 5
+	DefaultFlattenVerifyInterval = 500 * time.Millisecond
+)
+
+var ErrNotFlat = errors.New("account not flat")
+
+type FlattenItemKind string
+
+const (
+	FlattenItemOrder    FlattenItemKind = "order"
+	FlattenItemPosition FlattenItemKind = "position"
+)
+
+type FlattenFailure struct {
+	Kind       FlattenItemKind
+	OrderID    int32
+	ContractID string
+	Err        error
+}
+
+func (f FlattenFailure) Error() string {
+	if f.Kind == FlattenItemOrder {
+		return fmt.Sprintf("failed to cancel order %d: %v", f.OrderID, f.Err)
+	}
+	return fmt.Sprintf("failed to close position %s: %v", f.ContractID, f.Err)
+}
+
+func (f FlattenFailure) Unwrap() error {
+	return f.Err
+}
+
+type FlattenReport struct {
+	AccountID       int32
+	CancelledOrders []int32
+	ClosedPositions []string
+	Failures        []FlattenFailure
+	// RemainingOrders and RemainingPositions are what the account still held
+	// at the last verification.
+	RemainingOrders    []models.OrderModel
+	RemainingPositions []models.PositionModel
+	Flat               bool
+}
+
+type flattenConfig struct {
+	concurrency    int
+	verifyAttempts int
+	verifyInterval time.Duration
+}
+
+type FlattenOption func(*flattenConfig)
+
+// WithFlattenConcurrency bounds the number of cancel or close requests in
+// flight at once.
+func WithFlattenConcurrency(n int) FlattenOption {
+	return func(c *flattenConfig) {
+		c.concurrency = n
+	}
+}
+
+// WithFlattenVerify sets how often the account is checked after flattening
+// and how long to wait between checks.
+func WithFlattenVerify(attempts int, interval time.Duration) FlattenOption {
+	return func(c *flattenConfig) {
+		c.verifyAttempts = attempts
+		c.verifyInterval = interval
+	}
+}
+
+func newFlattenConfig(opts []FlattenOption) flattenConfig {
+	cfg := flattenConfig{
+		concurrency:    DefaultFlattenConcurrency,
+		verifyAttempts: DefaultFlattenVerifyAttempts,
+		verifyInterval: DefaultFlattenVerifyInterval,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.concurrency < 1 {
+		cfg.concurrency = 1
+	}
+	if cfg.verifyAttempts < 1 {
+		cfg.verifyAttempts = 1
+	}
+	return cfg
+}
+
+// CancelAllOrders cancels every open order of accountID in parallel. Failures
+// are listed in the report and returned joined.
+func (c *Client) CancelAllOrders(ctx context.Context, accountID int32, opts ...FlattenOption) (*FlattenReport, error) {
+	cfg := newFlattenConfig(opts)
+	report := &FlattenReport{AccountID: accountID}
+
+	if err := c.cancelAllOrders(ctx, accountID, cfg, report); err != nil {
+		return report, err
+	}
+	return report, joinFailures(report.Failures)
+}
+
+// CloseAllPositions closes every open position of accountID in parallel.
+func (c *Client) CloseAllPositions(ctx context.Context, accountID int32, opts ...FlattenOption) (*FlattenReport, error) {
+	cfg := newFlattenConfig(opts)
+	report := &FlattenReport{AccountID: accountID}
+
+	if err := c.closeAllPositions(ctx, accountID, cfg, report); err != nil {
+		return report, err
+	}
+	return report, joinFailures(report.Failures)
+}
+
+// Flatten cancels every open order and then closes every open position of
+// accountID, and verifies that nothing is left. Orders are cancelled first so
+// that resting stops cannot reopen a position while it is being closed. It is
+// meant as a kill switch: when called from a signal handler, pass a context
+// that is not already cancelled by the same signal.
+func (c *Client) Flatten(ctx context.Context, accountID int32, opts ...FlattenOption) (*FlattenReport, error) {
+	cfg := newFlattenConfig(opts)
+	report := &FlattenReport{AccountID: accountID}
+
+	var errs []error
+	if err := c.cancelAllOrders(ctx, accountID, cfg, report); err != nil {
+		errs = append(errs, err)
+	}
+	if err := c.closeAllPositions(ctx, accountID, cfg, report); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := c.verifyFlat(ctx, accountID, cfg, report); err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, failure := range report.Failures {
+		errs = append(errs, failure)
+	}
+	if !report.Flat {
+		errs = append(errs, fmt.Errorf("account %d: %w: %d open orders, %d open positions",
+			accountID, ErrNotFlat, len(report.RemainingOrders), len(report.RemainingPositions)))
+	}
+	return report, errors.Join(errs...)
+}
+
+func (c *Client) cancelAllOrders(ctx context.Context, accountID int32, cfg flattenConfig, report *FlattenReport) error {
+	resp, err := c.Order.SearchOpenOrders(ctx, &models.SearchOpenOrderRequest{AccountID: accountID})
+	if err != nil {
+		return fmt.Errorf("failed to search open orders: %w", err)
+	}
+	if !resp.Success {
+		return fmt.Errorf("failed to search open orders with error code: %v", resp.ErrorCode)
+	}
+
+	var mu sync.Mutex
+	forEachBounded(len(resp.Orders), cfg.concurrency, func(i int) {
+		order := resp.Orders[i]
+		cancelResp, err := c.Order.CancelOrder(ctx, &models.CancelOrderRequest{
+			AccountID: accountID,
+			OrderID:   order.ID,
+		})
+		if err == nil && !cancelResp.Success {
+			err = fmt.Errorf("error code: %v", cancelResp.ErrorCode)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			report.Failures = append(report.Failures, FlattenFailure{Kind: FlattenItemOrder, OrderID: order.ID, ContractID: order.ContractID, Err: err})
+			return
+		}
+		report.CancelledOrders = append(report.CancelledOrders, order.ID)
+	})
+
+	sort.Slice(report.CancelledOrders, func(i, j int) bool {
+		return report.CancelledOrders[i] < report.CancelledOrders[j]
+	})
+	return nil
+}
+
+func (c *Client) closeAllPositions(ctx context.Context, accountID int32, cfg flattenConfig, report *FlattenReport) error {
+	resp, err := c.Position.SearchOpenPositions(ctx, &models.SearchPositionRequest{AccountID: accountID})
+	if err != nil {
+		return fmt.Errorf("failed to search open positions: %w", err)
+	}
+	if !resp.Success {
+		return fmt.Errorf("failed to search open positions with error code: %v", resp.ErrorCode)
+	}
+
+	var mu sync.Mutex
+	forEachBounded(len(resp.Positions), cfg.concurrency, func(i int) {
+		position := resp.Positions[i]
+		closeResp, err := c.Position.CloseContractPosition(ctx, &models.CloseContractPositionRequest{
+			AccountID:  accountID,
+			ContractID: position.ContractID,
+		})
+		if err == nil && !closeResp.Success {
+			err = fmt.Errorf("error code: %v", closeResp.ErrorCode)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			report.Failures = append(report.Failures, FlattenFailure{Kind: FlattenItemPosition, ContractID: position.ContractID, Err: err})
+			return
+		}
+		report.ClosedPositions = append(report.ClosedPositions, position.ContractID)
+	})
+
+	sort.Strings(report.ClosedPositions)
+	return nil
+}
+
+// verifyFlat polls the account until it holds no open orders or positions or
+// the attempts are used up, recording what was left in report.
+func (c *Client) verifyFlat(ctx context.Context, accountID int32, cfg flattenConfig, report *FlattenReport) error {
+	var lastErr error
+	for attempt := 0; attempt < cfg.verifyAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(cfg.verifyInterval):
+			}
+		}
+
+		orders, positions, err := c.openItems(ctx, accountID)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		lastErr = nil
+		report.RemainingOrders = orders
+		report.RemainingPositions = positions
+		if len(orders) == 0 && len(positions) == 0 {
+			report.Flat = true
+			return nil
+		}
+	}
+	return lastErr
+}
+
+func (c *Client) openItems(ctx context.Context, accountID int32) ([]models.OrderModel, []models.PositionModel, error) {
+	orders, err := c.Order.SearchOpenOrders(ctx, &models.SearchOpenOrderRequest{AccountID: accountID})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to search open orders: %w", err)
+	}
+	if !orders.Success {
+		return nil, nil, fmt.Errorf("failed to search open orders with error code: %v", orders.ErrorCode)
+	}
+
+	positions, err := c.Position.SearchOpenPositions(ctx, &models.SearchPositionRequest{AccountID: accountID})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to search open positions: %w", err)
+	}
+	if !positions.Success {
+		return nil, nil, fmt.Errorf("failed to search open positions with error code: %v", positions.ErrorCode)
+	}
+
+	return orders.Orders, positions.Positions, nil
+}
+
+// forEachBounded calls fn for 0..n-1 with at most limit calls running at once
+// and returns when all of them have finished.
+func forEachBounded(n, limit int, fn func(i int)) {
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func joinFailures(failures []FlattenFailure) error {
+	errs := make([]error, 0, len(failures))
+	for _, failure := range failures {
+		errs = append(errs, failure)
+	}
+	return errors.Join(errs...)
+}
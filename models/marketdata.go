@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
 
 type Quote struct {
 	BestAsk       float64   `json:"bestAsk"`
@@ -10,10 +14,123 @@ type Quote struct {
 	LastPrice     float64   `json:"lastPrice"`
 	LastUpdated   time.Time `json:"lastUpdated"`
 	Symbol        string    `json:"symbol"`
+	SymbolName    string    `json:"symbolName"`
+	Open          float64   `json:"open"`
+	High          float64   `json:"high"`
+	Low           float64   `json:"low"`
 	Timestamp     time.Time `json:"timestamp"`
 	Volume        int       `json:"volume"`
 }
 
+type QuoteField uint32
+
+const (
+	QuoteFieldBestAsk QuoteField = 1 << iota
+	QuoteFieldBestBid
+	QuoteFieldChange
+	QuoteFieldChangePercent
+	QuoteFieldLastPrice
+	QuoteFieldLastUpdated
+	QuoteFieldSymbol
+	QuoteFieldSymbolName
+	QuoteFieldOpen
+	QuoteFieldHigh
+	QuoteFieldLow
+	QuoteFieldTimestamp
+	QuoteFieldVolume
+
+	QuoteFieldNone QuoteField = 0
+)
+
+func (f QuoteField) Has(field QuoteField) bool {
+	return f&field == field
+}
+
+var quoteFieldNames = map[string]QuoteField{
+	"bestask":       QuoteFieldBestAsk,
+	"bestbid":       QuoteFieldBestBid,
+	"change":        QuoteFieldChange,
+	"changepercent": QuoteFieldChangePercent,
+	"lastprice":     QuoteFieldLastPrice,
+	"lastupdated":   QuoteFieldLastUpdated,
+	"symbol":        QuoteFieldSymbol,
+	"symbolname":    QuoteFieldSymbolName,
+	"open":          QuoteFieldOpen,
+	"high":          QuoteFieldHigh,
+	"low":           QuoteFieldLow,
+	"timestamp":     QuoteFieldTimestamp,
+	"volume":        QuoteFieldVolume,
+}
+
+// ParsePartialQuote decodes a possibly partial quote payload and reports
+// which fields were present in it.
+func ParsePartialQuote(data []byte) (Quote, QuoteField, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return Quote{}, QuoteFieldNone, err
+	}
+
+	var quote Quote
+	if err := json.Unmarshal(data, &quote); err != nil {
+		return Quote{}, QuoteFieldNone, err
+	}
+
+	present := QuoteFieldNone
+	for name, value := range raw {
+		if string(value) == "null" {
+			continue
+		}
+		present |= quoteFieldNames[strings.ToLower(name)]
+	}
+
+	return quote, present, nil
+}
+
+// Merge copies the present fields of partial into q and returns the fields
+// whose values changed.
+func (q *Quote) Merge(partial Quote, present QuoteField) QuoteField {
+	changed := QuoteFieldNone
+
+	mergeFloat := func(field QuoteField, dst *float64, src float64) {
+		if present.Has(field) && *dst != src {
+			*dst = src
+			changed |= field
+		}
+	}
+	mergeTime := func(field QuoteField, dst *time.Time, src time.Time) {
+		if present.Has(field) && !dst.Equal(src) {
+			*dst = src
+			changed |= field
+		}
+	}
+	mergeString := func(field QuoteField, dst *string, src string) {
+		if present.Has(field) && *dst != src {
+			*dst = src
+			changed |= field
+		}
+	}
+
+	mergeFloat(QuoteFieldBestAsk, &q.BestAsk, partial.BestAsk)
+	mergeFloat(QuoteFieldBestBid, &q.BestBid, partial.BestBid)
+	mergeFloat(QuoteFieldChange, &q.Change, partial.Change)
+	mergeFloat(QuoteFieldChangePercent, &q.ChangePercent, partial.ChangePercent)
+	mergeFloat(QuoteFieldLastPrice, &q.LastPrice, partial.LastPrice)
+	mergeTime(QuoteFieldLastUpdated, &q.LastUpdated, partial.LastUpdated)
+	mergeString(QuoteFieldSymbol, &q.Symbol, partial.Symbol)
+	mergeString(QuoteFieldSymbolName, &q.SymbolName, partial.SymbolName)
+	mergeFloat(QuoteFieldOpen, &q.Open, partial.Open)
+	mergeFloat(QuoteFieldHigh, &q.High, partial.High)
+	mergeFloat(QuoteFieldLow, &q.Low, partial.Low)
+	mergeTime(QuoteFieldTimestamp, &q.Timestamp, partial.Timestamp)
+
+	if present.Has(QuoteFieldVolume) && q.Volume != partial.Volume {
+		q.Volume = partial.Volume
+		changed |= QuoteFieldVolume
+	}
+
+	return changed
+}
+
 type Trade struct {
 	Price     float64   `json:"price"`
 	SymbolID  string    `json:"symbolId"`
@@ -24,11 +141,59 @@ type Trade struct {
 
 type TradeData []Trade
 
+type DepthType int
+
+const (
+	DepthTypeUnknown    DepthType = 0
+	DepthTypeAsk        DepthType = 1
+	DepthTypeBid        DepthType = 2
+	DepthTypeBestAsk    DepthType = 3
+	DepthTypeBestBid    DepthType = 4
+	DepthTypeTrade      DepthType = 5
+	DepthTypeReset      DepthType = 6
+	DepthTypeLow        DepthType = 7
+	DepthTypeHigh       DepthType = 8
+	DepthTypeNewBestBid DepthType = 9
+	DepthTypeNewBestAsk DepthType = 10
+	DepthTypeFill       DepthType = 11
+)
+
+func (d DepthType) String() string {
+	switch d {
+	case DepthTypeUnknown:
+		return "UNKNOWN"
+	case DepthTypeAsk:
+		return "ASK"
+	case DepthTypeBid:
+		return "BID"
+	case DepthTypeBestAsk:
+		return "BEST_ASK"
+	case DepthTypeBestBid:
+		return "BEST_BID"
+	case DepthTypeTrade:
+		return "TRADE"
+	case DepthTypeReset:
+		return "RESET"
+	case DepthTypeLow:
+		return "LOW"
+	case DepthTypeHigh:
+		return "HIGH"
+	case DepthTypeNewBestBid:
+		return "NEW_BEST_BID"
+	case DepthTypeNewBestAsk:
+		return "NEW_BEST_ASK"
+	case DepthTypeFill:
+		return "FILL"
+	default:
+		return "UNKNOWN"
+	}
+}
+
 type MarketDepth struct {
 	Price         float64   `json:"price"`
 	Volume        float64   `json:"volume"`
 	CurrentVolume int       `json:"currentVolume"`
-	Type          int       `json:"type"`
+	Type          DepthType `json:"type"`
 	Timestamp     time.Time `json:"timestamp"`
 }
 
@@ -510,6 +510,8 @@ type OrderModel struct {
 	LimitPrice        *float64    `json:"limitPrice,omitempty"`
 	StopPrice         *float64    `json:"stopPrice,omitempty"`
 	FillVolume        int32       `json:"fillVolume"`
+	FilledPrice       *float64    `json:"filledPrice,omitempty"`
+	CustomTag         *string     `json:"customTag,omitempty"`
 }
 
 type OrderUpdateData struct {
@@ -524,11 +526,14 @@ type OrderUpdatePayload struct {
 	FillVolume        int32       `json:"fillVolume"`
 	ID                int32       `json:"id"`
 	LimitPrice        float64     `json:"limitPrice"`
+	StopPrice         float64     `json:"stopPrice"`
+	FilledPrice       float64     `json:"filledPrice"`
 	Side              OrderSide   `json:"side"`
 	Size              int32       `json:"size"`
 	Status            OrderStatus `json:"status"`
 	Type              OrderType   `json:"type"`
 	UpdateTimestamp   time.Time   `json:"updateTimestamp"`
+	CustomTag         string      `json:"customTag"`
 }
 
 func (o *OrderUpdatePayload) UnmarshalJSON(data []byte) error {
@@ -540,11 +545,14 @@ func (o *OrderUpdatePayload) UnmarshalJSON(data []byte) error {
 		FillVolume        int32   `json:"fillVolume"`
 		ID                float64 `json:"id"`
 		LimitPrice        float64 `json:"limitPrice"`
+		StopPrice         float64 `json:"stopPrice"`
+		FilledPrice       float64 `json:"filledPrice"`
 		Side              int32   `json:"side"`
 		Size              int32   `json:"size"`
 		Status            int32   `json:"status"`
 		Type              int32   `json:"type"`
 		UpdateTimestamp   string  `json:"updateTimestamp"`
+		CustomTag         string  `json:"customTag"`
 	}
 
 	var raw rawOrderUpdatePayload
@@ -557,6 +565,9 @@ func (o *OrderUpdatePayload) UnmarshalJSON(data []byte) error {
 	o.FillVolume = raw.FillVolume
 	o.ID = int32(raw.ID)
 	o.LimitPrice = raw.LimitPrice
+	o.StopPrice = raw.StopPrice
+	o.FilledPrice = raw.FilledPrice
+	o.CustomTag = raw.CustomTag
 	o.Side = OrderSide(raw.Side)
 	o.Size = raw.Size
 	o.Status = OrderStatus(raw.Status)
@@ -576,6 +587,43 @@ func (o *OrderUpdatePayload) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+func (o OrderUpdatePayload) Order() OrderModel {
+	order := OrderModel{
+		ID:                o.ID,
+		AccountID:         o.AccountID,
+		ContractID:        o.ContractID,
+		CreationTimestamp: o.CreationTimestamp,
+		Status:            o.Status,
+		Type:              o.Type,
+		Side:              o.Side,
+		Size:              o.Size,
+		FillVolume:        o.FillVolume,
+	}
+
+	if !o.UpdateTimestamp.IsZero() {
+		updated := o.UpdateTimestamp
+		order.UpdateTimestamp = &updated
+	}
+	if o.LimitPrice != 0 {
+		limitPrice := o.LimitPrice
+		order.LimitPrice = &limitPrice
+	}
+	if o.StopPrice != 0 {
+		stopPrice := o.StopPrice
+		order.StopPrice = &stopPrice
+	}
+	if o.FilledPrice != 0 {
+		filledPrice := o.FilledPrice
+		order.FilledPrice = &filledPrice
+	}
+	if o.CustomTag != "" {
+		customTag := o.CustomTag
+		order.CustomTag = &customTag
+	}
+
+	return order
+}
+
 func parseTimestamp(timestamp string) (time.Time, error) {
 	if timestamp == "" {
 		return time.Time{}, nil
@@ -650,6 +698,18 @@ func (p *PositionUpdatePayload) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+func (p PositionUpdatePayload) Position() PositionModel {
+	return PositionModel{
+		ID:                p.ID,
+		AccountID:         p.AccountID,
+		ContractID:        p.ContractID,
+		CreationTimestamp: p.CreationTimestamp,
+		Type:              p.Type,
+		Size:              p.Size,
+		AveragePrice:      p.AveragePrice,
+	}
+}
+
 type AccountUpdateData struct {
 	Action int                  `json:"action"`
 	Data   AccountUpdatePayload `json:"data"`
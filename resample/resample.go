@@ -0,0 +1,277 @@
+// Package resample aggregates AggregateBarModel series into higher timeframes
+// aligned to trading session boundaries.
+package resample
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/tradingiq/projectx-client/models"
+)
+
+type GapMode int
+
+const (
+	// GapSkip leaves out buckets without any input bar.
+	GapSkip GapMode = iota
+	// GapForwardFill emits buckets without input bars inside the session as
+	// flat bars at the previous close with zero volume.
+	GapForwardFill
+)
+
+// Session describes the trading day the buckets are aligned to. A session
+// opens Start after local midnight in Location and lasts Length. Sessions are
+// labelled with the date they close on, so a CME session opening Sunday at
+// 17:00 is Monday's session. Day, week and month buckets group sessions by
+// that label.
+type Session struct {
+	Location *time.Location
+	Start    time.Duration
+	// Length defaults to 24 hours.
+	Length time.Duration
+	// Weekdays are the labels of trading sessions. Gaps are only filled on
+	// those days; all days trade when empty.
+	Weekdays []time.Weekday
+}
+
+// CME returns the CME Globex session, 17:00 to 16:00 Chicago time, Monday to
+// Friday.
+func CME() (Session, error) {
+	loc, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		return Session{}, fmt.Errorf("failed to load CME time zone: %w", err)
+	}
+	return Session{
+		Location: loc,
+		Start:    17 * time.Hour,
+		Length:   23 * time.Hour,
+		Weekdays: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+	}, nil
+}
+
+type Config struct {
+	Unit       models.AggregateBarUnit
+	UnitNumber int32
+	// Session defaults to UTC days starting at midnight.
+	Session Session
+	Gaps    GapMode
+}
+
+// Bars aggregates bars into buckets of cfg.UnitNumber units. Each output bar
+// opens with the first and closes with the last input bar of its bucket,
+// takes the highest high and lowest low and sums the volume. Output bars are
+// stamped with the bucket start. Intraday buckets restart at every session
+// open, so a bucket size that does not divide the session leaves a shorter
+// last bucket. Day multiples are counted from the Unix epoch, week multiples
+// from the Monday of 1970-01-05 and month multiples from January of year 0,
+// so that buckets are stable across calls.
+func Bars(bars []models.AggregateBarModel, cfg Config) ([]models.AggregateBarModel, error) {
+	a, err := NewAligner(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if len(bars) == 0 {
+		return nil, nil
+	}
+
+	sorted := make([]models.AggregateBarModel, len(bars))
+	copy(sorted, bars)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].T.Before(sorted[j].T)
+	})
+
+	var result []models.AggregateBarModel
+	var bucket time.Time
+	for _, bar := range sorted {
+		start := a.Bucket(bar.T)
+		if len(result) > 0 && start.Equal(bucket) {
+			last := &result[len(result)-1]
+			last.High = max(last.High, bar.High)
+			last.Low = min(last.Low, bar.Low)
+			last.Close = bar.Close
+			last.Volume += bar.Volume
+			continue
+		}
+
+		if len(result) > 0 && cfg.Gaps == GapForwardFill {
+			prev := result[len(result)-1]
+			for gap := a.Next(bucket); gap.Before(start); gap = a.Next(gap) {
+				if !a.inSession(gap) {
+					continue
+				}
+				result = append(result, models.AggregateBarModel{
+					T:     gap.In(prev.T.Location()),
+					Open:  prev.Close,
+					High:  prev.Close,
+					Low:   prev.Close,
+					Close: prev.Close,
+				})
+			}
+		}
+
+		bucket = start
+		result = append(result, models.AggregateBarModel{
+			T:      start.In(bar.T.Location()),
+			Open:   bar.Open,
+			High:   bar.High,
+			Low:    bar.Low,
+			Close:  bar.Close,
+			Volume: bar.Volume,
+		})
+	}
+	return result, nil
+}
+
+// Aligner maps times to the buckets of a Config.
+type Aligner struct {
+	unit     models.AggregateBarUnit
+	n        int
+	step     time.Duration
+	loc      *time.Location
+	start    time.Duration
+	length   time.Duration
+	weekdays map[time.Weekday]bool
+}
+
+func NewAligner(cfg Config) (*Aligner, error) {
+	if cfg.UnitNumber <= 0 {
+		return nil, fmt.Errorf("unit number must be positive, got %d", cfg.UnitNumber)
+	}
+
+	a := &Aligner{
+		unit:   cfg.Unit,
+		n:      int(cfg.UnitNumber),
+		loc:    cfg.Session.Location,
+		start:  cfg.Session.Start,
+		length: cfg.Session.Length,
+	}
+	if a.loc == nil {
+		a.loc = time.UTC
+	}
+	if a.length <= 0 {
+		a.length = 24 * time.Hour
+	}
+	if a.start < 0 || a.start >= 24*time.Hour || a.length > 24*time.Hour {
+		return nil, fmt.Errorf("session must open within a day and last at most a day")
+	}
+	if len(cfg.Session.Weekdays) > 0 {
+		a.weekdays = make(map[time.Weekday]bool, len(cfg.Session.Weekdays))
+		for _, day := range cfg.Session.Weekdays {
+			a.weekdays[day] = true
+		}
+	}
+
+	switch cfg.Unit {
+	case models.AggregateBarUnitSecond:
+		a.step = time.Second * time.Duration(a.n)
+	case models.AggregateBarUnitMinute:
+		a.step = time.Minute * time.Duration(a.n)
+	case models.AggregateBarUnitHour:
+		a.step = time.Hour * time.Duration(a.n)
+	case models.AggregateBarUnitDay, models.AggregateBarUnitWeek, models.AggregateBarUnitMonth:
+	default:
+		return nil, fmt.Errorf("unsupported bar unit %d", cfg.Unit)
+	}
+	return a, nil
+}
+
+// Bucket returns the start of the bucket holding t.
+func (a *Aligner) Bucket(t time.Time) time.Time {
+	open := a.sessionOpen(t)
+	switch a.unit {
+	case models.AggregateBarUnitDay:
+		label := a.label(open)
+		return a.openOf(label.AddDate(0, 0, -floorMod(epochDays(label), a.n)))
+	case models.AggregateBarUnitWeek:
+		label := a.label(open)
+		monday := label.AddDate(0, 0, -floorMod(int(label.Weekday())-int(time.Monday), 7))
+		weeks := floorDiv(epochDays(monday)-4, 7)
+		return a.openOf(monday.AddDate(0, 0, -7*floorMod(weeks, a.n)))
+	case models.AggregateBarUnitMonth:
+		label := a.label(open)
+		month := label.Year()*12 + int(label.Month()) - 1
+		month -= floorMod(month, a.n)
+		return a.openOf(time.Date(month/12, time.Month(month%12+1), 1, 0, 0, 0, 0, a.loc))
+	default:
+		return open.Add(t.Sub(open) / a.step * a.step)
+	}
+}
+
+// Next returns the start of the bucket after the one starting at b.
+func (a *Aligner) Next(b time.Time) time.Time {
+	label := a.label(a.sessionOpen(b))
+	switch a.unit {
+	case models.AggregateBarUnitDay:
+		return a.openOf(label.AddDate(0, 0, a.n))
+	case models.AggregateBarUnitWeek:
+		return a.openOf(label.AddDate(0, 0, 7*a.n))
+	case models.AggregateBarUnitMonth:
+		return a.openOf(label.AddDate(0, a.n, 0))
+	default:
+		return a.Bucket(b.Add(a.step))
+	}
+}
+
+// inSession reports whether a bucket starting at b can hold trades.
+func (a *Aligner) inSession(b time.Time) bool {
+	open := a.sessionOpen(b)
+	if a.weekdays != nil && !a.weekdays[a.label(open).Weekday()] {
+		return false
+	}
+	switch a.unit {
+	case models.AggregateBarUnitDay, models.AggregateBarUnitWeek, models.AggregateBarUnitMonth:
+		return true
+	default:
+		return b.Sub(open) < a.length
+	}
+}
+
+// sessionOpen returns the most recent session open at or before t.
+func (a *Aligner) sessionOpen(t time.Time) time.Time {
+	local := t.In(a.loc)
+	open := a.openOn(local.Year(), local.Month(), local.Day())
+	if open.After(local) {
+		open = a.openOn(local.Year(), local.Month(), local.Day()-1)
+	}
+	return open
+}
+
+// label returns local midnight of the date the session opening at open closes
+// on.
+func (a *Aligner) label(open time.Time) time.Time {
+	closing := open.Add(a.length - time.Nanosecond).In(a.loc)
+	return time.Date(closing.Year(), closing.Month(), closing.Day(), 0, 0, 0, 0, a.loc)
+}
+
+// openOf returns the open of the session labelled with the date of label.
+func (a *Aligner) openOf(label time.Time) time.Time {
+	open := a.openOn(label.Year(), label.Month(), label.Day())
+	if a.label(open).After(label) {
+		open = a.openOn(label.Year(), label.Month(), label.Day()-1)
+	}
+	return open
+}
+
+// openOn returns the session open on a calendar date, taking Start as wall
+// clock time so that opens do not shift on daylight saving changes.
+func (a *Aligner) openOn(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, int(a.start), a.loc)
+}
+
+func epochDays(date time.Time) int {
+	utc := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	return floorDiv(int(utc.Unix()), 24*60*60)
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
+
+func floorMod(a, b int) int {
+	return a - floorDiv(a, b)*b
+}
@@ -0,0 +1,148 @@
+package resample
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/tradingiq/projectx-client/models"
+)
+
+func utc(month time.Month, day, hour, minute int) time.Time {
+	return time.Date(2025, month, day, hour, minute, 0, 0, time.UTC)
+}
+
+func cmeAligner(t *testing.T, unit models.AggregateBarUnit, n int32) *Aligner {
+	t.Helper()
+	session, err := CME()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := NewAligner(Config{Unit: unit, UnitNumber: n, Session: session})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestBucketAcrossDaylightSaving(t *testing.T) {
+	// US daylight saving starts 2025-03-09 and ends 2025-11-02, both on a
+	// Sunday before the 17:00 Chicago open, which is 23:00 UTC in winter and
+	// 22:00 UTC in summer.
+	tests := []struct {
+		name   string
+		unit   models.AggregateBarUnit
+		n      int32
+		t      time.Time
+		bucket time.Time
+	}{
+		{"4h first bucket, winter", models.AggregateBarUnitHour, 4, utc(3, 6, 23, 30), utc(3, 6, 23, 0)},
+		{"4h short last bucket, winter", models.AggregateBarUnitHour, 4, utc(3, 7, 21, 30), utc(3, 7, 19, 0)},
+		{"4h after spring forward", models.AggregateBarUnitHour, 4, utc(3, 10, 3, 30), utc(3, 10, 2, 0)},
+		{"4h summer close", models.AggregateBarUnitHour, 4, utc(10, 30, 20, 59), utc(10, 30, 18, 0)},
+		{"4h after fall back", models.AggregateBarUnitHour, 4, utc(11, 2, 23, 10), utc(11, 2, 23, 0)},
+		{"15m after spring forward", models.AggregateBarUnitMinute, 15, utc(3, 9, 22, 14), utc(3, 9, 22, 0)},
+		{"day, Friday session", models.AggregateBarUnitDay, 1, utc(3, 7, 21, 0), utc(3, 6, 23, 0)},
+		{"day, Sunday open is Monday", models.AggregateBarUnitDay, 1, utc(3, 9, 23, 0), utc(3, 9, 22, 0)},
+		{"week before spring forward", models.AggregateBarUnitWeek, 1, utc(3, 7, 21, 0), utc(3, 2, 23, 0)},
+		{"week after spring forward", models.AggregateBarUnitWeek, 1, utc(3, 12, 15, 0), utc(3, 9, 22, 0)},
+		{"month opens the evening before the 1st", models.AggregateBarUnitMonth, 1, utc(3, 31, 12, 0), utc(2, 28, 23, 0)},
+	}
+	for _, test := range tests {
+		a := cmeAligner(t, test.unit, test.n)
+		if got := a.Bucket(test.t); !got.Equal(test.bucket) {
+			t.Errorf("%s: bucket of %s is %s, want %s", test.name, test.t, got.UTC(), test.bucket)
+		}
+	}
+}
+
+func TestBarsAggregate(t *testing.T) {
+	var bars []models.AggregateBarModel
+	for i := 0; i < 7; i++ {
+		price := 5000 + float64(i)
+		bars = append(bars, models.AggregateBarModel{
+			T:    utc(3, 12, 14, i),
+			Open: price, High: price + 2, Low: price - 1, Close: price + 1,
+			Volume: 10,
+		})
+	}
+	// Input order must not matter.
+	bars[0], bars[6] = bars[6], bars[0]
+
+	got, err := Bars(bars, Config{Unit: models.AggregateBarUnitMinute, UnitNumber: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []models.AggregateBarModel{
+		{T: utc(3, 12, 14, 0), Open: 5000, High: 5006, Low: 4999, Close: 5005, Volume: 50},
+		{T: utc(3, 12, 14, 5), Open: 5005, High: 5008, Low: 5004, Close: 5007, Volume: 20},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d bars, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("bar %d: %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestBarsForwardFillSkipsSessionGaps(t *testing.T) {
+	session, err := CME()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bar := func(t time.Time, close float64) models.AggregateBarModel {
+		return models.AggregateBarModel{T: t, Open: close, High: close, Low: close, Close: close, Volume: 1}
+	}
+	// Friday 14:00 and 15:00 CST, then Sunday 17:00 and 19:00 CDT. The
+	// daily break, Saturday and Sunday morning are not filled; Sunday 18:00
+	// is.
+	bars := []models.AggregateBarModel{
+		bar(utc(3, 7, 20, 0), 1),
+		bar(utc(3, 7, 21, 0), 2),
+		bar(utc(3, 9, 22, 0), 3),
+		bar(utc(3, 10, 0, 0), 4),
+	}
+
+	tests := []struct {
+		gaps  GapMode
+		times []time.Time
+	}{
+		{GapSkip, []time.Time{utc(3, 7, 20, 0), utc(3, 7, 21, 0), utc(3, 9, 22, 0), utc(3, 10, 0, 0)}},
+		{GapForwardFill, []time.Time{utc(3, 7, 20, 0), utc(3, 7, 21, 0), utc(3, 9, 22, 0), utc(3, 9, 23, 0), utc(3, 10, 0, 0)}},
+	}
+	for _, test := range tests {
+		got, err := Bars(bars, Config{Unit: models.AggregateBarUnitHour, UnitNumber: 1, Session: session, Gaps: test.gaps})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(test.times) {
+			t.Fatalf("gap mode %d: got %d bars, want %d: %+v", test.gaps, len(got), len(test.times), got)
+		}
+		for i, want := range test.times {
+			if !got[i].T.Equal(want) {
+				t.Errorf("gap mode %d: bar %d at %s, want %s", test.gaps, i, got[i].T, want)
+			}
+		}
+		if test.gaps == GapForwardFill {
+			if fill := got[3]; fill.Close != 3 || fill.Open != 3 || fill.Volume != 0 {
+				t.Errorf("filled bar %+v, want flat at the previous close without volume", fill)
+			}
+		}
+	}
+}
+
+func TestNewAlignerRejectsInvalidConfig(t *testing.T) {
+	tests := []Config{
+		{Unit: models.AggregateBarUnitMinute, UnitNumber: 0},
+		{Unit: models.AggregateBarUnit(99), UnitNumber: 1},
+		{Unit: models.AggregateBarUnitHour, UnitNumber: 1, Session: Session{Start: 25 * time.Hour}},
+		{Unit: models.AggregateBarUnitHour, UnitNumber: 1, Session: Session{Length: 25 * time.Hour}},
+	}
+	for _, cfg := range tests {
+		if _, err := NewAligner(cfg); err == nil {
+			t.Errorf("%+v accepted", cfg)
+		}
+	}
+}
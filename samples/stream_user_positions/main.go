@@ -7,6 +7,7 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
@@ -63,9 +64,6 @@ func main() {
 		fmt.Printf("Avg Price:    %.2f\n", update.Data.AveragePrice)
 		fmt.Printf("Created At:   %s\n", update.Data.CreationTimestamp.Format("2006-01-02 15:04:05"))
 
-		positionValue := float64(update.Data.Size) * update.Data.AveragePrice
-		fmt.Printf("Position Value: $%.2f\n", positionValue)
-
 		fmt.Println("\n--- Full JSON Structure ---")
 		jsonBytes, err := json.MarshalIndent(update, "", "  ")
 		if err != nil {
@@ -74,14 +72,53 @@ func main() {
 			fmt.Printf("%s\n", string(jsonBytes))
 		}
 
-		fmt.Println("===============================================\n")
+		fmt.Println("===============================================")
+		fmt.Println()
 	})
 
 	userDataWS.SetConnectionHandler(func(state services.ConnectionState) {
-		fmt.Printf("[Connection State Changed] %s at %s\n",
+		fmt.Printf("[Connection State Changed] %v at %s\n",
 			state, time.Now().Format("15:04:05.000"))
 	})
 
+	// Futures P&L is (price - average price) / tick size * tick value * size,
+	// so it needs the contract's tick value and a live price.
+	marketDataWS := client.MarketData
+	book := services.NewPositionBook(client.Position, client.Contract, userDataWS, marketDataWS)
+
+	var quoteMu sync.Mutex
+	quoteContracts := make(map[string]bool)
+	book.OnChange(services.AllContracts, func(pnl services.PositionPnL) {
+		contractID := pnl.Position.ContractID
+
+		quoteMu.Lock()
+		if pnl.Position.Size != 0 && !quoteContracts[contractID] {
+			quoteContracts[contractID] = true
+			go func() {
+				if err := marketDataWS.SubscribeContractQuotes(contractID); err != nil {
+					fmt.Printf("Error subscribing to quotes for %s: %v\n", contractID, err)
+				}
+			}()
+		}
+		quoteMu.Unlock()
+
+		if !pnl.Priced {
+			fmt.Printf("[P&L] %s size %d @ %.2f - waiting for price\n",
+				contractID, pnl.Position.Size, pnl.Position.AveragePrice)
+			return
+		}
+
+		account := book.Account(pnl.Position.AccountID)
+		fmt.Printf("[P&L] %s %s %d @ %.2f mark %.2f: %+.1f ticks, $%+.2f (account $%+.2f)\n",
+			contractID, pnl.Position.Type, pnl.Position.Size, pnl.Position.AveragePrice,
+			pnl.MarkPrice, pnl.Ticks, pnl.UnrealizedPnL, account.UnrealizedPnL)
+	})
+
+	fmt.Println("\nConnecting to market data websocket...")
+	if err := marketDataWS.Connect(ctx); err != nil {
+		log.Fatalf("Failed to connect to market data: %v", err)
+	}
+
 	fmt.Println("\nConnecting to user data websocket...")
 	if err := userDataWS.Connect(ctx); err != nil {
 		log.Fatalf("Failed to connect: %v", err)
@@ -94,6 +131,11 @@ func main() {
 		log.Fatalf("Failed to subscribe to positions: %v", err)
 	}
 	fmt.Println("Successfully subscribed to position updates!")
+
+	if err := book.Start(ctx, account.ID); err != nil {
+		log.Fatalf("Failed to load open positions: %v", err)
+	}
+	defer book.Stop()
 	fmt.Println("\nListening for position updates... (Press Ctrl+C to exit)")
 	fmt.Println("NOTE: Position updates will appear when positions change on this account")
 
@@ -108,6 +150,13 @@ func main() {
 		fmt.Printf("Error unsubscribing from positions: %v\n", err)
 	}
 
+	if err := marketDataWS.UnsubscribeAllContracts(); err != nil {
+		fmt.Printf("Error unsubscribing from quotes: %v\n", err)
+	}
+	if err := marketDataWS.Disconnect(); err != nil {
+		fmt.Printf("Error disconnecting from market data: %v\n", err)
+	}
+
 	if err := userDataWS.Disconnect(); err != nil {
 		fmt.Printf("Error disconnecting: %v\n", err)
 	}
@@ -18,7 +18,7 @@ func NewAccountService(c *client.Client) *AccountService {
 
 func (s *AccountService) SearchAccounts(ctx context.Context, req *models.SearchAccountRequest) (*models.SearchAccountResponse, error) {
 	var resp models.SearchAccountResponse
-	err := s.client.DoJSON(ctx, &client.Request{
+	raw, err := s.client.DoJSONResponse(ctx, &client.Request{
 		Method: http.MethodPost,
 		Path:   "/api/Account/search",
 		Body:   req,
@@ -27,5 +27,9 @@ func (s *AccountService) SearchAccounts(ctx context.Context, req *models.SearchA
 		return nil, err
 	}
 
+	if err := checkResponse(s.client, raw, resp.Success, int(resp.ErrorCode), resp.ErrorMessage, nil); err != nil {
+		return &resp, err
+	}
+
 	return &resp, nil
 }
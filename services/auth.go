@@ -18,10 +18,11 @@ func NewAuthService(c *client.Client) *AuthService {
 
 func (s *AuthService) LoginApp(ctx context.Context, req *models.LoginAppRequest) (*models.LoginResponse, error) {
 	var resp models.LoginResponse
-	err := s.client.DoJSON(ctx, &client.Request{
-		Method: http.MethodPost,
-		Path:   "/api/Auth/loginApp",
-		Body:   req,
+	raw, err := s.client.DoJSONResponse(ctx, &client.Request{
+		Method:          http.MethodPost,
+		Path:            "/api/Auth/loginApp",
+		SkipAuthRefresh: true,
+		Body:            req,
 	}, &resp)
 	if err != nil {
 		return nil, err
@@ -31,15 +32,20 @@ func (s *AuthService) LoginApp(ctx context.Context, req *models.LoginAppRequest)
 		s.client.SetToken(*resp.Token)
 	}
 
+	if err := checkResponse(s.client, raw, resp.Success, int(resp.ErrorCode), resp.ErrorMessage, loginError(resp.ErrorCode)); err != nil {
+		return &resp, err
+	}
+
 	return &resp, nil
 }
 
 func (s *AuthService) LoginKey(ctx context.Context, req *models.LoginApiKeyRequest) (*models.LoginResponse, error) {
 	var resp models.LoginResponse
-	err := s.client.DoJSON(ctx, &client.Request{
-		Method: http.MethodPost,
-		Path:   "/api/Auth/loginKey",
-		Body:   req,
+	raw, err := s.client.DoJSONResponse(ctx, &client.Request{
+		Method:          http.MethodPost,
+		Path:            "/api/Auth/loginKey",
+		SkipAuthRefresh: true,
+		Body:            req,
 	}, &resp)
 	if err != nil {
 		return nil, err
@@ -49,14 +55,19 @@ func (s *AuthService) LoginKey(ctx context.Context, req *models.LoginApiKeyReque
 		s.client.SetToken(*resp.Token)
 	}
 
+	if err := checkResponse(s.client, raw, resp.Success, int(resp.ErrorCode), resp.ErrorMessage, loginError(resp.ErrorCode)); err != nil {
+		return &resp, err
+	}
+
 	return &resp, nil
 }
 
 func (s *AuthService) Logout(ctx context.Context) (*models.LogoutResponse, error) {
 	var resp models.LogoutResponse
-	err := s.client.DoJSON(ctx, &client.Request{
-		Method: http.MethodPost,
-		Path:   "/api/Auth/logout",
+	raw, err := s.client.DoJSONResponse(ctx, &client.Request{
+		Method:          http.MethodPost,
+		Path:            "/api/Auth/logout",
+		SkipAuthRefresh: true,
 	}, &resp)
 	if err != nil {
 		return nil, err
@@ -66,14 +77,19 @@ func (s *AuthService) Logout(ctx context.Context) (*models.LogoutResponse, error
 		s.client.SetToken("")
 	}
 
+	if err := checkResponse(s.client, raw, resp.Success, int(resp.ErrorCode), resp.ErrorMessage, logoutError(resp.ErrorCode)); err != nil {
+		return &resp, err
+	}
+
 	return &resp, nil
 }
 
 func (s *AuthService) Validate(ctx context.Context) (*models.ValidateResponse, error) {
 	var resp models.ValidateResponse
-	err := s.client.DoJSON(ctx, &client.Request{
-		Method: http.MethodPost,
-		Path:   "/api/Auth/validate",
+	raw, err := s.client.DoJSONResponse(ctx, &client.Request{
+		Method:          http.MethodPost,
+		Path:            "/api/Auth/validate",
+		SkipAuthRefresh: true,
 	}, &resp)
 	if err != nil {
 		return nil, err
@@ -83,5 +99,9 @@ func (s *AuthService) Validate(ctx context.Context) (*models.ValidateResponse, e
 		s.client.SetToken(*resp.NewToken)
 	}
 
+	if err := checkResponse(s.client, raw, resp.Success, int(resp.ErrorCode), resp.ErrorMessage, validateError(resp.ErrorCode)); err != nil {
+		return &resp, err
+	}
+
 	return &resp, nil
 }
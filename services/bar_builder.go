@@ -0,0 +1,351 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tradingiq/projectx-client/models"
+	"github.com/tradingiq/projectx-client/resample"
+)
+
+var ErrInvalidBarSpec = errors.New("invalid bar spec")
+
+type BarKind int
+
+const (
+	// BarKindTime closes bars on Unit and UnitNumber boundaries of Session.
+	BarKindTime BarKind = iota
+	// BarKindTick closes a bar every Ticks trades.
+	BarKindTick
+	// BarKindVolume closes a bar every Volume contracts, splitting trades
+	// that straddle two bars.
+	BarKindVolume
+	// BarKindRange closes a bar when a trade would stretch its high-low range
+	// beyond Range.
+	BarKindRange
+)
+
+type BarSpec struct {
+	Kind       BarKind
+	Unit       models.AggregateBarUnit
+	UnitNumber int32
+	Session    resample.Session
+	Ticks      int
+	Volume     int64
+	Range      float64
+	// MaxBars bounds the closed bars kept per contract; zero keeps all.
+	MaxBars int
+}
+
+type tradeKey struct {
+	price  float64
+	volume int
+	side   int
+}
+
+type barSeries struct {
+	bars    []models.AggregateBarModel
+	current models.AggregateBarModel
+	open    bool
+	ticks   int
+	bucket  time.Time
+	end     time.Time
+
+	cutoff time.Time
+	last   time.Time
+	seen   map[tradeKey]int
+}
+
+type barEvent struct {
+	contractID string
+	bar        models.AggregateBarModel
+	closed     bool
+}
+
+// BarBuilder aggregates live trades into bars per contract. Trades older than
+// the newest one seen are dropped, as are trades at that same timestamp that
+// were already received in an earlier batch, so trades replayed after a
+// reconnect are not counted twice.
+type BarBuilder struct {
+	spec    BarSpec
+	aligner *resample.Aligner
+
+	mu     sync.Mutex
+	series map[string]*barSeries
+
+	updated *subscribers[func(string, models.AggregateBarModel)]
+	closed  *subscribers[func(string, models.AggregateBarModel)]
+}
+
+func NewBarBuilder(spec BarSpec) (*BarBuilder, error) {
+	b := &BarBuilder{
+		spec:    spec,
+		series:  make(map[string]*barSeries),
+		updated: newSubscribers[func(string, models.AggregateBarModel)](),
+		closed:  newSubscribers[func(string, models.AggregateBarModel)](),
+	}
+
+	switch spec.Kind {
+	case BarKindTime:
+		aligner, err := resample.NewAligner(resample.Config{
+			Unit:       spec.Unit,
+			UnitNumber: spec.UnitNumber,
+			Session:    spec.Session,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBarSpec, err)
+		}
+		b.aligner = aligner
+	case BarKindTick:
+		if spec.Ticks <= 0 {
+			return nil, fmt.Errorf("%w: ticks must be positive, got %d", ErrInvalidBarSpec, spec.Ticks)
+		}
+	case BarKindVolume:
+		if spec.Volume <= 0 {
+			return nil, fmt.Errorf("%w: volume must be positive, got %d", ErrInvalidBarSpec, spec.Volume)
+		}
+	case BarKindRange:
+		if spec.Range <= 0 {
+			return nil, fmt.Errorf("%w: range must be positive, got %v", ErrInvalidBarSpec, spec.Range)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown kind %d", ErrInvalidBarSpec, spec.Kind)
+	}
+	return b, nil
+}
+
+// Seed loads history for contractID, replacing any bars built so far. Seed
+// time bars with completed bars from HistoryService.GetBars, in any order;
+// live trades then start at the end of the newest seeded bar. For other kinds
+// live trades start at the time of the newest seeded bar.
+func (b *BarBuilder) Seed(contractID string, bars []models.AggregateBarModel) {
+	bars = append([]models.AggregateBarModel(nil), bars...)
+	sort.SliceStable(bars, func(i, j int) bool {
+		return bars[i].T.Before(bars[j].T)
+	})
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	series := &barSeries{
+		bars: bars,
+		seen: make(map[tradeKey]int),
+	}
+	if len(bars) > 0 {
+		last := bars[len(bars)-1].T
+		series.cutoff = last
+		if b.aligner != nil {
+			series.cutoff = b.aligner.Next(b.aligner.Bucket(last))
+		}
+	}
+	b.trim(series)
+	b.series[contractID] = series
+}
+
+// HandleTrade can be passed directly to MarketDataWebSocketService.OnTrade or
+// SetTradeHandler. Trades within a batch may arrive in any order.
+func (b *BarBuilder) HandleTrade(contractID string, trades models.TradeData) {
+	trades = append(models.TradeData(nil), trades...)
+	sort.SliceStable(trades, func(i, j int) bool {
+		return trades[i].Timestamp.Before(trades[j].Timestamp)
+	})
+
+	b.mu.Lock()
+	series, ok := b.series[contractID]
+	if !ok {
+		series = &barSeries{seen: make(map[tradeKey]int)}
+		b.series[contractID] = series
+	}
+
+	var events []barEvent
+	startLast := series.last
+	previous := series.seen
+	matched := make(map[tradeKey]int)
+	added := make(map[tradeKey]int)
+	for _, trade := range trades {
+		ts := trade.Timestamp
+		if ts.Before(series.cutoff) || ts.Before(series.last) {
+			continue
+		}
+
+		key := tradeKey{price: trade.Price, volume: trade.Volume, side: trade.Type}
+		if ts.Equal(startLast) && matched[key] < previous[key] {
+			matched[key]++
+			continue
+		}
+		if ts.After(series.last) {
+			series.last = ts
+			added = make(map[tradeKey]int)
+		}
+		added[key]++
+
+		events = b.apply(contractID, series, trade, events)
+	}
+
+	if series.last.Equal(startLast) {
+		for key, n := range added {
+			previous[key] += n
+		}
+	} else {
+		series.seen = added
+	}
+	b.mu.Unlock()
+
+	b.dispatch(events)
+}
+
+// CloseElapsed closes time bars whose interval ended at or before now, for
+// contracts that have gone quiet.
+func (b *BarBuilder) CloseElapsed(now time.Time) {
+	if b.aligner == nil {
+		return
+	}
+
+	b.mu.Lock()
+	var events []barEvent
+	for contractID, series := range b.series {
+		if series.open && !series.end.After(now) {
+			events = b.closeBar(contractID, series, events)
+		}
+	}
+	b.mu.Unlock()
+
+	b.dispatch(events)
+}
+
+func (b *BarBuilder) apply(contractID string, series *barSeries, trade models.Trade, events []barEvent) []barEvent {
+	switch b.spec.Kind {
+	case BarKindTime:
+		bucket := b.aligner.Bucket(trade.Timestamp)
+		if series.open && !bucket.Equal(series.bucket) {
+			events = b.closeBar(contractID, series, events)
+		}
+		if !series.open {
+			series.bucket = bucket
+			series.end = b.aligner.Next(bucket)
+			b.openBar(series, bucket.In(trade.Timestamp.Location()), trade.Price)
+		}
+		b.addTrade(series, trade.Price, int64(trade.Volume))
+		events = append(events, barEvent{contractID: contractID, bar: series.current})
+
+	case BarKindTick:
+		if !series.open {
+			b.openBar(series, trade.Timestamp, trade.Price)
+		}
+		b.addTrade(series, trade.Price, int64(trade.Volume))
+		events = append(events, barEvent{contractID: contractID, bar: series.current})
+		if series.ticks >= b.spec.Ticks {
+			events = b.closeBar(contractID, series, events)
+		}
+
+	case BarKindVolume:
+		remaining := int64(trade.Volume)
+		for remaining > 0 {
+			if !series.open {
+				b.openBar(series, trade.Timestamp, trade.Price)
+			}
+			fill := min(remaining, b.spec.Volume-series.current.Volume)
+			b.addTrade(series, trade.Price, fill)
+			remaining -= fill
+			events = append(events, barEvent{contractID: contractID, bar: series.current})
+			if series.current.Volume >= b.spec.Volume {
+				events = b.closeBar(contractID, series, events)
+			}
+		}
+
+	case BarKindRange:
+		if series.open {
+			high := max(series.current.High, trade.Price)
+			low := min(series.current.Low, trade.Price)
+			if high-low > b.spec.Range {
+				events = b.closeBar(contractID, series, events)
+			}
+		}
+		if !series.open {
+			b.openBar(series, trade.Timestamp, trade.Price)
+		}
+		b.addTrade(series, trade.Price, int64(trade.Volume))
+		events = append(events, barEvent{contractID: contractID, bar: series.current})
+	}
+	return events
+}
+
+func (b *BarBuilder) openBar(series *barSeries, t time.Time, price float64) {
+	series.current = models.AggregateBarModel{T: t, Open: price, High: price, Low: price, Close: price}
+	series.ticks = 0
+	series.open = true
+}
+
+func (b *BarBuilder) addTrade(series *barSeries, price float64, volume int64) {
+	series.current.High = max(series.current.High, price)
+	series.current.Low = min(series.current.Low, price)
+	series.current.Close = price
+	series.current.Volume += volume
+	series.ticks++
+}
+
+func (b *BarBuilder) closeBar(contractID string, series *barSeries, events []barEvent) []barEvent {
+	series.bars = append(series.bars, series.current)
+	series.open = false
+	b.trim(series)
+	return append(events, barEvent{contractID: contractID, bar: series.current, closed: true})
+}
+
+func (b *BarBuilder) trim(series *barSeries) {
+	if b.spec.MaxBars > 0 && len(series.bars) > b.spec.MaxBars {
+		series.bars = append([]models.AggregateBarModel(nil), series.bars[len(series.bars)-b.spec.MaxBars:]...)
+	}
+}
+
+func (b *BarBuilder) dispatch(events []barEvent) {
+	for _, event := range events {
+		handlers := b.updated
+		if event.closed {
+			handlers = b.closed
+		}
+		for _, handler := range handlers.get(event.contractID) {
+			handler(event.contractID, event.bar)
+		}
+	}
+}
+
+// Bars returns the closed bars of contractID, seeded history first.
+func (b *BarBuilder) Bars(contractID string) []models.AggregateBarModel {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	series, ok := b.series[contractID]
+	if !ok {
+		return nil
+	}
+	return append([]models.AggregateBarModel(nil), series.bars...)
+}
+
+// Current returns the bar still forming for contractID.
+func (b *BarBuilder) Current(contractID string) (models.AggregateBarModel, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	series, ok := b.series[contractID]
+	if !ok || !series.open {
+		return models.AggregateBarModel{}, false
+	}
+	return series.current, true
+}
+
+// OnBarUpdate registers a callback for every trade added to the forming bar
+// of contractID, or of every contract with AllContracts, and returns a
+// function that removes it.
+func (b *BarBuilder) OnBarUpdate(contractID string, handler func(contractID string, bar models.AggregateBarModel)) func() {
+	return b.updated.add(contractID, handler)
+}
+
+// OnBarClose registers a callback for bars of contractID, or of every
+// contract with AllContracts, as they close, and returns a function that
+// removes it.
+func (b *BarBuilder) OnBarClose(contractID string, handler func(contractID string, bar models.AggregateBarModel)) func() {
+	return b.closed.add(contractID, handler)
+}
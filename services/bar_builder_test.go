@@ -0,0 +1,132 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tradingiq/projectx-client/models"
+)
+
+func newMinuteBuilder(t *testing.T) *BarBuilder {
+	t.Helper()
+	builder, err := NewBarBuilder(BarSpec{Kind: BarKindTime, Unit: models.AggregateBarUnitMinute, UnitNumber: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return builder
+}
+
+func trade(at time.Duration, price float64, volume int) models.Trade {
+	return models.Trade{Timestamp: historyTestStart.Add(at), Price: price, Volume: volume}
+}
+
+func TestBarBuilderDeduplicatesReplayedTrades(t *testing.T) {
+	tests := []struct {
+		name    string
+		batches []models.TradeData
+		volume  int64
+	}{
+		{
+			name: "batch replayed after reconnect",
+			batches: []models.TradeData{
+				{trade(time.Second, 100, 1), trade(2*time.Second, 101, 2)},
+				{trade(time.Second, 100, 1), trade(2*time.Second, 101, 2)},
+			},
+			volume: 3,
+		},
+		{
+			name: "new trade at the last timestamp",
+			batches: []models.TradeData{
+				{trade(2*time.Second, 101, 2)},
+				{trade(2*time.Second, 101, 2), trade(2*time.Second, 101, 2)},
+			},
+			volume: 4,
+		},
+		{
+			name: "identical trades replayed in part",
+			batches: []models.TradeData{
+				{trade(2*time.Second, 101, 2), trade(2*time.Second, 101, 2)},
+				{trade(2*time.Second, 101, 2)},
+			},
+			volume: 4,
+		},
+		{
+			name: "older trade dropped",
+			batches: []models.TradeData{
+				{trade(2*time.Second, 101, 2)},
+				{trade(time.Second, 100, 1), trade(3*time.Second, 102, 5)},
+			},
+			volume: 7,
+		},
+		{
+			name: "batch in any order",
+			batches: []models.TradeData{
+				{trade(3*time.Second, 102, 5), trade(time.Second, 100, 1)},
+			},
+			volume: 6,
+		},
+	}
+	for _, test := range tests {
+		builder := newMinuteBuilder(t)
+		for _, batch := range test.batches {
+			builder.HandleTrade(riskTestContract, batch)
+		}
+		bar, ok := builder.Current(riskTestContract)
+		if !ok {
+			t.Fatalf("%s: no bar forming", test.name)
+		}
+		if bar.Volume != test.volume {
+			t.Errorf("%s: volume %d, want %d", test.name, bar.Volume, test.volume)
+		}
+	}
+}
+
+func TestBarBuilderSeedsBeforeLiveTrades(t *testing.T) {
+	seeded := []models.AggregateBarModel{
+		{T: historyTestStart.Add(2 * time.Minute), Close: 102, Volume: 30},
+		{T: historyTestStart, Close: 100, Volume: 10},
+		{T: historyTestStart.Add(time.Minute), Close: 101, Volume: 20},
+	}
+
+	builder := newMinuteBuilder(t)
+	builder.Seed(riskTestContract, seeded)
+
+	var closed []models.AggregateBarModel
+	builder.OnBarClose(AllContracts, func(_ string, bar models.AggregateBarModel) {
+		closed = append(closed, bar)
+	})
+
+	// Trades inside the newest seeded bar are already counted in it.
+	builder.HandleTrade(riskTestContract, models.TradeData{
+		trade(2*time.Minute+30*time.Second, 102, 9),
+		trade(3*time.Minute+10*time.Second, 103, 1),
+	})
+	builder.HandleTrade(riskTestContract, models.TradeData{
+		trade(3*time.Minute+20*time.Second, 104, 2),
+		trade(4*time.Minute, 105, 3),
+	})
+
+	bars := builder.Bars(riskTestContract)
+	want := []models.AggregateBarModel{
+		{T: historyTestStart, Close: 100, Volume: 10},
+		{T: historyTestStart.Add(time.Minute), Close: 101, Volume: 20},
+		{T: historyTestStart.Add(2 * time.Minute), Close: 102, Volume: 30},
+		{T: historyTestStart.Add(3 * time.Minute), Open: 103, High: 104, Low: 103, Close: 104, Volume: 3},
+	}
+	if len(bars) != len(want) {
+		t.Fatalf("got %d bars, want %d: %+v", len(bars), len(want), bars)
+	}
+	for i := range want {
+		if bars[i] != want[i] {
+			t.Errorf("bar %d: %+v, want %+v", i, bars[i], want[i])
+		}
+	}
+	if len(closed) != 1 || closed[0] != want[3] {
+		t.Errorf("closed %+v, want only the first live bar", closed)
+	}
+
+	current, ok := builder.Current(riskTestContract)
+	if !ok || !current.T.Equal(historyTestStart.Add(4*time.Minute)) || current.Volume != 3 {
+		t.Errorf("current bar %+v, want the 4th minute with volume 3", current)
+	}
+}
@@ -0,0 +1,574 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tradingiq/projectx-client/models"
+)
+
+const (
+	barCacheMagic     = "PXBC"
+	barCacheVersion   = 1
+	barCacheExtension = ".bars"
+
+	// Encoded sizes: a range is two int64 timestamps, a bar a timestamp,
+	// four float64 prices and an int64 volume.
+	barCacheRangeSize = 16
+	barCacheBarSize   = 48
+)
+
+var ErrBarCacheCorrupt = errors.New("bar cache file corrupt")
+
+type BarCacheKey struct {
+	ContractID string
+	Unit       models.AggregateBarUnit
+	UnitNumber int32
+	Live       bool
+}
+
+func (k BarCacheKey) fileName() string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, k.ContractID)
+	name = fmt.Sprintf("%s_%d_%d", name, k.Unit, k.UnitNumber)
+	if k.Live {
+		name += "_live"
+	}
+	return name + barCacheExtension
+}
+
+// BarRange is a span of bar start times [Start, End) known to be complete.
+type BarRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+type BarCacheEntry struct {
+	Key     BarCacheKey
+	Bars    int
+	First   time.Time
+	Last    time.Time
+	Ranges  []BarRange
+	Size    int64
+	ModTime time.Time
+}
+
+type barCacheFile struct {
+	key    BarCacheKey
+	ranges []BarRange
+	bars   []models.AggregateBarModel
+}
+
+// BarCache keeps completed bars on disk in front of HistoryService.GetBars.
+// Each contract, unit, unit number and live flag is stored in its own compact
+// binary file together with the time ranges it covers, and only the missing
+// ranges are fetched. The bar still forming is never cached; when
+// IncludePartialBar is set, the tail of the range is always fetched live.
+type BarCache struct {
+	history *HistoryService
+	dir     string
+	opts    []BarRangeOption
+	now     func() time.Time
+
+	mu    sync.Mutex
+	locks map[BarCacheKey]*sync.Mutex
+}
+
+// NewBarCache stores files in dir, which is created if needed. opts apply to
+// the range requests used to fill gaps.
+func NewBarCache(history *HistoryService, dir string, opts ...BarRangeOption) (*BarCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create bar cache directory: %w", err)
+	}
+	return &BarCache{
+		history: history,
+		dir:     dir,
+		opts:    opts,
+		now:     time.Now,
+		locks:   make(map[BarCacheKey]*sync.Mutex),
+	}, nil
+}
+
+// GetBars returns the bars starting in [StartTime, EndTime) in ascending time
+// order. When Limit is set only the latest Limit bars are returned.
+func (c *BarCache) GetBars(ctx context.Context, req *models.RetrieveBarRequest) (*models.RetrieveBarResponse, error) {
+	duration, err := BarDuration(req.Unit, req.UnitNumber)
+	if err != nil {
+		return nil, err
+	}
+	if req.Unit == models.AggregateBarUnitMonth {
+		duration = 31 * 24 * time.Hour * time.Duration(req.UnitNumber)
+	}
+
+	key := BarCacheKey{ContractID: req.ContractID, Unit: req.Unit, UnitNumber: req.UnitNumber, Live: req.Live}
+	lock := c.lock(key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	file, err := c.load(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Bars starting before completeBefore had closed when the request began.
+	completeBefore := c.now().Add(-duration)
+	cacheEnd := req.EndTime
+	if completeBefore.Before(cacheEnd) {
+		cacheEnd = completeBefore
+	}
+
+	changed := false
+	for _, missing := range missingBarRanges(file.ranges, req.StartTime, cacheEnd) {
+		bars, err := c.fetch(ctx, req, missing, false)
+		if err != nil {
+			return nil, err
+		}
+		file.bars = mergeBars(file.bars, bars, missing)
+		file.ranges = addBarRange(file.ranges, missing)
+		changed = true
+	}
+	if changed {
+		if err := c.save(file); err != nil {
+			return nil, err
+		}
+	}
+
+	result := barsBetween(file.bars, req.StartTime, cacheEnd)
+	if cacheEnd.Before(req.EndTime) {
+		tailStart := cacheEnd
+		if tailStart.Before(req.StartTime) {
+			tailStart = req.StartTime
+		}
+		tail, err := c.fetch(ctx, req, BarRange{Start: tailStart, End: req.EndTime}, req.IncludePartialBar)
+		if err != nil {
+			return nil, err
+		}
+		for _, bar := range tail {
+			if !bar.T.Before(tailStart) && (bar.T.Before(req.EndTime) || req.IncludePartialBar) {
+				result = append(result, bar)
+			}
+		}
+	}
+
+	if req.Limit > 0 && len(result) > int(req.Limit) {
+		result = result[len(result)-int(req.Limit):]
+	}
+	return &models.RetrieveBarResponse{Success: true, Bars: result}, nil
+}
+
+func (c *BarCache) fetch(ctx context.Context, req *models.RetrieveBarRequest, r BarRange, partial bool) ([]models.AggregateBarModel, error) {
+	rangeReq := *req
+	rangeReq.StartTime = r.Start
+	rangeReq.EndTime = r.End
+	rangeReq.Limit = 0
+	rangeReq.IncludePartialBar = partial
+	return c.history.GetBarRange(ctx, &rangeReq, c.opts...)
+}
+
+// Entries describes every cache file.
+func (c *BarCache) Entries() ([]BarCacheEntry, error) {
+	paths, err := filepath.Glob(filepath.Join(c.dir, "*"+barCacheExtension))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]BarCacheEntry, 0, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		file, err := readBarCacheFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		entry := BarCacheEntry{
+			Key:     file.key,
+			Bars:    len(file.bars),
+			Ranges:  file.ranges,
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		}
+		if len(file.bars) > 0 {
+			entry.First = file.bars[0].T
+			entry.Last = file.bars[len(file.bars)-1].T
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key.fileName() < entries[j].Key.fileName()
+	})
+	return entries, nil
+}
+
+// Prune drops bars and coverage before cutoff from every entry, removing
+// entries that become empty.
+func (c *BarCache) Prune(cutoff time.Time) error {
+	entries, err := c.Entries()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, entry := range entries {
+		if err := c.prune(entry.Key, cutoff); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *BarCache) prune(key BarCacheKey, cutoff time.Time) error {
+	lock := c.lock(key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	file, err := c.load(key)
+	if err != nil {
+		return err
+	}
+
+	from := sort.Search(len(file.bars), func(i int) bool {
+		return !file.bars[i].T.Before(cutoff)
+	})
+	file.bars = file.bars[from:]
+	var ranges []BarRange
+	for _, r := range file.ranges {
+		if !r.End.After(cutoff) {
+			continue
+		}
+		if r.Start.Before(cutoff) {
+			r.Start = cutoff
+		}
+		ranges = append(ranges, r)
+	}
+	file.ranges = ranges
+
+	if len(file.ranges) == 0 {
+		return c.removeLocked(key)
+	}
+	return c.save(file)
+}
+
+// Remove deletes the cache file of key.
+func (c *BarCache) Remove(key BarCacheKey) error {
+	lock := c.lock(key)
+	lock.Lock()
+	defer lock.Unlock()
+	return c.removeLocked(key)
+}
+
+func (c *BarCache) removeLocked(key BarCacheKey) error {
+	err := os.Remove(filepath.Join(c.dir, key.fileName()))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove bar cache file: %w", err)
+	}
+	return nil
+}
+
+func (c *BarCache) lock(key BarCacheKey) *sync.Mutex {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	lock, ok := c.locks[key]
+	if !ok {
+		lock = &sync.Mutex{}
+		c.locks[key] = lock
+	}
+	return lock
+}
+
+func (c *BarCache) load(key BarCacheKey) (*barCacheFile, error) {
+	file, err := readBarCacheFile(filepath.Join(c.dir, key.fileName()))
+	if errors.Is(err, os.ErrNotExist) {
+		return &barCacheFile{key: key}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+func (c *BarCache) save(file *barCacheFile) error {
+	path := filepath.Join(c.dir, file.key.fileName())
+	tmp, err := os.CreateTemp(c.dir, file.key.fileName()+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write bar cache file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	if err := writeBarCacheFile(w, file); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write bar cache file: %w", err)
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write bar cache file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write bar cache file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write bar cache file: %w", err)
+	}
+	return nil
+}
+
+// The file layout is little endian: magic, version, contract id length and
+// bytes, unit, unit number, live flag, range count, ranges as pairs of unix
+// nanoseconds, bar count and bars as time, open, high, low, close and volume.
+func writeBarCacheFile(w io.Writer, file *barCacheFile) error {
+	live := uint8(0)
+	if file.key.Live {
+		live = 1
+	}
+
+	header := []interface{}{
+		[]byte(barCacheMagic),
+		uint8(barCacheVersion),
+		uint16(len(file.key.ContractID)),
+		[]byte(file.key.ContractID),
+		uint8(file.key.Unit),
+		file.key.UnitNumber,
+		live,
+		uint32(len(file.ranges)),
+	}
+	for _, v := range header {
+		if err := binary.Write(w, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+	for _, r := range file.ranges {
+		if err := binary.Write(w, binary.LittleEndian, [2]int64{r.Start.UnixNano(), r.End.UnixNano()}); err != nil {
+			return err
+		}
+	}
+
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(file.bars))); err != nil {
+		return err
+	}
+	for _, bar := range file.bars {
+		record := struct {
+			T                      int64
+			Open, High, Low, Close float64
+			Volume                 int64
+		}{bar.T.UnixNano(), bar.Open, bar.High, bar.Low, bar.Close, bar.Volume}
+		if err := binary.Write(w, binary.LittleEndian, record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readBarCacheFile(path string) (*barCacheFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(f)
+
+	// remaining is the number of bytes not read yet, so that counts from the
+	// header can be checked before anything is allocated for them.
+	remaining := info.Size()
+	read := func(v interface{}) error {
+		if err := binary.Read(r, binary.LittleEndian, v); err != nil {
+			return fmt.Errorf("%w: %v", ErrBarCacheCorrupt, err)
+		}
+		remaining -= int64(binary.Size(v))
+		return nil
+	}
+	fits := func(count uint32, size int64, what string) error {
+		if int64(count)*size > remaining {
+			return fmt.Errorf("%w: %d %s do not fit in the remaining %d bytes", ErrBarCacheCorrupt, count, what, remaining)
+		}
+		return nil
+	}
+
+	var magic [4]byte
+	var version uint8
+	if err := read(&magic); err != nil {
+		return nil, err
+	}
+	if err := read(&version); err != nil {
+		return nil, err
+	}
+	if string(magic[:]) != barCacheMagic || version != barCacheVersion {
+		return nil, fmt.Errorf("%w: unknown format %q version %d", ErrBarCacheCorrupt, magic[:], version)
+	}
+
+	var idLen uint16
+	if err := read(&idLen); err != nil {
+		return nil, err
+	}
+	if err := fits(uint32(idLen), 1, "contract id bytes"); err != nil {
+		return nil, err
+	}
+	id := make([]byte, idLen)
+	if err := read(id); err != nil {
+		return nil, err
+	}
+
+	var unit, live uint8
+	var unitNumber int32
+	var rangeCount uint32
+	for _, v := range []interface{}{&unit, &unitNumber, &live, &rangeCount} {
+		if err := read(v); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := fits(rangeCount, barCacheRangeSize, "ranges"); err != nil {
+		return nil, err
+	}
+
+	file := &barCacheFile{
+		key: BarCacheKey{
+			ContractID: string(id),
+			Unit:       models.AggregateBarUnit(unit),
+			UnitNumber: unitNumber,
+			Live:       live == 1,
+		},
+		ranges: make([]BarRange, 0, rangeCount),
+	}
+	for i := uint32(0); i < rangeCount; i++ {
+		var r [2]int64
+		if err := read(&r); err != nil {
+			return nil, err
+		}
+		file.ranges = append(file.ranges, BarRange{Start: time.Unix(0, r[0]).UTC(), End: time.Unix(0, r[1]).UTC()})
+	}
+
+	var barCount uint32
+	if err := read(&barCount); err != nil {
+		return nil, err
+	}
+	if err := fits(barCount, barCacheBarSize, "bars"); err != nil {
+		return nil, err
+	}
+	file.bars = make([]models.AggregateBarModel, 0, barCount)
+	for i := uint32(0); i < barCount; i++ {
+		var record struct {
+			T                      int64
+			Open, High, Low, Close float64
+			Volume                 int64
+		}
+		if err := read(&record); err != nil {
+			return nil, err
+		}
+		file.bars = append(file.bars, models.AggregateBarModel{
+			T:      time.Unix(0, record.T).UTC(),
+			Open:   record.Open,
+			High:   record.High,
+			Low:    record.Low,
+			Close:  record.Close,
+			Volume: record.Volume,
+		})
+	}
+
+	return file, nil
+}
+
+// missingBarRanges returns the parts of [start, end) not covered by ranges,
+// which must be sorted and non-overlapping.
+func missingBarRanges(ranges []BarRange, start, end time.Time) []BarRange {
+	var missing []BarRange
+	cursor := start
+	for _, r := range ranges {
+		if !cursor.Before(end) {
+			break
+		}
+		if !r.End.After(cursor) {
+			continue
+		}
+		if r.Start.After(cursor) {
+			gapEnd := r.Start
+			if gapEnd.After(end) {
+				gapEnd = end
+			}
+			missing = append(missing, BarRange{Start: cursor, End: gapEnd})
+		}
+		cursor = r.End
+	}
+	if cursor.Before(end) {
+		missing = append(missing, BarRange{Start: cursor, End: end})
+	}
+	return missing
+}
+
+// addBarRange inserts r and merges overlapping or touching ranges.
+func addBarRange(ranges []BarRange, r BarRange) []BarRange {
+	ranges = append(ranges, r)
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].Start.Before(ranges[j].Start)
+	})
+
+	merged := ranges[:1]
+	for _, next := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if next.Start.After(last.End) {
+			merged = append(merged, next)
+			continue
+		}
+		if next.End.After(last.End) {
+			last.End = next.End
+		}
+	}
+	return merged
+}
+
+// mergeBars replaces the bars of existing inside r with fetched.
+func mergeBars(existing, fetched []models.AggregateBarModel, r BarRange) []models.AggregateBarModel {
+	result := make([]models.AggregateBarModel, 0, len(existing)+len(fetched))
+	for _, bar := range existing {
+		if bar.T.Before(r.Start) || !bar.T.Before(r.End) {
+			result = append(result, bar)
+		}
+	}
+	for _, bar := range fetched {
+		if !bar.T.Before(r.Start) && bar.T.Before(r.End) {
+			result = append(result, bar)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].T.Before(result[j].T)
+	})
+	return result
+}
+
+// barsBetween returns the bars starting in [start, end) of sorted bars.
+func barsBetween(bars []models.AggregateBarModel, start, end time.Time) []models.AggregateBarModel {
+	from := sort.Search(len(bars), func(i int) bool {
+		return !bars[i].T.Before(start)
+	})
+	to := sort.Search(len(bars), func(i int) bool {
+		return !bars[i].T.Before(end)
+	})
+	if to < from {
+		to = from
+	}
+	return append([]models.AggregateBarModel(nil), bars[from:to]...)
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tradingiq/projectx-client/models"
+)
+
+func testBarCacheFile() *barCacheFile {
+	file := &barCacheFile{
+		key:    BarCacheKey{ContractID: riskTestContract, Unit: models.AggregateBarUnitMinute, UnitNumber: 1},
+		ranges: []BarRange{{Start: historyTestStart, End: historyTestStart.Add(3 * time.Minute)}},
+	}
+	for i := 0; i < 3; i++ {
+		file.bars = append(file.bars, models.AggregateBarModel{
+			T:      historyTestStart.Add(time.Duration(i) * time.Minute),
+			Open:   5000 + float64(i),
+			High:   5001 + float64(i),
+			Low:    4999 + float64(i),
+			Close:  5000.5 + float64(i),
+			Volume: int64(100 * (i + 1)),
+		})
+	}
+	return file
+}
+
+func encodeBarCacheFile(t *testing.T, file *barCacheFile) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := writeBarCacheFile(&buf, file); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestBarCacheFileRoundTrip(t *testing.T) {
+	want := testBarCacheFile()
+	path := filepath.Join(t.TempDir(), want.key.fileName())
+	if err := os.WriteFile(path, encodeBarCacheFile(t, want), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := readBarCacheFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("read %+v\nwant %+v", got, want)
+	}
+}
+
+func TestBarCacheFileCorrupt(t *testing.T) {
+	data := encodeBarCacheFile(t, testBarCacheFile())
+	// Offsets of the counts in the header.
+	idLenAt := 5
+	rangeCountAt := idLenAt + 2 + len(riskTestContract) + 1 + 4 + 1
+	barCountAt := rangeCountAt + 4 + barCacheRangeSize
+
+	patch := func(at int, v interface{}) []byte {
+		patched := append([]byte(nil), data...)
+		var buf bytes.Buffer
+		binary.Write(&buf, binary.LittleEndian, v)
+		copy(patched[at:], buf.Bytes())
+		return patched
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"bad magic", append([]byte("PXBD"), data[4:]...)},
+		{"unknown version", patch(4, uint8(barCacheVersion+1))},
+		{"truncated header", data[:idLenAt+3]},
+		{"truncated bars", data[:len(data)-1]},
+		{"contract id longer than file", patch(idLenAt, uint16(0xffff))},
+		{"range count larger than file", patch(rangeCountAt, uint32(0xffffffff))},
+		{"range count one too many", patch(rangeCountAt, uint32(2))},
+		{"bar count larger than file", patch(barCountAt, uint32(0xffffffff))},
+		{"bar count one too many", patch(barCountAt, uint32(4))},
+	}
+	for _, test := range tests {
+		path := filepath.Join(t.TempDir(), "corrupt"+barCacheExtension)
+		if err := os.WriteFile(path, test.data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := readBarCacheFile(path); !errors.Is(err, ErrBarCacheCorrupt) {
+			t.Errorf("%s: error %v, want ErrBarCacheCorrupt", test.name, err)
+		}
+	}
+}
+
+func TestBarCacheFetchesOnlyMissingRanges(t *testing.T) {
+	api, c := newTestAPI(t)
+	serveMinuteBars(api)
+
+	cache, err := NewBarCache(NewHistoryService(c), t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.now = func() time.Time { return historyTestStart.Add(24 * time.Hour) }
+
+	steps := []struct {
+		from, to int
+		requests int
+	}{
+		{0, 30, 1},
+		{0, 30, 0},
+		{10, 20, 0},
+		{20, 45, 1},
+		{0, 45, 0},
+	}
+	for _, step := range steps {
+		before := len(api.bodies("/api/History/retrieveBars"))
+		req := minuteBars(historyTestStart.Add(time.Duration(step.from)*time.Minute), step.to-step.from)
+		resp, err := cache.GetBars(context.Background(), &req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n := len(api.bodies("/api/History/retrieveBars")) - before; n != step.requests {
+			t.Errorf("bars %d to %d: %d requests, want %d", step.from, step.to, n, step.requests)
+		}
+		if len(resp.Bars) != step.to-step.from {
+			t.Errorf("bars %d to %d: got %d bars", step.from, step.to, len(resp.Bars))
+		}
+		for i, bar := range resp.Bars {
+			if want := req.StartTime.Add(time.Duration(i) * time.Minute); !bar.T.Equal(want) {
+				t.Errorf("bars %d to %d: bar %d at %s, want %s", step.from, step.to, i, bar.T, want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,446 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/tradingiq/projectx-client/models"
+)
+
+var (
+	ErrInvalidBracket          = errors.New("invalid bracket order")
+	ErrBracketPositionMismatch = errors.New("position does not match bracket entry")
+)
+
+type BracketLegState int
+
+const (
+	// BracketLegNotPlaced means the leg was never sent, usually because an
+	// earlier leg failed.
+	BracketLegNotPlaced BracketLegState = iota
+	BracketLegPlaced
+	BracketLegRejected
+	// BracketLegCancelled means the leg was placed and then cancelled because
+	// another leg failed.
+	BracketLegCancelled
+	// BracketLegCancelFailed means the leg was placed but could not be
+	// cancelled after another leg failed; it is still working.
+	BracketLegCancelFailed
+	// BracketLegClosed means the market entry had filled and its position was
+	// closed because a protective leg failed.
+	BracketLegClosed
+	// BracketLegCloseFailed means the market entry may have filled but its
+	// position could not be closed, either because the close failed or
+	// because the position did not match the entry; it may be open without
+	// protection.
+	BracketLegCloseFailed
+)
+
+func (s BracketLegState) String() string {
+	switch s {
+	case BracketLegNotPlaced:
+		return "NOT_PLACED"
+	case BracketLegPlaced:
+		return "PLACED"
+	case BracketLegRejected:
+		return "REJECTED"
+	case BracketLegCancelled:
+		return "CANCELLED"
+	case BracketLegCancelFailed:
+		return "CANCEL_FAILED"
+	case BracketLegClosed:
+		return "CLOSED"
+	case BracketLegCloseFailed:
+		return "CLOSE_FAILED"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// BracketTarget is the distance of a protective leg from the reference price,
+// either in ticks or as an absolute price. Price takes precedence when set.
+type BracketTarget struct {
+	Ticks float64
+	Price *float64
+}
+
+func (t BracketTarget) isSet() bool {
+	return t.Price != nil || t.Ticks > 0
+}
+
+type BracketOrderRequest struct {
+	AccountID  int32
+	ContractID string
+	Side       models.OrderSide
+	Size       int32
+	// EntryType is Market, Limit or Stop. EntryPrice is required for Limit
+	// and Stop entries and is the reference price for tick targets.
+	EntryType  models.OrderType
+	EntryPrice *float64
+	// ReferencePrice is used for tick targets of market entries, e.g. the
+	// last traded price.
+	ReferencePrice *float64
+	StopLoss       BracketTarget
+	TakeProfit     BracketTarget
+	// TickSize is looked up from the contract when zero and tick targets are
+	// used.
+	TickSize float64
+	// CustomTag is applied to the entry; the legs get "-sl" and "-tp"
+	// suffixes.
+	CustomTag *string
+	// OCO groups the stop loss and take profit once both are placed, so that
+	// a fill of one cancels or reduces the other. Without it the exits are
+	// only linked to the entry and the caller must cancel the remaining one.
+	OCO *OCOManager
+}
+
+// BracketService places bracket orders. It needs the position service to
+// close a filled market entry when a protective leg fails, and the contract
+// service to look up tick sizes.
+type BracketService struct {
+	orders    *OrderService
+	positions *PositionService
+	contracts *ContractService
+}
+
+func NewBracketService(orders *OrderService, positions *PositionService, contracts *ContractService) *BracketService {
+	return &BracketService{
+		orders:    orders,
+		positions: positions,
+		contracts: contracts,
+	}
+}
+
+type BracketLeg struct {
+	OrderID *int32
+	Type    models.OrderType
+	Side    models.OrderSide
+	Price   *float64
+	State   BracketLegState
+	Err     error
+}
+
+type BracketOrderResult struct {
+	Entry      BracketLeg
+	StopLoss   BracketLeg
+	TakeProfit BracketLeg
+	// OCOGroupID is the group of the exits when req.OCO was set.
+	OCOGroupID string
+}
+
+// PlaceBracketOrder places an entry order followed by a stop loss and take
+// profit linked to it through LinkedOrderID, and groups the exits in req.OCO
+// when set. If the entry is rejected nothing else is sent; if a protective
+// leg fails, the exits already placed are cancelled, as is a limit or stop
+// entry. A market entry has filled by then, so its position is closed
+// instead. The result always reports the state of all three legs, also when
+// an error is returned. If only the grouping fails, both exits stay working
+// and the error is returned with the result.
+func (s *BracketService) PlaceBracketOrder(ctx context.Context, req *BracketOrderRequest) (*BracketOrderResult, error) {
+	exit := models.OrderSideAsk
+	if req.Side == models.OrderSideAsk {
+		exit = models.OrderSideBid
+	}
+
+	result := &BracketOrderResult{
+		Entry:      BracketLeg{Type: req.EntryType, Side: req.Side, Price: req.EntryPrice},
+		StopLoss:   BracketLeg{Type: models.OrderTypeStop, Side: exit},
+		TakeProfit: BracketLeg{Type: models.OrderTypeLimit, Side: exit},
+	}
+
+	if err := s.prepareBracket(ctx, req, result); err != nil {
+		return result, err
+	}
+
+	entry := &models.PlaceOrderRequest{
+		AccountID:  req.AccountID,
+		ContractID: req.ContractID,
+		Type:       req.EntryType,
+		Side:       req.Side,
+		Size:       req.Size,
+		CustomTag:  req.CustomTag,
+	}
+	switch req.EntryType {
+	case models.OrderTypeLimit:
+		entry.LimitPrice = req.EntryPrice
+	case models.OrderTypeStop:
+		entry.StopPrice = req.EntryPrice
+	}
+	if err := s.placeBracketLeg(ctx, entry, &result.Entry); err != nil {
+		return result, fmt.Errorf("failed to place bracket entry: %w", err)
+	}
+
+	stopLoss := &models.PlaceOrderRequest{
+		AccountID:     req.AccountID,
+		ContractID:    req.ContractID,
+		Type:          models.OrderTypeStop,
+		Side:          exit,
+		Size:          req.Size,
+		StopPrice:     result.StopLoss.Price,
+		CustomTag:     bracketTag(req.CustomTag, "-sl"),
+		LinkedOrderID: result.Entry.OrderID,
+	}
+	if err := s.placeBracketLeg(ctx, stopLoss, &result.StopLoss); err != nil {
+		return result, s.unwindBracket(ctx, req, result, fmt.Errorf("failed to place bracket stop loss: %w", err))
+	}
+
+	takeProfit := &models.PlaceOrderRequest{
+		AccountID:     req.AccountID,
+		ContractID:    req.ContractID,
+		Type:          models.OrderTypeLimit,
+		Side:          exit,
+		Size:          req.Size,
+		LimitPrice:    result.TakeProfit.Price,
+		CustomTag:     bracketTag(req.CustomTag, "-tp"),
+		LinkedOrderID: result.Entry.OrderID,
+	}
+	if err := s.placeBracketLeg(ctx, takeProfit, &result.TakeProfit); err != nil {
+		return result, s.unwindBracket(ctx, req, result, fmt.Errorf("failed to place bracket take profit: %w", err))
+	}
+
+	if req.OCO != nil {
+		groupID, err := req.OCO.Add(context.WithoutCancel(ctx), req.AccountID, *result.StopLoss.OrderID, *result.TakeProfit.OrderID)
+		if err != nil {
+			return result, fmt.Errorf("failed to group bracket exits: %w", err)
+		}
+		result.OCOGroupID = groupID
+	}
+
+	return result, nil
+}
+
+// prepareBracket validates req and resolves the protective leg prices.
+func (s *BracketService) prepareBracket(ctx context.Context, req *BracketOrderRequest, result *BracketOrderResult) error {
+	if req.Size <= 0 {
+		return fmt.Errorf("%w: size must be positive, got %d", ErrInvalidBracket, req.Size)
+	}
+	if !req.StopLoss.isSet() || !req.TakeProfit.isSet() {
+		return fmt.Errorf("%w: both stop loss and take profit are required", ErrInvalidBracket)
+	}
+
+	reference := req.ReferencePrice
+	switch req.EntryType {
+	case models.OrderTypeMarket:
+	case models.OrderTypeLimit, models.OrderTypeStop:
+		if req.EntryPrice == nil {
+			return fmt.Errorf("%w: %s entry requires an entry price", ErrInvalidBracket, req.EntryType)
+		}
+		reference = req.EntryPrice
+	default:
+		return fmt.Errorf("%w: unsupported entry type %s", ErrInvalidBracket, req.EntryType)
+	}
+
+	tickSize := req.TickSize
+	if tickSize <= 0 && (req.StopLoss.Price == nil || req.TakeProfit.Price == nil) {
+		if reference == nil {
+			return fmt.Errorf("%w: tick targets of a market entry require a reference price", ErrInvalidBracket)
+		}
+		resp, err := s.contracts.SearchContractByID(ctx, &models.SearchContractByIdRequest{ContractID: req.ContractID})
+		if err != nil {
+			return fmt.Errorf("failed to load contract %s: %w", req.ContractID, err)
+		}
+		if !resp.Success || resp.Contract == nil {
+			return fmt.Errorf("failed to load contract %s with error code: %v", req.ContractID, resp.ErrorCode)
+		}
+		tickSize = resp.Contract.TickSize
+	}
+
+	// Protective prices sit below the reference for longs and above it for
+	// shorts.
+	direction := 1.0
+	if req.Side == models.OrderSideAsk {
+		direction = -1
+	}
+
+	resolve := func(name string, target BracketTarget, sign float64) (*float64, error) {
+		if target.Price != nil {
+			price := *target.Price
+			return &price, nil
+		}
+		if reference == nil {
+			return nil, fmt.Errorf("%w: %s in ticks requires a reference price", ErrInvalidBracket, name)
+		}
+		if tickSize <= 0 {
+			return nil, fmt.Errorf("%w: %s in ticks requires a tick size", ErrInvalidBracket, name)
+		}
+		price := roundToTick(*reference+sign*direction*target.Ticks*tickSize, tickSize)
+		return &price, nil
+	}
+
+	var err error
+	if result.StopLoss.Price, err = resolve("stop loss", req.StopLoss, -1); err != nil {
+		return err
+	}
+	if result.TakeProfit.Price, err = resolve("take profit", req.TakeProfit, 1); err != nil {
+		return err
+	}
+
+	if reference != nil {
+		stop, target := *result.StopLoss.Price, *result.TakeProfit.Price
+		if direction*(stop-*reference) >= 0 || direction*(target-*reference) <= 0 {
+			return fmt.Errorf("%w: stop loss %v and take profit %v are on the wrong side of %v for a %s entry",
+				ErrInvalidBracket, stop, target, *reference, req.Side)
+		}
+	}
+
+	return nil
+}
+
+func (s *BracketService) placeBracketLeg(ctx context.Context, req *models.PlaceOrderRequest, leg *BracketLeg) error {
+	resp, err := s.orders.PlaceOrder(ctx, req)
+	if err == nil && !resp.Success {
+		err = placeOrderError(resp.ErrorCode)
+		if resp.ErrorMessage != nil {
+			err = fmt.Errorf("%w: %s", err, *resp.ErrorMessage)
+		}
+	}
+	if err == nil && resp.OrderID == nil {
+		err = fmt.Errorf("order placed without an order id")
+	}
+	if err != nil {
+		leg.State = BracketLegRejected
+		leg.Err = err
+		return err
+	}
+
+	leg.OrderID = resp.OrderID
+	leg.State = BracketLegPlaced
+	return nil
+}
+
+// unwindBracket cancels the placed exits and then undoes the entry after
+// cause, and returns cause joined with any failures. It ignores ctx
+// cancellation so a timed out placement does not leave orphaned legs or an
+// unprotected position behind.
+func (s *BracketService) unwindBracket(ctx context.Context, req *BracketOrderRequest, result *BracketOrderResult, cause error) error {
+	ctx = context.WithoutCancel(ctx)
+
+	errs := []error{cause}
+	for _, leg := range []*BracketLeg{&result.StopLoss, &result.TakeProfit} {
+		errs = append(errs, s.cancelBracketLeg(ctx, req.AccountID, leg))
+	}
+	if req.EntryType == models.OrderTypeMarket {
+		errs = append(errs, s.closeBracketEntry(ctx, req, &result.Entry))
+	} else {
+		errs = append(errs, s.cancelBracketLeg(ctx, req.AccountID, &result.Entry))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *BracketService) cancelBracketLeg(ctx context.Context, accountID int32, leg *BracketLeg) error {
+	if leg.State != BracketLegPlaced {
+		return nil
+	}
+
+	resp, err := s.orders.CancelOrder(ctx, &models.CancelOrderRequest{
+		AccountID: accountID,
+		OrderID:   *leg.OrderID,
+	})
+	if err == nil && !resp.Success {
+		err = cancelOrderError(resp.ErrorCode)
+	}
+	if err != nil {
+		leg.State = BracketLegCancelFailed
+		leg.Err = err
+		return fmt.Errorf("failed to cancel bracket order %d: %w", *leg.OrderID, err)
+	}
+	leg.State = BracketLegCancelled
+	return nil
+}
+
+// closeBracketEntry closes the position opened by a filled market entry. Only
+// the entry's size is closed when the position is larger, so a position held
+// before the bracket is left alone. Without a position the entry has not
+// filled yet and is cancelled instead. A position on the other side or
+// smaller than the entry cannot have come from it, so it is not touched and
+// the leg is marked BracketLegCloseFailed.
+func (s *BracketService) closeBracketEntry(ctx context.Context, req *BracketOrderRequest, leg *BracketLeg) error {
+	if leg.State != BracketLegPlaced {
+		return nil
+	}
+
+	fail := func(err error) error {
+		leg.State = BracketLegCloseFailed
+		leg.Err = err
+		return fmt.Errorf("failed to close bracket position in %s: %w", req.ContractID, err)
+	}
+
+	resp, err := s.positions.SearchOpenPositions(ctx, &models.SearchPositionRequest{AccountID: req.AccountID})
+	if err == nil && !resp.Success {
+		err = searchPositionError(resp.ErrorCode)
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	var position *models.PositionModel
+	for i := range resp.Positions {
+		if resp.Positions[i].ContractID == req.ContractID {
+			position = &resp.Positions[i]
+			break
+		}
+	}
+	if position == nil {
+		return s.cancelBracketLeg(ctx, req.AccountID, leg)
+	}
+
+	side := models.PositionTypeLong
+	if req.Side == models.OrderSideAsk {
+		side = models.PositionTypeShort
+	}
+	if position.Type != side {
+		return fail(fmt.Errorf("%w: position is %s, entry was %s", ErrBracketPositionMismatch, position.Type, req.Side))
+	}
+	if position.Size < req.Size {
+		return fail(fmt.Errorf("%w: position of %d is smaller than the entry of %d", ErrBracketPositionMismatch, position.Size, req.Size))
+	}
+
+	if position.Size > req.Size {
+		resp, err := s.positions.PartialCloseContractPosition(ctx, &models.PartialCloseContractPositionRequest{
+			AccountID:  req.AccountID,
+			ContractID: req.ContractID,
+			Size:       req.Size,
+		})
+		if err == nil && !resp.Success {
+			err = partialClosePositionError(resp.ErrorCode)
+		}
+		if err != nil {
+			return fail(err)
+		}
+	} else {
+		resp, err := s.positions.CloseContractPosition(ctx, &models.CloseContractPositionRequest{
+			AccountID:  req.AccountID,
+			ContractID: req.ContractID,
+		})
+		if err == nil && !resp.Success {
+			err = closePositionError(resp.ErrorCode)
+		}
+		if err != nil {
+			return fail(err)
+		}
+	}
+
+	leg.State = BracketLegClosed
+	return nil
+}
+
+func bracketTag(tag *string, suffix string) *string {
+	if tag == nil || *tag == "" {
+		return nil
+	}
+	legTag := *tag + suffix
+	return &legTag
+}
+
+func roundToTick(price, tickSize float64) float64 {
+	if tickSize <= 0 {
+		return price
+	}
+	ticks := math.Round(price / tickSize)
+	// Rounding the tick count and multiplying back can still leave float
+	// noise such as 5012.250000000001, so trim it at the tick precision.
+	return math.Round(ticks*tickSize*1e9) / 1e9
+}
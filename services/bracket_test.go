@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/tradingiq/projectx-client/models"
+)
+
+// newBracketAPI places the entry and stop loss as orders 1 and 2, rejects the
+// take profit and returns positions from the open positions search.
+func newBracketAPI(t *testing.T, positions []models.PositionModel) (*testAPI, *BracketService) {
+	t.Helper()
+	api, c := newTestAPI(t)
+
+	var placed int32
+	api.handle("/api/Order/place", func([]byte) interface{} {
+		placed++
+		if placed == 3 {
+			message := "price outside limits"
+			return models.PlaceOrderResponse{ErrorCode: models.PlaceOrderErrorCodeOrderRejected, ErrorMessage: &message}
+		}
+		id := placed
+		return models.PlaceOrderResponse{Success: true, OrderID: &id}
+	})
+	api.handle("/api/Order/cancel", func([]byte) interface{} {
+		return models.CancelOrderResponse{Success: true}
+	})
+	api.handle("/api/Position/searchOpen", func([]byte) interface{} {
+		return models.SearchPositionResponse{Success: true, Positions: positions}
+	})
+	api.handle("/api/Position/closeContract", func([]byte) interface{} {
+		return models.ClosePositionResponse{Success: true}
+	})
+	api.handle("/api/Position/partialCloseContract", func([]byte) interface{} {
+		return models.PartialClosePositionResponse{Success: true}
+	})
+
+	return api, NewBracketService(NewOrderService(c), NewPositionService(c), NewContractService(c))
+}
+
+func marketBracket(side models.OrderSide, size int32) *BracketOrderRequest {
+	stop, target := 4990.0, 5020.0
+	if side == models.OrderSideAsk {
+		stop, target = target, stop
+	}
+	reference := 5000.0
+	return &BracketOrderRequest{
+		AccountID:      1,
+		ContractID:     riskTestContract,
+		Side:           side,
+		Size:           size,
+		EntryType:      models.OrderTypeMarket,
+		ReferencePrice: &reference,
+		StopLoss:       BracketTarget{Price: &stop},
+		TakeProfit:     BracketTarget{Price: &target},
+	}
+}
+
+func heldPosition(positionType models.PositionType, size int32) []models.PositionModel {
+	return []models.PositionModel{{AccountID: 1, ContractID: riskTestContract, Type: positionType, Size: size}}
+}
+
+func TestBracketUnwindMarketEntry(t *testing.T) {
+	tests := []struct {
+		name      string
+		side      models.OrderSide
+		positions []models.PositionModel
+		entry     BracketLegState
+		mismatch  bool
+		closes    int
+		partial   []int32
+		cancels   int
+	}{
+		{
+			name:    "not filled yet",
+			side:    models.OrderSideBid,
+			entry:   BracketLegCancelled,
+			cancels: 2,
+		},
+		{
+			name:      "filled",
+			side:      models.OrderSideBid,
+			positions: heldPosition(models.PositionTypeLong, 2),
+			entry:     BracketLegClosed,
+			closes:    1,
+			cancels:   1,
+		},
+		{
+			name:      "added to an existing position",
+			side:      models.OrderSideAsk,
+			positions: heldPosition(models.PositionTypeShort, 5),
+			entry:     BracketLegClosed,
+			partial:   []int32{2},
+			cancels:   1,
+		},
+		{
+			name:      "opposite position",
+			side:      models.OrderSideBid,
+			positions: heldPosition(models.PositionTypeShort, 3),
+			entry:     BracketLegCloseFailed,
+			mismatch:  true,
+			cancels:   1,
+		},
+		{
+			name:      "position smaller than the entry",
+			side:      models.OrderSideBid,
+			positions: heldPosition(models.PositionTypeLong, 1),
+			entry:     BracketLegCloseFailed,
+			mismatch:  true,
+			cancels:   1,
+		},
+	}
+	for _, test := range tests {
+		api, brackets := newBracketAPI(t, test.positions)
+		result, err := brackets.PlaceBracketOrder(context.Background(), marketBracket(test.side, 2))
+		if err == nil {
+			t.Fatalf("%s: placed without error", test.name)
+		}
+
+		if result.Entry.State != test.entry {
+			t.Errorf("%s: entry %s, want %s", test.name, result.Entry.State, test.entry)
+		}
+		if result.StopLoss.State != BracketLegCancelled || result.TakeProfit.State != BracketLegRejected {
+			t.Errorf("%s: stop loss %s, take profit %s", test.name, result.StopLoss.State, result.TakeProfit.State)
+		}
+		if got := errors.Is(err, ErrBracketPositionMismatch); got != test.mismatch {
+			t.Errorf("%s: position mismatch %v, want %v: %v", test.name, got, test.mismatch, err)
+		}
+
+		if n := len(api.bodies("/api/Position/closeContract")); n != test.closes {
+			t.Errorf("%s: %d full closes, want %d", test.name, n, test.closes)
+		}
+		var partial []int32
+		for _, body := range api.bodies("/api/Position/partialCloseContract") {
+			var req models.PartialCloseContractPositionRequest
+			json.Unmarshal(body, &req)
+			partial = append(partial, req.Size)
+		}
+		if len(partial) != len(test.partial) || len(partial) > 0 && partial[0] != test.partial[0] {
+			t.Errorf("%s: partial closes %v, want %v", test.name, partial, test.partial)
+		}
+		if n := len(api.bodies("/api/Order/cancel")); n != test.cancels {
+			t.Errorf("%s: %d cancels, want %d", test.name, n, test.cancels)
+		}
+	}
+}
@@ -18,7 +18,7 @@ func NewContractService(c *client.Client) *ContractService {
 
 func (s *ContractService) SearchContracts(ctx context.Context, req *models.SearchContractRequest) (*models.SearchContractResponse, error) {
 	var resp models.SearchContractResponse
-	err := s.client.DoJSON(ctx, &client.Request{
+	raw, err := s.client.DoJSONResponse(ctx, &client.Request{
 		Method: http.MethodPost,
 		Path:   "/api/Contract/search",
 		Body:   req,
@@ -27,12 +27,16 @@ func (s *ContractService) SearchContracts(ctx context.Context, req *models.Searc
 		return nil, err
 	}
 
+	if err := checkResponse(s.client, raw, resp.Success, int(resp.ErrorCode), resp.ErrorMessage, nil); err != nil {
+		return &resp, err
+	}
+
 	return &resp, nil
 }
 
 func (s *ContractService) SearchContractByID(ctx context.Context, req *models.SearchContractByIdRequest) (*models.SearchContractByIdResponse, error) {
 	var resp models.SearchContractByIdResponse
-	err := s.client.DoJSON(ctx, &client.Request{
+	raw, err := s.client.DoJSONResponse(ctx, &client.Request{
 		Method: http.MethodPost,
 		Path:   "/api/Contract/searchById",
 		Body:   req,
@@ -41,5 +45,9 @@ func (s *ContractService) SearchContractByID(ctx context.Context, req *models.Se
 		return nil, err
 	}
 
+	if err := checkResponse(s.client, raw, resp.Success, int(resp.ErrorCode), resp.ErrorMessage, searchContractByIDError(resp.ErrorCode)); err != nil {
+		return &resp, err
+	}
+
 	return &resp, nil
 }
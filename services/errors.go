@@ -0,0 +1,274 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tradingiq/projectx-client/client"
+	"github.com/tradingiq/projectx-client/models"
+)
+
+var (
+	ErrAccountNotFound         = errors.New("account not found")
+	ErrAccountRejected         = errors.New("account rejected")
+	ErrAccountViolation        = errors.New("account violation")
+	ErrInsufficientFunds       = errors.New("insufficient funds")
+	ErrOutsideTradingHours     = errors.New("outside trading hours")
+	ErrContractNotFound        = errors.New("contract not found")
+	ErrContractNotActive       = errors.New("contract not active")
+	ErrOrderNotFound           = errors.New("order not found")
+	ErrOrderRejected           = errors.New("order rejected")
+	ErrOrderPending            = errors.New("order pending")
+	ErrPositionNotFound        = errors.New("position not found")
+	ErrInvalidCloseSize        = errors.New("invalid close size")
+	ErrInvalidCredentials      = errors.New("invalid credentials")
+	ErrInvalidDevice           = errors.New("invalid device")
+	ErrAgreementsNotSigned     = errors.New("agreements not signed")
+	ErrAPISubscriptionNotFound = errors.New("api subscription not found")
+	ErrInvalidSession          = errors.New("invalid session")
+	ErrSessionNotFound         = errors.New("session not found")
+	ErrExpiredToken            = errors.New("expired token")
+	ErrUnknownAPIError         = errors.New("unknown api error")
+)
+
+// APIError is returned for responses with success set to false. Code holds
+// the endpoint's error code enum as an int, so it compares directly against
+// the constants in models after conversion.
+type APIError struct {
+	Endpoint   string
+	Code       int
+	Message    string
+	StatusCode int
+	Body       []byte
+	Err        error
+}
+
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("%s failed with error code %d: %s", e.Endpoint, e.Code, e.Message)
+	}
+	return fmt.Sprintf("%s failed with error code %d: %v", e.Endpoint, e.Code, e.Err)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+func checkResponse(c *client.Client, raw *client.Response, success bool, code int, message *string, err error) error {
+	if success || !c.APIErrorsEnabled() {
+		return nil
+	}
+
+	apiErr := &APIError{
+		Code: code,
+		Err:  err,
+	}
+	if message != nil {
+		apiErr.Message = *message
+	}
+	if raw != nil {
+		apiErr.Body = raw.Body
+		if raw.Response != nil {
+			apiErr.StatusCode = raw.StatusCode
+			if raw.Request != nil && raw.Request.URL != nil {
+				apiErr.Endpoint = raw.Request.URL.Path
+			}
+		}
+	}
+	if apiErr.Err == nil {
+		apiErr.Err = ErrUnknownAPIError
+	}
+
+	return apiErr
+}
+
+func loginError(code models.LoginErrorCode) error {
+	switch code {
+	case models.LoginErrorCodeUserNotFound,
+		models.LoginErrorCodePasswordVerificationFailed,
+		models.LoginErrorCodeInvalidCredentials,
+		models.LoginErrorCodeAppNotFound,
+		models.LoginErrorCodeAppVerificationFailed:
+		return ErrInvalidCredentials
+	case models.LoginErrorCodeInvalidDevice:
+		return ErrInvalidDevice
+	case models.LoginErrorCodeAgreementsNotSigned:
+		return ErrAgreementsNotSigned
+	case models.LoginErrorCodeApiSubscriptionNotFound:
+		return ErrAPISubscriptionNotFound
+	default:
+		return ErrUnknownAPIError
+	}
+}
+
+func logoutError(code models.LogoutErrorCode) error {
+	switch code {
+	case models.LogoutErrorCodeInvalidSession:
+		return ErrInvalidSession
+	default:
+		return ErrUnknownAPIError
+	}
+}
+
+func validateError(code models.ValidateErrorCode) error {
+	switch code {
+	case models.ValidateErrorCodeInvalidSession:
+		return ErrInvalidSession
+	case models.ValidateErrorCodeSessionNotFound:
+		return ErrSessionNotFound
+	case models.ValidateErrorCodeExpiredToken:
+		return ErrExpiredToken
+	default:
+		return ErrUnknownAPIError
+	}
+}
+
+func searchContractByIDError(code models.SearchContractByIdErrorCode) error {
+	switch code {
+	case models.SearchContractByIdErrorCodeContractNotFound:
+		return ErrContractNotFound
+	default:
+		return ErrUnknownAPIError
+	}
+}
+
+func retrieveBarError(code models.RetrieveBarErrorCode) error {
+	switch code {
+	case models.RetrieveBarErrorCodeContractNotFound:
+		return ErrContractNotFound
+	default:
+		return ErrUnknownAPIError
+	}
+}
+
+func searchOrderError(code models.SearchOrderErrorCode) error {
+	switch code {
+	case models.SearchOrderErrorCodeAccountNotFound:
+		return ErrAccountNotFound
+	default:
+		return ErrUnknownAPIError
+	}
+}
+
+func placeOrderError(code models.PlaceOrderErrorCode) error {
+	switch code {
+	case models.PlaceOrderErrorCodeAccountNotFound:
+		return ErrAccountNotFound
+	case models.PlaceOrderErrorCodeOrderRejected:
+		return ErrOrderRejected
+	case models.PlaceOrderErrorCodeInsufficientFunds:
+		return ErrInsufficientFunds
+	case models.PlaceOrderErrorCodeAccountViolation:
+		return ErrAccountViolation
+	case models.PlaceOrderErrorCodeOutsideTradingHours:
+		return ErrOutsideTradingHours
+	case models.PlaceOrderErrorCodeOrderPending:
+		return ErrOrderPending
+	case models.PlaceOrderErrorCodeContractNotFound:
+		return ErrContractNotFound
+	case models.PlaceOrderErrorCodeContractNotActive:
+		return ErrContractNotActive
+	case models.PlaceOrderErrorCodeAccountRejected:
+		return ErrAccountRejected
+	default:
+		return ErrUnknownAPIError
+	}
+}
+
+func cancelOrderError(code models.CancelOrderErrorCode) error {
+	switch code {
+	case models.CancelOrderErrorCodeAccountNotFound:
+		return ErrAccountNotFound
+	case models.CancelOrderErrorCodeOrderNotFound:
+		return ErrOrderNotFound
+	case models.CancelOrderErrorCodeRejected:
+		return ErrOrderRejected
+	case models.CancelOrderErrorCodePending:
+		return ErrOrderPending
+	case models.CancelOrderErrorCodeAccountRejected:
+		return ErrAccountRejected
+	default:
+		return ErrUnknownAPIError
+	}
+}
+
+func modifyOrderError(code models.ModifyOrderErrorCode) error {
+	switch code {
+	case models.ModifyOrderErrorCodeAccountNotFound:
+		return ErrAccountNotFound
+	case models.ModifyOrderErrorCodeOrderNotFound:
+		return ErrOrderNotFound
+	case models.ModifyOrderErrorCodeRejected:
+		return ErrOrderRejected
+	case models.ModifyOrderErrorCodePending:
+		return ErrOrderPending
+	case models.ModifyOrderErrorCodeAccountRejected:
+		return ErrAccountRejected
+	case models.ModifyOrderErrorCodeContractNotFound:
+		return ErrContractNotFound
+	default:
+		return ErrUnknownAPIError
+	}
+}
+
+func searchPositionError(code models.SearchPositionErrorCode) error {
+	switch code {
+	case models.SearchPositionErrorCodeAccountNotFound:
+		return ErrAccountNotFound
+	default:
+		return ErrUnknownAPIError
+	}
+}
+
+func closePositionError(code models.ClosePositionErrorCode) error {
+	switch code {
+	case models.ClosePositionErrorCodeAccountNotFound:
+		return ErrAccountNotFound
+	case models.ClosePositionErrorCodePositionNotFound:
+		return ErrPositionNotFound
+	case models.ClosePositionErrorCodeContractNotFound:
+		return ErrContractNotFound
+	case models.ClosePositionErrorCodeContractNotActive:
+		return ErrContractNotActive
+	case models.ClosePositionErrorCodeOrderRejected:
+		return ErrOrderRejected
+	case models.ClosePositionErrorCodeOrderPending:
+		return ErrOrderPending
+	case models.ClosePositionErrorCodeAccountRejected:
+		return ErrAccountRejected
+	default:
+		return ErrUnknownAPIError
+	}
+}
+
+func partialClosePositionError(code models.PartialClosePositionErrorCode) error {
+	switch code {
+	case models.PartialClosePositionErrorCodeAccountNotFound:
+		return ErrAccountNotFound
+	case models.PartialClosePositionErrorCodePositionNotFound:
+		return ErrPositionNotFound
+	case models.PartialClosePositionErrorCodeContractNotFound:
+		return ErrContractNotFound
+	case models.PartialClosePositionErrorCodeContractNotActive:
+		return ErrContractNotActive
+	case models.PartialClosePositionErrorCodeInvalidCloseSize:
+		return ErrInvalidCloseSize
+	case models.PartialClosePositionErrorCodeOrderRejected:
+		return ErrOrderRejected
+	case models.PartialClosePositionErrorCodeOrderPending:
+		return ErrOrderPending
+	case models.PartialClosePositionErrorCodeAccountRejected:
+		return ErrAccountRejected
+	default:
+		return ErrUnknownAPIError
+	}
+}
+
+func searchTradeError(code models.SearchTradeErrorCode) error {
+	switch code {
+	case models.SearchTradeErrorCodeAccountNotFound:
+		return ErrAccountNotFound
+	default:
+		return ErrUnknownAPIError
+	}
+}
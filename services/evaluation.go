@@ -0,0 +1,595 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tradingiq/projectx-client/models"
+)
+
+type DrawdownMode int
+
+const (
+	// DrawdownEndOfDay trails the max loss threshold behind the highest
+	// end-of-day balance.
+	DrawdownEndOfDay DrawdownMode = iota
+	// DrawdownIntraday trails it behind the highest equity, including open
+	// P&L, seen at any time.
+	DrawdownIntraday
+)
+
+func (m DrawdownMode) String() string {
+	switch m {
+	case DrawdownEndOfDay:
+		return "END_OF_DAY"
+	case DrawdownIntraday:
+		return "INTRADAY"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// EvaluationRules are the combine parameters of one account.
+type EvaluationRules struct {
+	StartingBalance float64
+	// HighWater is the high-water mark carried over from earlier sessions.
+	// It defaults to StartingBalance.
+	HighWater float64
+	// MaxLoss is the trailing drawdown distance below the high-water mark.
+	MaxLoss float64
+	Mode    DrawdownMode
+	// LockThreshold stops the max loss threshold from trailing past it,
+	// typically the starting balance. Zero trails without limit.
+	LockThreshold float64
+	// DailyLossLimit is measured from the balance at the start of the trading
+	// day. Zero disables it.
+	DailyLossLimit float64
+	// WarningBuffers are distances to a threshold at which a warning is sent,
+	// e.g. 500, 250, 100.
+	WarningBuffers []float64
+	// FlattenBuffer triggers the flatten function once the distance to a
+	// threshold is at or below it. Zero, or no flatten function, disables
+	// auto-flatten.
+	FlattenBuffer float64
+	// Location and DayStart define when a trading day begins, e.g.
+	// America/Chicago and 17h. Location defaults to UTC.
+	Location *time.Location
+	DayStart time.Duration
+}
+
+type EvaluationEventKind int
+
+const (
+	EvaluationWarning EvaluationEventKind = iota
+	EvaluationFlatten
+	EvaluationBreach
+)
+
+func (k EvaluationEventKind) String() string {
+	switch k {
+	case EvaluationWarning:
+		return "WARNING"
+	case EvaluationFlatten:
+		return "FLATTEN"
+	case EvaluationBreach:
+		return "BREACH"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+const (
+	EvaluationRuleMaxLoss   = "max_loss"
+	EvaluationRuleDailyLoss = "daily_loss"
+)
+
+type EvaluationEvent struct {
+	Kind      EvaluationEventKind
+	Rule      string
+	AccountID int32
+	Equity    float64
+	Threshold float64
+	Distance  float64
+	// Buffer is the warning buffer or flatten buffer that was crossed.
+	Buffer float64
+	// Err is set on flatten events when the flatten function failed.
+	Err  error
+	Time time.Time
+}
+
+type EvaluationStatus struct {
+	AccountID     int32
+	TradingDay    time.Time
+	Balance       float64
+	UnrealizedPnL float64
+	Equity        float64
+	HighWater     float64
+	// MaxLossThreshold is the equity at which the account fails.
+	MaxLossThreshold float64
+	MaxLossDistance  float64
+	DayStartBalance  float64
+	RealizedPnL      float64
+	DailyPnL         float64
+	// DailyLossThreshold is the equity at which the daily loss limit is hit;
+	// it is zero when the limit is disabled.
+	DailyLossThreshold float64
+	DailyLossDistance  float64
+	Breached           bool
+}
+
+type evaluationAccount struct {
+	rules           EvaluationRules
+	day             time.Time
+	balance         float64
+	hasBalance      bool
+	dayStartBalance float64
+	realized        float64
+	realizedSynced  bool
+	unrealized      float64
+	highWater       float64
+	breached        map[string]bool
+	warned          map[string]map[float64]bool
+	flattened       map[string]bool
+}
+
+// EvaluationMonitor tracks prop-firm combine rules: the trailing max loss and
+// the daily loss limit. Balance comes from user hub account events, realized
+// P&L from half-turn trades refreshed on every user hub trade event, and
+// unrealized P&L from the position book.
+type EvaluationMonitor struct {
+	accountService *AccountService
+	trades         *TradeService
+	userData       *UserDataWebSocketService
+	positions      *PositionBook
+
+	mu           sync.Mutex
+	accounts     map[int32]*evaluationAccount
+	flatten      func(ctx context.Context, accountID int32) error
+	errorHandler func(error)
+	unsubscribe  []func()
+	ctx          context.Context
+	cancel       context.CancelFunc
+	now          func() time.Time
+
+	events *subscribers[func(EvaluationEvent)]
+}
+
+// NewEvaluationMonitor creates a monitor; trades may be nil to derive
+// realized P&L from balance changes instead.
+func NewEvaluationMonitor(accounts *AccountService, trades *TradeService, userData *UserDataWebSocketService, positions *PositionBook) *EvaluationMonitor {
+	return &EvaluationMonitor{
+		accountService: accounts,
+		trades:         trades,
+		userData:       userData,
+		positions:      positions,
+		accounts:       make(map[int32]*evaluationAccount),
+		now:            time.Now,
+		events:         newSubscribers[func(EvaluationEvent)](),
+	}
+}
+
+// Watch adds accountID with rules. The high-water mark is kept in memory, so
+// pass the last known value in rules.HighWater after a restart.
+func (m *EvaluationMonitor) Watch(accountID int32, rules EvaluationRules) {
+	if rules.Location == nil {
+		rules.Location = time.UTC
+	}
+	if rules.HighWater < rules.StartingBalance {
+		rules.HighWater = rules.StartingBalance
+	}
+	rules.WarningBuffers = append([]float64(nil), rules.WarningBuffers...)
+	sort.Sort(sort.Reverse(sort.Float64Slice(rules.WarningBuffers)))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.accounts[accountID] = &evaluationAccount{
+		rules:     rules,
+		highWater: rules.HighWater,
+		breached:  make(map[string]bool),
+		warned:    make(map[string]map[float64]bool),
+		flattened: make(map[string]bool),
+	}
+}
+
+// SetFlattenFunc sets what runs when an account reaches its flatten buffer,
+// usually projectx.Client.Flatten.
+func (m *EvaluationMonitor) SetFlattenFunc(flatten func(ctx context.Context, accountID int32) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.flatten = flatten
+}
+
+func (m *EvaluationMonitor) SetErrorHandler(handler func(error)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.errorHandler = handler
+}
+
+// OnEvent registers a callback for warnings, flattens and breaches and returns
+// a function that removes it.
+func (m *EvaluationMonitor) OnEvent(handler func(EvaluationEvent)) func() {
+	return m.events.add(subscribeAll, handler)
+}
+
+// Start loads the balances of the watched accounts and their realized P&L for
+// the current trading day, then follows account, trade and position events.
+// Trading day rollovers are checked every minute. The caller remains
+// responsible for SubscribeAccounts and SubscribeTrades on the user hub and
+// for starting the position book.
+func (m *EvaluationMonitor) Start(ctx context.Context) error {
+	m.mu.Lock()
+	if m.cancel != nil {
+		m.mu.Unlock()
+		return fmt.Errorf("evaluation monitor already started")
+	}
+	m.ctx, m.cancel = context.WithCancel(ctx)
+	ctx = m.ctx
+	m.unsubscribe = append(m.unsubscribe,
+		m.userData.OnAccount(m.HandleAccountUpdate),
+		m.userData.OnTrade(m.HandleTradeUpdate),
+	)
+	if m.positions != nil {
+		m.unsubscribe = append(m.unsubscribe, m.positions.OnChange(AllContracts, m.handlePositionChange))
+	}
+	m.mu.Unlock()
+
+	if err := m.Sync(ctx); err != nil {
+		return err
+	}
+
+	go m.watchRollover(ctx)
+	return nil
+}
+
+func (m *EvaluationMonitor) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, unsubscribe := range m.unsubscribe {
+		unsubscribe()
+	}
+	m.unsubscribe = nil
+
+	if m.cancel != nil {
+		m.cancel()
+		m.cancel = nil
+	}
+}
+
+// Sync reloads balances from REST and realized P&L from half-turn trades for
+// every watched account.
+func (m *EvaluationMonitor) Sync(ctx context.Context) error {
+	resp, err := m.accountService.SearchAccounts(ctx, &models.SearchAccountRequest{OnlyActiveAccounts: true})
+	if err != nil {
+		return fmt.Errorf("failed to search accounts: %w", err)
+	}
+	if !resp.Success {
+		return fmt.Errorf("failed to search accounts with error code: %v", resp.ErrorCode)
+	}
+
+	for _, account := range resp.Accounts {
+		m.setBalance(account.ID, account.Balance)
+	}
+
+	for _, accountID := range m.accountIDs() {
+		if err := m.syncRealized(ctx, accountID); err != nil {
+			return err
+		}
+		m.Evaluate(accountID)
+	}
+	return nil
+}
+
+func (m *EvaluationMonitor) HandleAccountUpdate(update *models.AccountUpdateData) {
+	if m.setBalance(update.Data.ID, update.Data.Balance) {
+		m.Evaluate(update.Data.ID)
+	}
+}
+
+// HandleTradeUpdate refreshes the realized P&L of the trade's account off the
+// hub receive loop, since trade events do not carry P&L.
+func (m *EvaluationMonitor) HandleTradeUpdate(update *models.TradeUpdateData) {
+	accountID := update.Data.AccountID
+
+	m.mu.Lock()
+	_, watched := m.accounts[accountID]
+	ctx := m.ctx
+	m.mu.Unlock()
+	if !watched || ctx == nil || m.trades == nil {
+		return
+	}
+
+	go func() {
+		if err := m.syncRealized(ctx, accountID); err != nil {
+			if ctx.Err() == nil {
+				m.reportError(err)
+			}
+			return
+		}
+		m.Evaluate(accountID)
+	}()
+}
+
+func (m *EvaluationMonitor) handlePositionChange(pnl PositionPnL) {
+	accountID := pnl.Position.AccountID
+
+	m.mu.Lock()
+	_, watched := m.accounts[accountID]
+	m.mu.Unlock()
+	if watched {
+		m.Evaluate(accountID)
+	}
+}
+
+func (m *EvaluationMonitor) setBalance(accountID int32, balance float64) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	account, ok := m.accounts[accountID]
+	if !ok {
+		return false
+	}
+	if !account.hasBalance {
+		account.dayStartBalance = balance
+		account.hasBalance = true
+	}
+	account.balance = balance
+	return true
+}
+
+func (m *EvaluationMonitor) syncRealized(ctx context.Context, accountID int32) error {
+	if m.trades == nil {
+		return nil
+	}
+
+	m.mu.Lock()
+	account, ok := m.accounts[accountID]
+	if !ok {
+		m.mu.Unlock()
+		return nil
+	}
+	day := m.rolloverLocked(account)
+	m.mu.Unlock()
+
+	resp, err := m.trades.SearchHalfTurnTrades(ctx, &models.SearchTradeRequest{
+		AccountID:      accountID,
+		StartTimestamp: &day,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to search trades: %w", err)
+	}
+	if !resp.Success {
+		return fmt.Errorf("failed to search trades with error code: %v", resp.ErrorCode)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if account.day.Equal(day) {
+		account.realized = realizedPnL(resp.Trades)
+		account.realizedSynced = true
+	}
+	return nil
+}
+
+func (m *EvaluationMonitor) watchRollover(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, accountID := range m.accountIDs() {
+				m.Evaluate(accountID)
+			}
+		}
+	}
+}
+
+// Evaluate recomputes the thresholds of accountID and sends any warning,
+// flatten or breach events that became due.
+func (m *EvaluationMonitor) Evaluate(accountID int32) {
+	m.mu.Lock()
+	account, ok := m.accounts[accountID]
+	if !ok || !account.hasBalance {
+		m.mu.Unlock()
+		return
+	}
+
+	m.rolloverLocked(account)
+	if m.positions != nil {
+		account.unrealized = m.positions.Account(accountID).UnrealizedPnL
+	}
+	status := m.statusLocked(accountID, account)
+	if account.rules.Mode == DrawdownIntraday && status.Equity > account.highWater {
+		account.highWater = status.Equity
+		status = m.statusLocked(accountID, account)
+	}
+
+	now := m.now()
+	flatten := m.flatten
+	ctx := m.ctx
+	var events []EvaluationEvent
+	check := func(rule string, threshold, distance float64) {
+		event := EvaluationEvent{
+			Rule:      rule,
+			AccountID: accountID,
+			Equity:    status.Equity,
+			Threshold: threshold,
+			Distance:  distance,
+			Time:      now,
+		}
+
+		if distance <= 0 {
+			if !account.breached[rule] {
+				account.breached[rule] = true
+				event.Kind = EvaluationBreach
+				events = append(events, event)
+			}
+		} else {
+			account.breached[rule] = false
+		}
+
+		if account.warned[rule] == nil {
+			account.warned[rule] = make(map[float64]bool)
+		}
+		for _, buffer := range account.rules.WarningBuffers {
+			if distance > buffer {
+				account.warned[rule][buffer] = false
+				continue
+			}
+			if !account.warned[rule][buffer] {
+				account.warned[rule][buffer] = true
+				event.Kind = EvaluationWarning
+				event.Buffer = buffer
+				events = append(events, event)
+			}
+		}
+
+		if buffer := account.rules.FlattenBuffer; buffer > 0 && flatten != nil {
+			if distance > buffer {
+				account.flattened[rule] = false
+			} else if !account.flattened[rule] {
+				account.flattened[rule] = true
+				event.Kind = EvaluationFlatten
+				event.Buffer = buffer
+				events = append(events, event)
+			}
+		}
+	}
+
+	check(EvaluationRuleMaxLoss, status.MaxLossThreshold, status.MaxLossDistance)
+	if account.rules.DailyLossLimit > 0 {
+		check(EvaluationRuleDailyLoss, status.DailyLossThreshold, status.DailyLossDistance)
+	}
+
+	m.mu.Unlock()
+
+	for _, event := range events {
+		if event.Kind == EvaluationFlatten {
+			go m.runFlatten(ctx, flatten, event)
+			continue
+		}
+		m.emit(event)
+	}
+}
+
+func (m *EvaluationMonitor) runFlatten(ctx context.Context, flatten func(ctx context.Context, accountID int32) error, event EvaluationEvent) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	event.Err = flatten(context.WithoutCancel(ctx), event.AccountID)
+	m.emit(event)
+}
+
+// rolloverLocked starts a new trading day when one has begun and returns the
+// start of the current trading day.
+func (m *EvaluationMonitor) rolloverLocked(account *evaluationAccount) time.Time {
+	day := tradingDayStart(m.now(), account.rules.Location, account.rules.DayStart)
+	if account.day.Equal(day) {
+		return day
+	}
+
+	if !account.day.IsZero() && account.hasBalance {
+		if account.rules.Mode == DrawdownEndOfDay && account.balance > account.highWater {
+			account.highWater = account.balance
+		}
+		account.dayStartBalance = account.balance
+		account.realized = 0
+		account.realizedSynced = m.trades != nil
+		account.warned[EvaluationRuleDailyLoss] = nil
+		account.flattened[EvaluationRuleDailyLoss] = false
+		account.breached[EvaluationRuleDailyLoss] = false
+	}
+	account.day = day
+	return day
+}
+
+func (m *EvaluationMonitor) statusLocked(accountID int32, account *evaluationAccount) EvaluationStatus {
+	rules := account.rules
+	status := EvaluationStatus{
+		AccountID:       accountID,
+		TradingDay:      account.day,
+		Balance:         account.balance,
+		UnrealizedPnL:   account.unrealized,
+		Equity:          account.balance + account.unrealized,
+		HighWater:       account.highWater,
+		DayStartBalance: account.dayStartBalance,
+		RealizedPnL:     account.balance - account.dayStartBalance,
+	}
+	if account.realizedSynced {
+		status.RealizedPnL = account.realized
+		status.DayStartBalance = account.balance - account.realized
+	}
+	status.DailyPnL = status.RealizedPnL + status.UnrealizedPnL
+
+	status.MaxLossThreshold = account.highWater - rules.MaxLoss
+	if rules.LockThreshold != 0 && status.MaxLossThreshold > rules.LockThreshold {
+		status.MaxLossThreshold = rules.LockThreshold
+	}
+	status.MaxLossDistance = status.Equity - status.MaxLossThreshold
+
+	if rules.DailyLossLimit > 0 {
+		status.DailyLossDistance = status.DailyPnL + rules.DailyLossLimit
+		status.DailyLossThreshold = status.Equity - status.DailyLossDistance
+	}
+
+	status.Breached = status.MaxLossDistance <= 0 || (rules.DailyLossLimit > 0 && status.DailyLossDistance <= 0)
+	return status
+}
+
+func (m *EvaluationMonitor) Status(accountID int32) (EvaluationStatus, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	account, ok := m.accounts[accountID]
+	if !ok || !account.hasBalance {
+		return EvaluationStatus{}, false
+	}
+	return m.statusLocked(accountID, account), true
+}
+
+func (m *EvaluationMonitor) accountIDs() []int32 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := make([]int32, 0, len(m.accounts))
+	for accountID := range m.accounts {
+		result = append(result, accountID)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i] < result[j]
+	})
+	return result
+}
+
+func (m *EvaluationMonitor) emit(event EvaluationEvent) {
+	for _, handler := range m.events.get(subscribeAll) {
+		handler(event)
+	}
+}
+
+func (m *EvaluationMonitor) reportError(err error) {
+	m.mu.Lock()
+	handler := m.errorHandler
+	m.mu.Unlock()
+
+	if handler != nil {
+		handler(err)
+	}
+}
+
+// tradingDayStart returns the most recent start of a trading day at or
+// before t, where days begin dayStart after midnight in loc.
+func tradingDayStart(t time.Time, loc *time.Location, dayStart time.Duration) time.Time {
+	local := t.In(loc)
+	start := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc).Add(dayStart)
+	if start.After(local) {
+		start = time.Date(local.Year(), local.Month(), local.Day()-1, 0, 0, 0, 0, loc).Add(dayStart)
+	}
+	return start
+}
@@ -18,7 +18,7 @@ func NewHistoryService(c *client.Client) *HistoryService {
 
 func (s *HistoryService) GetBars(ctx context.Context, req *models.RetrieveBarRequest) (*models.RetrieveBarResponse, error) {
 	var resp models.RetrieveBarResponse
-	err := s.client.DoJSON(ctx, &client.Request{
+	raw, err := s.client.DoJSONResponse(ctx, &client.Request{
 		Method: http.MethodPost,
 		Path:   "/api/History/retrieveBars",
 		Body:   req,
@@ -27,5 +27,9 @@ func (s *HistoryService) GetBars(ctx context.Context, req *models.RetrieveBarReq
 		return nil, err
 	}
 
+	if err := checkResponse(s.client, raw, resp.Success, int(resp.ErrorCode), resp.ErrorMessage, retrieveBarError(resp.ErrorCode)); err != nil {
+		return &resp, err
+	}
+
 	return &resp, nil
 }
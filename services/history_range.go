@@ -0,0 +1,236 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"sort"
+	"time"
+
+	"github.com/tradingiq/projectx-client/models"
+)
+
+const (
+	// DefaultBarsPerRequest stays below the server's per-request bar limit.
+	DefaultBarsPerRequest = 10000
+	DefaultBarConcurrency = 4
+)
+
+// ErrBarsTruncated is returned when a window still fills the per-request limit
+// after it was split down to the smallest window the API accepts.
+var ErrBarsTruncated = errors.New("bar window truncated by request limit")
+
+type barRangeConfig struct {
+	barsPerRequest int32
+	concurrency    int
+	window         time.Duration
+}
+
+type BarRangeOption func(*barRangeConfig)
+
+// WithBarsPerRequest sets the Limit of each window request. Windows are sized
+// so that they hold at most this many bars.
+func WithBarsPerRequest(n int32) BarRangeOption {
+	return func(c *barRangeConfig) {
+		c.barsPerRequest = n
+	}
+}
+
+// WithBarConcurrency bounds the number of window requests in flight.
+func WithBarConcurrency(n int) BarRangeOption {
+	return func(c *barRangeConfig) {
+		c.concurrency = n
+	}
+}
+
+// WithBarWindow overrides the window length derived from the bar unit.
+func WithBarWindow(window time.Duration) BarRangeOption {
+	return func(c *barRangeConfig) {
+		c.window = window
+	}
+}
+
+type barWindow struct {
+	start, end time.Time
+	partial    bool
+}
+
+type barWindowResult struct {
+	bars []models.AggregateBarModel
+	err  error
+}
+
+// Bars yields the bars of req between StartTime and EndTime in ascending time
+// order. The range is split into windows sized for the bar unit, fetched with
+// bounded concurrency, and bars repeated at window edges are dropped. A window
+// that comes back full is split in half and fetched again, so the server limit
+// never silently truncates the range; a window that cannot be split further
+// fails with ErrBarsTruncated. Iteration stops after the first error.
+// IncludePartialBar only applies to the last window.
+func (s *HistoryService) Bars(ctx context.Context, req *models.RetrieveBarRequest, opts ...BarRangeOption) iter.Seq2[models.AggregateBarModel, error] {
+	return func(yield func(models.AggregateBarModel, error) bool) {
+		cfg := barRangeConfig{
+			barsPerRequest: DefaultBarsPerRequest,
+			concurrency:    DefaultBarConcurrency,
+		}
+		for _, opt := range opts {
+			opt(&cfg)
+		}
+		if cfg.concurrency < 1 {
+			cfg.concurrency = 1
+		}
+		if req.Limit > 0 && req.Limit < cfg.barsPerRequest {
+			cfg.barsPerRequest = req.Limit
+		}
+
+		windows, err := splitBarRange(req, cfg)
+		if err != nil {
+			yield(models.AggregateBarModel{}, err)
+			return
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		results := make([]chan barWindowResult, len(windows))
+		for i := range results {
+			results[i] = make(chan barWindowResult, 1)
+		}
+		// Windows are fetched at most cfg.concurrency ahead of the one being
+		// yielded, which bounds both requests in flight and buffered bars.
+		launched := 0
+		launch := func(next int) {
+			for ; launched < len(windows) && launched < next+cfg.concurrency; launched++ {
+				i := launched
+				go func() {
+					bars, err := s.fetchBarWindow(ctx, req, windows[i], cfg.barsPerRequest)
+					results[i] <- barWindowResult{bars: bars, err: err}
+				}()
+			}
+		}
+
+		var last time.Time
+		for i := range windows {
+			launch(i)
+			result := <-results[i]
+			if result.err != nil {
+				yield(models.AggregateBarModel{}, result.err)
+				return
+			}
+			for _, bar := range result.bars {
+				if !last.IsZero() && !bar.T.After(last) {
+					continue
+				}
+				last = bar.T
+				if !yield(bar, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// GetBarRange collects Bars into a slice.
+func (s *HistoryService) GetBarRange(ctx context.Context, req *models.RetrieveBarRequest, opts ...BarRangeOption) ([]models.AggregateBarModel, error) {
+	var result []models.AggregateBarModel
+	for bar, err := range s.Bars(ctx, req, opts...) {
+		if err != nil {
+			return result, err
+		}
+		result = append(result, bar)
+	}
+	return result, nil
+}
+
+func (s *HistoryService) fetchBarWindow(ctx context.Context, req *models.RetrieveBarRequest, window barWindow, limit int32) ([]models.AggregateBarModel, error) {
+	windowReq := *req
+	windowReq.StartTime = window.start
+	windowReq.EndTime = window.end
+	windowReq.Limit = limit
+	windowReq.IncludePartialBar = req.IncludePartialBar && window.partial
+
+	resp, err := s.GetBars(ctx, &windowReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve bars from %s to %s: %w", window.start.Format(time.RFC3339), window.end.Format(time.RFC3339), err)
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("failed to retrieve bars from %s to %s: %w", window.start.Format(time.RFC3339), window.end.Format(time.RFC3339), retrieveBarError(resp.ErrorCode))
+	}
+
+	bars := resp.Bars
+	if int32(len(bars)) >= limit {
+		mid := window.start.Add(window.end.Sub(window.start) / 2).Truncate(time.Second)
+		if !mid.After(window.start) || !mid.Before(window.end) {
+			return nil, fmt.Errorf("failed to retrieve bars from %s to %s: %w: %d bars",
+				window.start.Format(time.RFC3339), window.end.Format(time.RFC3339), ErrBarsTruncated, len(bars))
+		}
+		first, err := s.fetchBarWindow(ctx, req, barWindow{start: window.start, end: mid}, limit)
+		if err != nil {
+			return nil, err
+		}
+		second, err := s.fetchBarWindow(ctx, req, barWindow{start: mid, end: window.end, partial: window.partial}, limit)
+		if err != nil {
+			return nil, err
+		}
+		bars = append(first, second...)
+	}
+
+	sort.SliceStable(bars, func(i, j int) bool {
+		return bars[i].T.Before(bars[j].T)
+	})
+	return bars, nil
+}
+
+func splitBarRange(req *models.RetrieveBarRequest, cfg barRangeConfig) ([]barWindow, error) {
+	if !req.EndTime.After(req.StartTime) {
+		return nil, fmt.Errorf("bar range end %s is not after start %s", req.EndTime.Format(time.RFC3339), req.StartTime.Format(time.RFC3339))
+	}
+
+	window := cfg.window
+	if window <= 0 {
+		duration, err := BarDuration(req.Unit, req.UnitNumber)
+		if err != nil {
+			return nil, err
+		}
+		window = duration * time.Duration(cfg.barsPerRequest)
+	}
+
+	var windows []barWindow
+	for start := req.StartTime; start.Before(req.EndTime); start = start.Add(window) {
+		end := start.Add(window)
+		if !end.Before(req.EndTime) {
+			end = req.EndTime
+		}
+		windows = append(windows, barWindow{start: start, end: end})
+	}
+	windows[len(windows)-1].partial = true
+	return windows, nil
+}
+
+// BarDuration is the shortest length of one bar, so that a window sized with
+// it never holds more bars than intended. Months count as 28 days.
+func BarDuration(unit models.AggregateBarUnit, unitNumber int32) (time.Duration, error) {
+	if unitNumber <= 0 {
+		return 0, fmt.Errorf("unit number must be positive, got %d", unitNumber)
+	}
+
+	var base time.Duration
+	switch unit {
+	case models.AggregateBarUnitSecond:
+		base = time.Second
+	case models.AggregateBarUnitMinute:
+		base = time.Minute
+	case models.AggregateBarUnitHour:
+		base = time.Hour
+	case models.AggregateBarUnitDay:
+		base = 24 * time.Hour
+	case models.AggregateBarUnitWeek:
+		base = 7 * 24 * time.Hour
+	case models.AggregateBarUnitMonth:
+		base = 28 * 24 * time.Hour
+	default:
+		return 0, fmt.Errorf("unsupported bar unit %d", unit)
+	}
+	return base * time.Duration(unitNumber), nil
+}
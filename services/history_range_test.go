@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tradingiq/projectx-client/models"
+)
+
+var historyTestStart = time.Date(2025, 3, 14, 14, 0, 0, 0, time.UTC)
+
+func minuteBars(from time.Time, n int) models.RetrieveBarRequest {
+	return models.RetrieveBarRequest{
+		ContractID: riskTestContract,
+		StartTime:  from,
+		EndTime:    from.Add(time.Duration(n) * time.Minute),
+		Unit:       models.AggregateBarUnitMinute,
+		UnitNumber: 1,
+	}
+}
+
+// serveMinuteBars answers bar requests with one bar per minute from start to
+// end inclusive, newest first and cut to the limit like the API does.
+func serveMinuteBars(api *testAPI) {
+	api.handle("/api/History/retrieveBars", func(body []byte) interface{} {
+		var req models.RetrieveBarRequest
+		json.Unmarshal(body, &req)
+
+		var bars []models.AggregateBarModel
+		for t := req.EndTime.Truncate(time.Minute); !t.Before(req.StartTime); t = t.Add(-time.Minute) {
+			if int32(len(bars)) == req.Limit {
+				break
+			}
+			bars = append(bars, models.AggregateBarModel{T: t, Close: float64(t.Unix())})
+		}
+		return models.RetrieveBarResponse{Success: true, Bars: bars}
+	})
+}
+
+func TestSplitBarRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		bars    int
+		perReq  int32
+		window  time.Duration
+		windows []time.Duration
+	}{
+		{"single window", 10, 100, 0, []time.Duration{10 * time.Minute}},
+		{"exact multiple", 20, 10, 0, []time.Duration{10 * time.Minute, 10 * time.Minute}},
+		{"short last window", 25, 10, 0, []time.Duration{10 * time.Minute, 10 * time.Minute, 5 * time.Minute}},
+		{"window override", 90, 10000, time.Hour, []time.Duration{time.Hour, 30 * time.Minute}},
+	}
+	for _, test := range tests {
+		req := minuteBars(historyTestStart, test.bars)
+		windows, err := splitBarRange(&req, barRangeConfig{barsPerRequest: test.perReq, window: test.window})
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if len(windows) != len(test.windows) {
+			t.Fatalf("%s: %d windows, want %d", test.name, len(windows), len(test.windows))
+		}
+		start := req.StartTime
+		for i, window := range windows {
+			if !window.start.Equal(start) || window.end.Sub(window.start) != test.windows[i] {
+				t.Errorf("%s: window %d is %s to %s", test.name, i, window.start, window.end)
+			}
+			if window.partial != (i == len(windows)-1) {
+				t.Errorf("%s: window %d partial %v", test.name, i, window.partial)
+			}
+			start = window.end
+		}
+		if !start.Equal(req.EndTime) {
+			t.Errorf("%s: windows end at %s, want %s", test.name, start, req.EndTime)
+		}
+	}
+
+	req := minuteBars(historyTestStart, 0)
+	if _, err := splitBarRange(&req, barRangeConfig{barsPerRequest: 10}); err == nil {
+		t.Error("empty range split without error")
+	}
+}
+
+func TestBarsAcrossWindows(t *testing.T) {
+	tests := []struct {
+		name string
+		bars int
+		opts []BarRangeOption
+	}{
+		{"one window", 30, nil},
+		{"window edges", 95, []BarRangeOption{WithBarsPerRequest(10), WithBarConcurrency(3)}},
+		// The window holds 60 bars but only 25 fit a request, so every
+		// window comes back full and is split until it fits.
+		{"full windows split", 180, []BarRangeOption{WithBarsPerRequest(25), WithBarWindow(time.Hour)}},
+	}
+	for _, test := range tests {
+		api, c := newTestAPI(t)
+		serveMinuteBars(api)
+
+		req := minuteBars(historyTestStart, test.bars)
+		bars, err := NewHistoryService(c).GetBarRange(context.Background(), &req, test.opts...)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		// Both ends are inclusive, so the range holds one bar more.
+		if len(bars) != test.bars+1 {
+			t.Errorf("%s: %d bars, want %d", test.name, len(bars), test.bars+1)
+		}
+		for i, bar := range bars {
+			if want := historyTestStart.Add(time.Duration(i) * time.Minute); !bar.T.Equal(want) {
+				t.Errorf("%s: bar %d at %s, want %s", test.name, i, bar.T, want)
+				break
+			}
+		}
+	}
+}
+
+func TestBarsFailInsteadOfTruncating(t *testing.T) {
+	api, c := newTestAPI(t)
+	// Every answer fills the limit, however small the window.
+	api.handle("/api/History/retrieveBars", func(body []byte) interface{} {
+		var req models.RetrieveBarRequest
+		json.Unmarshal(body, &req)
+		bars := make([]models.AggregateBarModel, req.Limit)
+		for i := range bars {
+			bars[i].T = req.StartTime
+		}
+		return models.RetrieveBarResponse{Success: true, Bars: bars}
+	})
+
+	req := minuteBars(historyTestStart, 60)
+	bars, err := NewHistoryService(c).GetBarRange(context.Background(), &req, WithBarsPerRequest(10))
+	if !errors.Is(err, ErrBarsTruncated) {
+		t.Errorf("error %v, want ErrBarsTruncated", err)
+	}
+	if len(bars) != 0 {
+		t.Errorf("%d bars returned with a truncated first window", len(bars))
+	}
+}
@@ -0,0 +1,32 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/philippseith/signalr"
+	"github.com/tradingiq/projectx-client/client"
+)
+
+// hubConnector reads the token on every dial so that reconnects, including
+// the ones signalr performs internally, pick up a refreshed session token.
+func hubConnector(ctx context.Context, c *client.Client, hubURL string) func() (signalr.Connection, error) {
+	return func() (signalr.Connection, error) {
+		token, err := c.Token(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get token: %w", err)
+		}
+		if token == "" {
+			return nil, fmt.Errorf("authentication token not set")
+		}
+
+		return signalr.HttpConnectionFactory(ctx, fmt.Sprintf(hubURL, token),
+			signalr.WithHTTPHeaders(func() http.Header {
+				headers := http.Header{}
+				headers.Set("Authorization", "Bearer "+token)
+				return headers
+			}),
+		)
+	}
+}
@@ -0,0 +1,361 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/tradingiq/projectx-client/models"
+)
+
+const DefaultStreamBufferSize = 256
+
+type OverflowPolicy int
+
+const (
+	// OverflowDropOldest discards the oldest buffered event to make room.
+	OverflowDropOldest OverflowPolicy = iota
+	// OverflowDropNewest discards the incoming event when the buffer is full.
+	OverflowDropNewest
+	// OverflowBlock waits for the consumer, stalling the hub receive loop.
+	OverflowBlock
+	// OverflowConflate keeps only the latest undelivered event per contract.
+	OverflowConflate
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowDropOldest:
+		return "DROP_OLDEST"
+	case OverflowDropNewest:
+		return "DROP_NEWEST"
+	case OverflowBlock:
+		return "BLOCK"
+	case OverflowConflate:
+		return "CONFLATE"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+type QuoteEvent struct {
+	ContractID string
+	Quote      models.Quote
+	Changed    models.QuoteField
+}
+
+type TradeEvent struct {
+	ContractID string
+	Trades     models.TradeData
+}
+
+type DepthEvent struct {
+	ContractID string
+	Depth      models.MarketDepthData
+}
+
+type StreamStats struct {
+	ID         uint64
+	Kind       string
+	ContractID string
+	Policy     OverflowPolicy
+	Published  uint64
+	Dropped    uint64
+	Pending    int
+}
+
+type streamConfig struct {
+	bufferSize int
+	policy     OverflowPolicy
+}
+
+type StreamOption func(*streamConfig)
+
+func WithStreamBuffer(size int) StreamOption {
+	return func(c *streamConfig) {
+		c.bufferSize = size
+	}
+}
+
+func WithOverflowPolicy(policy OverflowPolicy) StreamOption {
+	return func(c *streamConfig) {
+		c.policy = policy
+	}
+}
+
+type statsProvider interface {
+	stats() StreamStats
+}
+
+type marketDataStreams struct {
+	mu      sync.Mutex
+	nextID  uint64
+	streams map[uint64]statsProvider
+}
+
+func (m *marketDataStreams) register(stream statsProvider) uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.streams == nil {
+		m.streams = make(map[uint64]statsProvider)
+	}
+	m.nextID++
+	m.streams[m.nextID] = stream
+	return m.nextID
+}
+
+func (m *marketDataStreams) unregister(id uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.streams, id)
+}
+
+func (m *marketDataStreams) all() []StreamStats {
+	m.mu.Lock()
+	providers := make(map[uint64]statsProvider, len(m.streams))
+	for id, stream := range m.streams {
+		providers[id] = stream
+	}
+	m.mu.Unlock()
+
+	result := make([]StreamStats, 0, len(providers))
+	for id, stream := range providers {
+		stats := stream.stats()
+		stats.ID = id
+		result = append(result, stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+type eventStream[T any] struct {
+	kind       string
+	contractID string
+	policy     OverflowPolicy
+	ctx        context.Context
+	out        chan T
+
+	mu      sync.Mutex
+	closed  bool
+	senders sync.WaitGroup
+	pending map[string]T
+	order   []string
+	notify  chan struct{}
+
+	published atomic.Uint64
+	dropped   atomic.Uint64
+}
+
+func newEventStream[T any](ctx context.Context, kind, contractID string, opts []StreamOption) *eventStream[T] {
+	cfg := streamConfig{
+		bufferSize: DefaultStreamBufferSize,
+		policy:     OverflowDropOldest,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.bufferSize < 1 {
+		cfg.bufferSize = 1
+	}
+
+	s := &eventStream[T]{
+		kind:       kind,
+		contractID: contractID,
+		policy:     cfg.policy,
+		ctx:        ctx,
+	}
+
+	if cfg.policy == OverflowConflate {
+		// Conflated events wait in pending so that the consumer always
+		// receives the latest value rather than a stale buffered one.
+		s.out = make(chan T)
+		s.pending = make(map[string]T)
+		s.notify = make(chan struct{}, 1)
+	} else {
+		s.out = make(chan T, cfg.bufferSize)
+	}
+
+	return s
+}
+
+func (s *eventStream[T]) publish(key string, event T) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.published.Add(1)
+
+	if s.policy == OverflowBlock {
+		// The send happens outside the lock so that a slow consumer only
+		// holds up its own publisher; run waits for it before closing out.
+		s.senders.Add(1)
+		s.mu.Unlock()
+		defer s.senders.Done()
+
+		select {
+		case s.out <- event:
+		case <-s.ctx.Done():
+		}
+		return
+	}
+	defer s.mu.Unlock()
+
+	switch s.policy {
+	case OverflowDropNewest:
+		select {
+		case s.out <- event:
+		default:
+			s.dropped.Add(1)
+		}
+	case OverflowConflate:
+		if _, ok := s.pending[key]; ok {
+			s.dropped.Add(1)
+		} else {
+			s.order = append(s.order, key)
+		}
+		s.pending[key] = event
+
+		select {
+		case s.notify <- struct{}{}:
+		default:
+		}
+	default:
+		for {
+			select {
+			case s.out <- event:
+				return
+			default:
+			}
+
+			select {
+			case <-s.out:
+				s.dropped.Add(1)
+			default:
+			}
+		}
+	}
+}
+
+func (s *eventStream[T]) run(unsubscribe func(), done func()) {
+	defer done()
+
+	if s.policy != OverflowConflate {
+		<-s.ctx.Done()
+		unsubscribe()
+
+		s.mu.Lock()
+		s.closed = true
+		s.mu.Unlock()
+		s.senders.Wait()
+		close(s.out)
+		return
+	}
+
+	defer func() {
+		unsubscribe()
+
+		s.mu.Lock()
+		s.closed = true
+		s.mu.Unlock()
+		close(s.out)
+	}()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-s.notify:
+		}
+
+		for {
+			s.mu.Lock()
+			if len(s.order) == 0 {
+				s.mu.Unlock()
+				break
+			}
+			key := s.order[0]
+			s.order = s.order[1:]
+			event := s.pending[key]
+			delete(s.pending, key)
+			s.mu.Unlock()
+
+			select {
+			case s.out <- event:
+			case <-s.ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+func (s *eventStream[T]) stats() StreamStats {
+	s.mu.Lock()
+	pending := len(s.out) + len(s.pending)
+	s.mu.Unlock()
+
+	return StreamStats{
+		Kind:       s.kind,
+		ContractID: s.contractID,
+		Policy:     s.policy,
+		Published:  s.published.Load(),
+		Dropped:    s.dropped.Load(),
+		Pending:    pending,
+	}
+}
+
+// Quotes delivers merged quote updates for contractID, or for every contract
+// with AllContracts, on a dedicated buffered channel that is closed when ctx
+// is done.
+func (s *MarketDataWebSocketService) Quotes(ctx context.Context, contractID string, opts ...StreamOption) <-chan QuoteEvent {
+	stream := newEventStream[QuoteEvent](ctx, "quotes", contractID, opts)
+	id := s.streams.register(stream)
+
+	unsubscribe := s.OnQuoteUpdate(contractID, func(contractID string, quote models.Quote, changed models.QuoteField) {
+		stream.publish(contractID, QuoteEvent{ContractID: contractID, Quote: quote, Changed: changed})
+	})
+	go stream.run(unsubscribe, func() { s.streams.unregister(id) })
+
+	return stream.out
+}
+
+func (s *MarketDataWebSocketService) Trades(ctx context.Context, contractID string, opts ...StreamOption) <-chan TradeEvent {
+	stream := newEventStream[TradeEvent](ctx, "trades", contractID, opts)
+	id := s.streams.register(stream)
+
+	unsubscribe := s.OnTrade(contractID, func(contractID string, trades models.TradeData) {
+		stream.publish(contractID, TradeEvent{ContractID: contractID, Trades: trades})
+	})
+	go stream.run(unsubscribe, func() { s.streams.unregister(id) })
+
+	return stream.out
+}
+
+func (s *MarketDataWebSocketService) Depth(ctx context.Context, contractID string, opts ...StreamOption) <-chan DepthEvent {
+	stream := newEventStream[DepthEvent](ctx, "depth", contractID, opts)
+	id := s.streams.register(stream)
+
+	unsubscribe := s.OnDepth(contractID, func(contractID string, depth models.MarketDepthData) {
+		stream.publish(contractID, DepthEvent{ContractID: contractID, Depth: depth})
+	})
+	go stream.run(unsubscribe, func() { s.streams.unregister(id) })
+
+	return stream.out
+}
+
+// StreamStats reports per-consumer counters for all open channel streams.
+func (s *MarketDataWebSocketService) StreamStats() []StreamStats {
+	return s.streams.all()
+}
+
+func (s *MarketDataWebSocketService) DroppedMessages() uint64 {
+	var total uint64
+	for _, stats := range s.streams.all() {
+		total += stats.Dropped
+	}
+	return total
+}
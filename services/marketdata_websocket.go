@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
 	"sync"
 	"time"
 
@@ -39,19 +38,31 @@ type MarketDataWebSocketService struct {
 	connectionHandler func(ConnectionState)
 	maxReconnectDelay time.Duration
 	reconnectAttempts int
+	streams           marketDataStreams
 }
 
 type MarketDataReceiver struct {
-	quoteHandler func(string, models.Quote)
-	tradeHandler func(string, models.TradeData)
-	depthHandler func(string, models.MarketDepthData)
-	mu           sync.RWMutex
-	service      *MarketDataWebSocketService
+	quoteHandler       func(string, models.Quote)
+	quoteUpdateHandler func(string, models.Quote, models.QuoteField)
+	tradeHandler       func(string, models.TradeData)
+	depthHandler       func(string, models.MarketDepthData)
+	recorder           HubRecorder
+	quoteSubscribers   *subscribers[func(string, models.Quote, models.QuoteField)]
+	tradeSubscribers   *subscribers[func(string, models.TradeData)]
+	depthSubscribers   *subscribers[func(string, models.MarketDepthData)]
+	mu                 sync.RWMutex
+	quotes             map[string]models.Quote
+	quotesMu           sync.Mutex
+	service            *MarketDataWebSocketService
 }
 
 func NewMarketDataReceiver(service *MarketDataWebSocketService) *MarketDataReceiver {
 	return &MarketDataReceiver{
-		service: service,
+		quoteSubscribers: newSubscribers[func(string, models.Quote, models.QuoteField)](),
+		tradeSubscribers: newSubscribers[func(string, models.TradeData)](),
+		depthSubscribers: newSubscribers[func(string, models.MarketDepthData)](),
+		quotes:           make(map[string]models.Quote),
+		service:          service,
 	}
 }
 
@@ -61,6 +72,43 @@ func (r *MarketDataReceiver) SetQuoteHandler(handler func(string, models.Quote))
 	r.quoteHandler = handler
 }
 
+func (r *MarketDataReceiver) SetQuoteUpdateHandler(handler func(string, models.Quote, models.QuoteField)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.quoteUpdateHandler = handler
+}
+
+func (r *MarketDataReceiver) OnQuote(contractID string, handler func(string, models.Quote)) func() {
+	return r.quoteSubscribers.add(contractID, func(contractID string, quote models.Quote, _ models.QuoteField) {
+		handler(contractID, quote)
+	})
+}
+
+func (r *MarketDataReceiver) OnQuoteUpdate(contractID string, handler func(string, models.Quote, models.QuoteField)) func() {
+	return r.quoteSubscribers.add(contractID, handler)
+}
+
+func (r *MarketDataReceiver) OnTrade(contractID string, handler func(string, models.TradeData)) func() {
+	return r.tradeSubscribers.add(contractID, handler)
+}
+
+func (r *MarketDataReceiver) OnDepth(contractID string, handler func(string, models.MarketDepthData)) func() {
+	return r.depthSubscribers.add(contractID, handler)
+}
+
+func (r *MarketDataReceiver) LatestQuote(contractID string) (models.Quote, bool) {
+	r.quotesMu.Lock()
+	defer r.quotesMu.Unlock()
+	quote, ok := r.quotes[contractID]
+	return quote, ok
+}
+
+func (r *MarketDataReceiver) clearQuote(contractID string) {
+	r.quotesMu.Lock()
+	defer r.quotesMu.Unlock()
+	delete(r.quotes, contractID)
+}
+
 func (r *MarketDataReceiver) SetTradeHandler(handler func(string, models.TradeData)) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -90,31 +138,72 @@ func (r *MarketDataReceiver) ConnectionClosed() {
 	}
 }
 
+// SetRecorder passes every hub message to recorder before it is decoded.
+// Pass nil to stop recording.
+func (r *MarketDataReceiver) SetRecorder(recorder HubRecorder) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.recorder = recorder
+}
+
+func (r *MarketDataReceiver) record(method, contractID string, payload []byte, received time.Time) {
+	r.mu.RLock()
+	recorder := r.recorder
+	r.mu.RUnlock()
+
+	if recorder != nil {
+		recorder.RecordHubMessage(HubMessage{
+			Hub:        HubMarket,
+			Method:     method,
+			ContractID: contractID,
+			Payload:    payload,
+			Received:   received,
+		})
+	}
+}
+
 func (r *MarketDataReceiver) GatewayQuote(contractID string, data interface{}) {
+	received := time.Now()
 	jsonData, err := json.Marshal(data)
 	if err != nil {
 		return
 	}
+	r.record("GatewayQuote", contractID, jsonData, received)
 
-	var quote models.Quote
-	if err := json.Unmarshal(jsonData, &quote); err != nil {
+	partial, present, err := models.ParsePartialQuote(jsonData)
+	if err != nil {
 		return
 	}
 
+	r.quotesMu.Lock()
+	quote := r.quotes[contractID]
+	changed := quote.Merge(partial, present)
+	r.quotes[contractID] = quote
+	r.quotesMu.Unlock()
+
 	r.mu.RLock()
 	handler := r.quoteHandler
+	updateHandler := r.quoteUpdateHandler
 	r.mu.RUnlock()
 
 	if handler != nil {
 		handler(contractID, quote)
 	}
+	if updateHandler != nil {
+		updateHandler(contractID, quote, changed)
+	}
+	for _, subscriber := range r.quoteSubscribers.get(contractID) {
+		subscriber(contractID, quote, changed)
+	}
 }
 
 func (r *MarketDataReceiver) GatewayTrade(contractID string, data interface{}) {
+	received := time.Now()
 	jsonData, err := json.Marshal(data)
 	if err != nil {
 		return
 	}
+	r.record("GatewayTrade", contractID, jsonData, received)
 
 	var trades models.TradeData
 	if err := json.Unmarshal(jsonData, &trades); err != nil {
@@ -128,13 +217,18 @@ func (r *MarketDataReceiver) GatewayTrade(contractID string, data interface{}) {
 	if handler != nil {
 		handler(contractID, trades)
 	}
+	for _, subscriber := range r.tradeSubscribers.get(contractID) {
+		subscriber(contractID, trades)
+	}
 }
 
 func (r *MarketDataReceiver) GatewayDepth(contractID string, data interface{}) {
+	received := time.Now()
 	jsonData, err := json.Marshal(data)
 	if err != nil {
 		return
 	}
+	r.record("GatewayDepth", contractID, jsonData, received)
 
 	var depth models.MarketDepthData
 	if err := json.Unmarshal(jsonData, &depth); err != nil {
@@ -148,6 +242,9 @@ func (r *MarketDataReceiver) GatewayDepth(contractID string, data interface{}) {
 	if handler != nil {
 		handler(contractID, depth)
 	}
+	for _, subscriber := range r.depthSubscribers.get(contractID) {
+		subscriber(contractID, depth)
+	}
 }
 
 func NewMarketDataWebSocketService(c *client.Client) *MarketDataWebSocketService {
@@ -173,20 +270,18 @@ func (s *MarketDataWebSocketService) Connect(ctx context.Context) error {
 	s.ctx, s.cancel = context.WithCancel(ctx)
 	s.setState(StateConnecting)
 
-	token := s.client.GetToken()
+	token, err := s.client.Token(s.ctx)
+	if err != nil {
+		s.setState(StateDisconnected)
+		return fmt.Errorf("failed to get token: %w", err)
+	}
 	if token == "" {
 		s.setState(StateDisconnected)
 		return fmt.Errorf("authentication token not set")
 	}
 
 	conn, err := signalr.NewClient(s.ctx,
-		signalr.WithHttpConnection(s.ctx, fmt.Sprintf(MarketHubURL, token),
-			signalr.WithHTTPHeaders(func() http.Header {
-				headers := http.Header{}
-				headers.Set("Authorization", "Bearer "+token)
-				return headers
-			}),
-		),
+		signalr.WithConnector(hubConnector(s.ctx, s.client, MarketHubURL)),
 		signalr.WithReceiver(s.receiver),
 		signalr.MaximumReceiveMessageSize(1024*1024),
 		signalr.Logger(newNoopLogger(), false),
@@ -289,6 +384,8 @@ func (s *MarketDataWebSocketService) UnsubscribeContractQuotes(contractID string
 		return fmt.Errorf("failed to unsubscribe from quotes for %s: %w", contractID, result.Error)
 	}
 
+	s.receiver.clearQuote(contractID)
+
 	if s.subscriptions[contractID] != nil {
 		delete(s.subscriptions[contractID], "quotes")
 		if len(s.subscriptions[contractID]) == 0 {
@@ -428,6 +525,34 @@ func (s *MarketDataWebSocketService) SetQuoteHandler(handler func(string, models
 	s.receiver.SetQuoteHandler(handler)
 }
 
+// SetQuoteUpdateHandler receives the merged quote together with the fields
+// that changed in this update.
+func (s *MarketDataWebSocketService) SetQuoteUpdateHandler(handler func(string, models.Quote, models.QuoteField)) {
+	s.receiver.SetQuoteUpdateHandler(handler)
+}
+
+// OnQuote registers an additional quote handler for contractID, or for every
+// contract with AllContracts, and returns a function that removes it.
+func (s *MarketDataWebSocketService) OnQuote(contractID string, handler func(string, models.Quote)) func() {
+	return s.receiver.OnQuote(contractID, handler)
+}
+
+func (s *MarketDataWebSocketService) OnQuoteUpdate(contractID string, handler func(string, models.Quote, models.QuoteField)) func() {
+	return s.receiver.OnQuoteUpdate(contractID, handler)
+}
+
+func (s *MarketDataWebSocketService) OnTrade(contractID string, handler func(string, models.TradeData)) func() {
+	return s.receiver.OnTrade(contractID, handler)
+}
+
+func (s *MarketDataWebSocketService) OnDepth(contractID string, handler func(string, models.MarketDepthData)) func() {
+	return s.receiver.OnDepth(contractID, handler)
+}
+
+func (s *MarketDataWebSocketService) LatestQuote(contractID string) (models.Quote, bool) {
+	return s.receiver.LatestQuote(contractID)
+}
+
 func (s *MarketDataWebSocketService) SetTradeHandler(handler func(string, models.TradeData)) {
 	s.receiver.SetTradeHandler(handler)
 }
@@ -436,6 +561,12 @@ func (s *MarketDataWebSocketService) SetDepthHandler(handler func(string, models
 	s.receiver.SetDepthHandler(handler)
 }
 
+// SetRecorder passes every raw hub message to recorder, alongside the
+// handlers.
+func (s *MarketDataWebSocketService) SetRecorder(recorder HubRecorder) {
+	s.receiver.SetRecorder(recorder)
+}
+
 func (s *MarketDataWebSocketService) handleReconnection() {
 
 	for {
@@ -520,19 +651,13 @@ func (s *MarketDataWebSocketService) reconnect() {
 		}
 		s.mu.Unlock()
 
-		token := s.client.GetToken()
-		if token == "" {
+		token, err := s.client.Token(s.ctx)
+		if err != nil || token == "" {
 			continue
 		}
 
 		conn, err := signalr.NewClient(s.ctx,
-			signalr.WithHttpConnection(s.ctx, fmt.Sprintf(MarketHubURL, token),
-				signalr.WithHTTPHeaders(func() http.Header {
-					headers := http.Header{}
-					headers.Set("Authorization", "Bearer "+token)
-					return headers
-				}),
-			),
+			signalr.WithConnector(hubConnector(s.ctx, s.client, MarketHubURL)),
 			signalr.WithReceiver(s.receiver),
 			signalr.MaximumReceiveMessageSize(1024*1024),
 			signalr.Logger(newNoopLogger(), false),
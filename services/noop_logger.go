@@ -8,4 +8,4 @@ func (n noopLogger) Log(keyvals ...interface{}) error {
 
 func newNoopLogger() noopLogger {
 	return noopLogger{}
-}
\ No newline at end of file
+}
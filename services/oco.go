@@ -0,0 +1,576 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tradingiq/projectx-client/models"
+)
+
+var ErrOrderInGroup = errors.New("order already belongs to an oco group")
+
+type OCOMember struct {
+	OrderID int32 `json:"orderId"`
+	// Size is the remaining size the member was last placed or modified with.
+	Size int32 `json:"size"`
+	// Filled is the fill volume already applied to the siblings.
+	Filled int32 `json:"filled"`
+}
+
+type OCOGroup struct {
+	ID        string      `json:"id"`
+	AccountID int32       `json:"accountId"`
+	CreatedAt time.Time   `json:"createdAt"`
+	Members   []OCOMember `json:"members"`
+}
+
+func (g *OCOGroup) member(orderID int32) *OCOMember {
+	for i := range g.Members {
+		if g.Members[i].OrderID == orderID {
+			return &g.Members[i]
+		}
+	}
+	return nil
+}
+
+func (g *OCOGroup) remove(orderID int32) {
+	for i := range g.Members {
+		if g.Members[i].OrderID == orderID {
+			g.Members = append(g.Members[:i], g.Members[i+1:]...)
+			return
+		}
+	}
+}
+
+func (g OCOGroup) clone() OCOGroup {
+	g.Members = append([]OCOMember(nil), g.Members...)
+	return g
+}
+
+// OCOStore persists group membership so protective orders survive a restart.
+type OCOStore interface {
+	Load() ([]OCOGroup, error)
+	Save(groups []OCOGroup) error
+}
+
+// FileOCOStore keeps the groups as JSON in a single file, replaced atomically
+// on every save.
+type FileOCOStore struct {
+	path string
+}
+
+func NewFileOCOStore(path string) *FileOCOStore {
+	return &FileOCOStore{path: path}
+}
+
+func (s *FileOCOStore) Load() ([]OCOGroup, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read oco groups: %w", err)
+	}
+
+	var groups []OCOGroup
+	if err := json.Unmarshal(data, &groups); err != nil {
+		return nil, fmt.Errorf("failed to decode oco groups: %w", err)
+	}
+	return groups, nil
+}
+
+func (s *FileOCOStore) Save(groups []OCOGroup) error {
+	data, err := json.MarshalIndent(groups, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode oco groups: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to save oco groups: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save oco groups: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save oco groups: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save oco groups: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to save oco groups: %w", err)
+	}
+	return nil
+}
+
+type ocoAction struct {
+	accountID int32
+	orderID   int32
+	// size is the new size of the order; zero cancels it.
+	size int32
+}
+
+// ocoBatch holds the sibling changes required by one order event. done, when
+// set, receives the outcome once the batch has run.
+type ocoBatch struct {
+	ctx     context.Context
+	actions []ocoAction
+	done    chan error
+}
+
+// OCOManager links orders the exchange does not link natively. When a member
+// of a group fills, its siblings are cancelled; a partial fill reduces the
+// siblings by the filled quantity with ModifyOrder. Sibling changes run on a
+// single worker in the order the events arrived, so a later reduction never
+// lands before an earlier one.
+type OCOManager struct {
+	orders   *OrderService
+	userData *UserDataWebSocketService
+	store    OCOStore
+
+	mu           sync.Mutex
+	groups       map[string]*OCOGroup
+	byOrder      map[int32]string
+	errorHandler func(error)
+	unsubscribe  []func()
+	ctx          context.Context
+	cancel       context.CancelFunc
+	queue        []ocoBatch
+	working      bool
+}
+
+// NewOCOManager creates a manager; store may be nil to keep groups in memory
+// only.
+func NewOCOManager(orders *OrderService, userData *UserDataWebSocketService, store OCOStore) *OCOManager {
+	return &OCOManager{
+		orders:   orders,
+		userData: userData,
+		store:    store,
+		groups:   make(map[string]*OCOGroup),
+		byOrder:  make(map[int32]string),
+	}
+}
+
+// Start loads persisted groups, applies order events from the user hub and
+// reconciles against REST now and after every reconnect, so fills that
+// happened while the process was down still cancel their siblings. The caller
+// remains responsible for SubscribeOrders on the user hub.
+func (m *OCOManager) Start(ctx context.Context) error {
+	m.mu.Lock()
+	if m.cancel != nil {
+		m.mu.Unlock()
+		return fmt.Errorf("oco manager already started")
+	}
+
+	if m.store != nil {
+		groups, err := m.store.Load()
+		if err != nil {
+			m.mu.Unlock()
+			return err
+		}
+		for _, group := range groups {
+			group := group.clone()
+			m.groups[group.ID] = &group
+			for _, member := range group.Members {
+				m.byOrder[member.OrderID] = group.ID
+			}
+		}
+	}
+
+	m.ctx, m.cancel = context.WithCancel(ctx)
+	m.unsubscribe = append(m.unsubscribe,
+		m.userData.OnOrder(m.HandleOrderUpdate),
+		m.userData.OnConnectionState(m.handleConnectionState),
+	)
+	m.mu.Unlock()
+
+	return m.Reconcile(ctx)
+}
+
+func (m *OCOManager) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, unsubscribe := range m.unsubscribe {
+		unsubscribe()
+	}
+	m.unsubscribe = nil
+
+	if m.cancel != nil {
+		m.cancel()
+		m.cancel = nil
+	}
+}
+
+func (m *OCOManager) SetErrorHandler(handler func(error)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.errorHandler = handler
+}
+
+// Add groups already placed open orders of accountID and returns the group
+// ID. Current sizes and fill volumes are read from the open orders endpoint.
+func (m *OCOManager) Add(ctx context.Context, accountID int32, orderIDs ...int32) (string, error) {
+	if len(orderIDs) < 2 {
+		return "", fmt.Errorf("oco group needs at least two orders, got %d", len(orderIDs))
+	}
+
+	resp, err := m.orders.SearchOpenOrders(ctx, &models.SearchOpenOrderRequest{AccountID: accountID})
+	if err != nil {
+		return "", fmt.Errorf("failed to search open orders: %w", err)
+	}
+	if !resp.Success {
+		return "", fmt.Errorf("failed to search open orders with error code: %v", resp.ErrorCode)
+	}
+	open := make(map[int32]models.OrderModel, len(resp.Orders))
+	for _, order := range resp.Orders {
+		open[order.ID] = order
+	}
+
+	group := &OCOGroup{
+		ID:        fmt.Sprintf("%d-%d", accountID, orderIDs[0]),
+		AccountID: accountID,
+		CreatedAt: time.Now().UTC(),
+	}
+	for _, orderID := range orderIDs {
+		order, ok := open[orderID]
+		if !ok {
+			return "", fmt.Errorf("order %d: %w", orderID, ErrOrderNotFound)
+		}
+		group.Members = append(group.Members, OCOMember{
+			OrderID: orderID,
+			Size:    order.Size - order.FillVolume,
+			Filled:  order.FillVolume,
+		})
+	}
+
+	m.mu.Lock()
+	for _, orderID := range orderIDs {
+		if _, ok := m.byOrder[orderID]; ok {
+			m.mu.Unlock()
+			return "", fmt.Errorf("order %d: %w", orderID, ErrOrderInGroup)
+		}
+	}
+	m.groups[group.ID] = group
+	for _, orderID := range orderIDs {
+		m.byOrder[orderID] = group.ID
+	}
+	err = m.saveLocked()
+	m.mu.Unlock()
+
+	return group.ID, err
+}
+
+// Remove forgets a group without touching its orders.
+func (m *OCOManager) Remove(groupID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	group, ok := m.groups[groupID]
+	if !ok {
+		return nil
+	}
+	m.deleteGroupLocked(group)
+	return m.saveLocked()
+}
+
+func (m *OCOManager) Groups() []OCOGroup {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.snapshotLocked()
+}
+
+func (m *OCOManager) handleConnectionState(state ConnectionState) {
+	if state != StateConnected {
+		return
+	}
+
+	m.mu.Lock()
+	ctx := m.ctx
+	m.mu.Unlock()
+	if ctx == nil {
+		return
+	}
+
+	if err := m.Reconcile(ctx); err != nil && ctx.Err() == nil {
+		m.reportError(err)
+	}
+}
+
+// HandleOrderUpdate can be passed to UserDataWebSocketService.OnOrder when the
+// manager is driven manually instead of through Start. Sibling cancels and
+// modifies go over REST and run off the hub receive loop, one event at a
+// time.
+func (m *OCOManager) HandleOrderUpdate(update *models.OrderUpdateData) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ctx := m.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	m.applyLocked(ctx, update.Data.Order(), nil)
+}
+
+// Reconcile applies the open orders of every grouped account and the final
+// state of members that are no longer open.
+func (m *OCOManager) Reconcile(ctx context.Context) error {
+	m.mu.Lock()
+	since := make(map[int32]time.Time)
+	for _, group := range m.groups {
+		if created, ok := since[group.AccountID]; !ok || group.CreatedAt.Before(created) {
+			since[group.AccountID] = group.CreatedAt
+		}
+	}
+	m.mu.Unlock()
+
+	var errs []error
+	for accountID, start := range since {
+		if err := m.reconcileAccount(ctx, accountID, start); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m *OCOManager) reconcileAccount(ctx context.Context, accountID int32, since time.Time) error {
+	resp, err := m.orders.SearchOpenOrders(ctx, &models.SearchOpenOrderRequest{AccountID: accountID})
+	if err != nil {
+		return fmt.Errorf("failed to search open orders: %w", err)
+	}
+	if !resp.Success {
+		return fmt.Errorf("failed to search open orders with error code: %v", resp.ErrorCode)
+	}
+
+	open := make(map[int32]bool, len(resp.Orders))
+	var pending []chan error
+	apply := func(order models.OrderModel) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if done := m.applyLocked(ctx, order, make(chan error, 1)); done != nil {
+			pending = append(pending, done)
+		}
+	}
+	for _, order := range resp.Orders {
+		open[order.ID] = true
+		apply(order)
+	}
+
+	missing := false
+	m.mu.Lock()
+	for orderID, groupID := range m.byOrder {
+		if m.groups[groupID].AccountID == accountID && !open[orderID] {
+			missing = true
+			break
+		}
+	}
+	m.mu.Unlock()
+
+	if missing {
+		history, err := m.orders.SearchOrders(ctx, &models.SearchOrderRequest{
+			AccountID:      accountID,
+			StartTimestamp: since.Add(-time.Minute),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to search orders: %w", err)
+		}
+		if !history.Success {
+			return fmt.Errorf("failed to search orders with error code: %v", history.ErrorCode)
+		}
+		for _, order := range history.Orders {
+			if !open[order.ID] {
+				apply(order)
+			}
+		}
+	}
+
+	var errs []error
+	for _, done := range pending {
+		select {
+		case err := <-done:
+			errs = append(errs, err)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// applyLocked updates the group of order and queues the sibling changes it
+// requires. It returns done when changes were queued.
+func (m *OCOManager) applyLocked(ctx context.Context, order models.OrderModel, done chan error) chan error {
+	groupID, ok := m.byOrder[order.ID]
+	if !ok {
+		return nil
+	}
+	group := m.groups[groupID]
+	member := group.member(order.ID)
+
+	var actions []ocoAction
+	switch {
+	case order.Status == models.OrderStatusFilled:
+		for _, sibling := range group.Members {
+			if sibling.OrderID != order.ID {
+				actions = append(actions, ocoAction{accountID: group.AccountID, orderID: sibling.OrderID})
+			}
+		}
+		m.deleteGroupLocked(group)
+
+	case isTerminalOrderStatus(order.Status):
+		// A cancelled or rejected member leaves the rest of the group intact.
+		group.remove(order.ID)
+		delete(m.byOrder, order.ID)
+		if len(group.Members) < 2 {
+			m.deleteGroupLocked(group)
+		}
+
+	case order.FillVolume > member.Filled:
+		delta := order.FillVolume - member.Filled
+		member.Filled = order.FillVolume
+		member.Size -= delta
+		for i := range group.Members {
+			sibling := &group.Members[i]
+			if sibling.OrderID == order.ID {
+				continue
+			}
+			sibling.Size -= delta
+			if sibling.Size < 0 {
+				sibling.Size = 0
+			}
+			actions = append(actions, ocoAction{accountID: group.AccountID, orderID: sibling.OrderID, size: sibling.Size})
+		}
+
+	default:
+		return nil
+	}
+
+	if err := m.saveLocked(); err != nil {
+		go m.reportError(err)
+	}
+	if len(actions) == 0 {
+		return nil
+	}
+
+	m.queue = append(m.queue, ocoBatch{ctx: ctx, actions: actions, done: done})
+	if !m.working {
+		m.working = true
+		go m.work()
+	}
+	return done
+}
+
+// work runs queued batches in order until the queue is empty.
+func (m *OCOManager) work() {
+	for {
+		m.mu.Lock()
+		if len(m.queue) == 0 {
+			m.working = false
+			m.mu.Unlock()
+			return
+		}
+		batch := m.queue[0]
+		m.queue[0] = ocoBatch{}
+		m.queue = m.queue[1:]
+		m.mu.Unlock()
+
+		err := m.execute(batch.ctx, batch.actions)
+		if batch.done != nil {
+			batch.done <- err
+		} else if err != nil && batch.ctx.Err() == nil {
+			m.reportError(err)
+		}
+	}
+}
+
+func (m *OCOManager) execute(ctx context.Context, actions []ocoAction) error {
+	var errs []error
+	for _, action := range actions {
+		var err error
+		if action.size > 0 {
+			err = m.modify(ctx, action)
+		} else {
+			err = m.cancelOrder(ctx, action)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m *OCOManager) cancelOrder(ctx context.Context, action ocoAction) error {
+	resp, err := m.orders.CancelOrder(ctx, &models.CancelOrderRequest{
+		AccountID: action.accountID,
+		OrderID:   action.orderID,
+	})
+	if err == nil && !resp.Success {
+		err = cancelOrderError(resp.ErrorCode)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to cancel oco sibling %d: %w", action.orderID, err)
+	}
+	return nil
+}
+
+func (m *OCOManager) modify(ctx context.Context, action ocoAction) error {
+	size := action.size
+	resp, err := m.orders.ModifyOrder(ctx, &models.ModifyOrderRequest{
+		AccountID: action.accountID,
+		OrderID:   action.orderID,
+		Size:      &size,
+	})
+	if err == nil && !resp.Success {
+		err = modifyOrderError(resp.ErrorCode)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to reduce oco sibling %d to %d: %w", action.orderID, size, err)
+	}
+	return nil
+}
+
+func (m *OCOManager) deleteGroupLocked(group *OCOGroup) {
+	for _, member := range group.Members {
+		delete(m.byOrder, member.OrderID)
+	}
+	delete(m.groups, group.ID)
+}
+
+func (m *OCOManager) snapshotLocked() []OCOGroup {
+	result := make([]OCOGroup, 0, len(m.groups))
+	for _, group := range m.groups {
+		result = append(result, group.clone())
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+func (m *OCOManager) saveLocked() error {
+	if m.store == nil {
+		return nil
+	}
+	return m.store.Save(m.snapshotLocked())
+}
+
+func (m *OCOManager) reportError(err error) {
+	m.mu.Lock()
+	handler := m.errorHandler
+	m.mu.Unlock()
+
+	if handler != nil {
+		handler(err)
+	}
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tradingiq/projectx-client/models"
+)
+
+func ocoFill(orderID int32, status models.OrderStatus, filled int32) *models.OrderUpdateData {
+	return &models.OrderUpdateData{Data: models.OrderUpdatePayload{
+		ID:         orderID,
+		AccountID:  1,
+		ContractID: riskTestContract,
+		Status:     status,
+		Type:       models.OrderTypeLimit,
+		Side:       models.OrderSideAsk,
+		Size:       3,
+		FillVolume: filled,
+	}}
+}
+
+func TestOCOPartialFillsReduceSiblingInOrder(t *testing.T) {
+	api, c := newTestAPI(t)
+	api.handle("/api/Order/searchOpen", func([]byte) interface{} {
+		return models.SearchOrderResponse{Success: true, Orders: []models.OrderModel{
+			workingOrder(1, models.OrderSideAsk, models.OrderTypeLimit, 3),
+			workingOrder(2, models.OrderSideAsk, models.OrderTypeStop, 3),
+		}}
+	})
+
+	var mu sync.Mutex
+	var sizes []int32
+	var cancels int
+	finished := make(chan struct{})
+	api.handle("/api/Order/modify", func(body []byte) interface{} {
+		var req models.ModifyOrderRequest
+		json.Unmarshal(body, &req)
+		// Hold the first reduction back so a second worker would overtake it.
+		if *req.Size == 2 {
+			time.Sleep(50 * time.Millisecond)
+		}
+		mu.Lock()
+		sizes = append(sizes, *req.Size)
+		mu.Unlock()
+		return models.ModifyOrderResponse{Success: true}
+	})
+	api.handle("/api/Order/cancel", func([]byte) interface{} {
+		mu.Lock()
+		cancels++
+		if cancels == 1 {
+			close(finished)
+		}
+		mu.Unlock()
+		return models.CancelOrderResponse{Success: true}
+	})
+
+	oco := NewOCOManager(NewOrderService(c), nil, nil)
+	oco.SetErrorHandler(func(err error) { t.Error(err) })
+	if _, err := oco.Add(context.Background(), 1, 1, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	oco.HandleOrderUpdate(ocoFill(1, models.OrderStatusOpen, 1))
+	oco.HandleOrderUpdate(ocoFill(1, models.OrderStatusOpen, 2))
+	oco.HandleOrderUpdate(ocoFill(1, models.OrderStatusFilled, 3))
+
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("sibling was not cancelled")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(sizes) != 2 || sizes[0] != 2 || sizes[1] != 1 {
+		t.Errorf("sibling modified to %v, want [2 1]", sizes)
+	}
+	if cancels != 1 {
+		t.Errorf("sibling cancelled %d times, want once after the reductions", cancels)
+	}
+	if groups := oco.Groups(); len(groups) != 0 {
+		t.Errorf("groups %+v remain after the fill", groups)
+	}
+}
@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"net/http"
+	"sync"
 
 	"github.com/tradingiq/projectx-client/client"
 	"github.com/tradingiq/projectx-client/models"
@@ -10,15 +11,40 @@ import (
 
 type OrderService struct {
 	client *client.Client
+
+	mu    sync.RWMutex
+	check PreTradeCheck
+}
+
+// PreTradeCheck is consulted before an order is placed or modified; a non-nil
+// error rejects the request locally without calling the API.
+type PreTradeCheck interface {
+	CheckPlace(ctx context.Context, req *models.PlaceOrderRequest) error
+	CheckModify(ctx context.Context, req *models.ModifyOrderRequest) error
 }
 
 func NewOrderService(c *client.Client) *OrderService {
 	return &OrderService{client: c}
 }
 
+// SetPreTradeCheck installs check for every PlaceOrder and ModifyOrder call,
+// including those made by bracket orders and the order builder. Passing nil
+// removes it.
+func (s *OrderService) SetPreTradeCheck(check PreTradeCheck) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.check = check
+}
+
+func (s *OrderService) preTradeCheck() PreTradeCheck {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.check
+}
+
 func (s *OrderService) SearchOrders(ctx context.Context, req *models.SearchOrderRequest) (*models.SearchOrderResponse, error) {
 	var resp models.SearchOrderResponse
-	err := s.client.DoJSON(ctx, &client.Request{
+	raw, err := s.client.DoJSONResponse(ctx, &client.Request{
 		Method: http.MethodPost,
 		Path:   "/api/Order/search",
 		Body:   req,
@@ -27,12 +53,16 @@ func (s *OrderService) SearchOrders(ctx context.Context, req *models.SearchOrder
 		return nil, err
 	}
 
+	if err := checkResponse(s.client, raw, resp.Success, int(resp.ErrorCode), resp.ErrorMessage, searchOrderError(resp.ErrorCode)); err != nil {
+		return &resp, err
+	}
+
 	return &resp, nil
 }
 
 func (s *OrderService) SearchOpenOrders(ctx context.Context, req *models.SearchOpenOrderRequest) (*models.SearchOrderResponse, error) {
 	var resp models.SearchOrderResponse
-	err := s.client.DoJSON(ctx, &client.Request{
+	raw, err := s.client.DoJSONResponse(ctx, &client.Request{
 		Method: http.MethodPost,
 		Path:   "/api/Order/searchOpen",
 		Body:   req,
@@ -41,12 +71,22 @@ func (s *OrderService) SearchOpenOrders(ctx context.Context, req *models.SearchO
 		return nil, err
 	}
 
+	if err := checkResponse(s.client, raw, resp.Success, int(resp.ErrorCode), resp.ErrorMessage, searchOrderError(resp.ErrorCode)); err != nil {
+		return &resp, err
+	}
+
 	return &resp, nil
 }
 
 func (s *OrderService) PlaceOrder(ctx context.Context, req *models.PlaceOrderRequest) (*models.PlaceOrderResponse, error) {
+	if check := s.preTradeCheck(); check != nil {
+		if err := check.CheckPlace(ctx, req); err != nil {
+			return nil, err
+		}
+	}
+
 	var resp models.PlaceOrderResponse
-	err := s.client.DoJSON(ctx, &client.Request{
+	raw, err := s.client.DoJSONResponse(ctx, &client.Request{
 		Method: http.MethodPost,
 		Path:   "/api/Order/place",
 		Body:   req,
@@ -55,12 +95,16 @@ func (s *OrderService) PlaceOrder(ctx context.Context, req *models.PlaceOrderReq
 		return nil, err
 	}
 
+	if err := checkResponse(s.client, raw, resp.Success, int(resp.ErrorCode), resp.ErrorMessage, placeOrderError(resp.ErrorCode)); err != nil {
+		return &resp, err
+	}
+
 	return &resp, nil
 }
 
 func (s *OrderService) CancelOrder(ctx context.Context, req *models.CancelOrderRequest) (*models.CancelOrderResponse, error) {
 	var resp models.CancelOrderResponse
-	err := s.client.DoJSON(ctx, &client.Request{
+	raw, err := s.client.DoJSONResponse(ctx, &client.Request{
 		Method: http.MethodPost,
 		Path:   "/api/Order/cancel",
 		Body:   req,
@@ -69,12 +113,22 @@ func (s *OrderService) CancelOrder(ctx context.Context, req *models.CancelOrderR
 		return nil, err
 	}
 
+	if err := checkResponse(s.client, raw, resp.Success, int(resp.ErrorCode), resp.ErrorMessage, cancelOrderError(resp.ErrorCode)); err != nil {
+		return &resp, err
+	}
+
 	return &resp, nil
 }
 
 func (s *OrderService) ModifyOrder(ctx context.Context, req *models.ModifyOrderRequest) (*models.ModifyOrderResponse, error) {
+	if check := s.preTradeCheck(); check != nil {
+		if err := check.CheckModify(ctx, req); err != nil {
+			return nil, err
+		}
+	}
+
 	var resp models.ModifyOrderResponse
-	err := s.client.DoJSON(ctx, &client.Request{
+	raw, err := s.client.DoJSONResponse(ctx, &client.Request{
 		Method: http.MethodPost,
 		Path:   "/api/Order/modify",
 		Body:   req,
@@ -83,5 +137,9 @@ func (s *OrderService) ModifyOrder(ctx context.Context, req *models.ModifyOrderR
 		return nil, err
 	}
 
+	if err := checkResponse(s.client, raw, resp.Success, int(resp.ErrorCode), resp.ErrorMessage, modifyOrderError(resp.ErrorCode)); err != nil {
+		return &resp, err
+	}
+
 	return &resp, nil
 }
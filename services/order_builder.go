@@ -0,0 +1,219 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/tradingiq/projectx-client/models"
+)
+
+var ErrInvalidOrder = errors.New("invalid order")
+
+// OrderValidationError lists every problem found while building an order.
+type OrderValidationError struct {
+	Problems []string
+}
+
+func (e *OrderValidationError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrInvalidOrder, strings.Join(e.Problems, "; "))
+}
+
+func (e *OrderValidationError) Unwrap() error {
+	return ErrInvalidOrder
+}
+
+// OrderBuilder assembles a PlaceOrderRequest and validates it against the
+// contract before it is sent:
+//
+//	req, err := services.NewOrder(accountID, contract).Buy(2).Limit(5012.25).Tag("x").Build()
+//
+// Prices off the contract tick grid are rejected unless RoundToTick is used.
+type OrderBuilder struct {
+	accountID int32
+	contract  models.ContractModel
+
+	orderType  models.OrderType
+	side       models.OrderSide
+	sideSet    bool
+	size       int32
+	limitPrice *float64
+	stopPrice  *float64
+	trailPrice *float64
+	customTag  *string
+	linkedID   *int32
+	round      bool
+}
+
+func NewOrder(accountID int32, contract models.ContractModel) *OrderBuilder {
+	return &OrderBuilder{
+		accountID: accountID,
+		contract:  contract,
+		orderType: models.OrderTypeMarket,
+	}
+}
+
+func (b *OrderBuilder) Buy(size int32) *OrderBuilder {
+	b.side, b.sideSet, b.size = models.OrderSideBid, true, size
+	return b
+}
+
+func (b *OrderBuilder) Sell(size int32) *OrderBuilder {
+	b.side, b.sideSet, b.size = models.OrderSideAsk, true, size
+	return b
+}
+
+func (b *OrderBuilder) Market() *OrderBuilder {
+	b.orderType = models.OrderTypeMarket
+	return b
+}
+
+func (b *OrderBuilder) Limit(price float64) *OrderBuilder {
+	b.orderType = models.OrderTypeLimit
+	b.limitPrice = &price
+	return b
+}
+
+func (b *OrderBuilder) Stop(price float64) *OrderBuilder {
+	b.orderType = models.OrderTypeStop
+	b.stopPrice = &price
+	return b
+}
+
+func (b *OrderBuilder) StopLimit(stopPrice, limitPrice float64) *OrderBuilder {
+	b.orderType = models.OrderTypeStopLimit
+	b.stopPrice = &stopPrice
+	b.limitPrice = &limitPrice
+	return b
+}
+
+// TrailingStop trails the market by distance, a price offset rather than a
+// number of ticks.
+func (b *OrderBuilder) TrailingStop(distance float64) *OrderBuilder {
+	b.orderType = models.OrderTypeTrailingStop
+	b.trailPrice = &distance
+	return b
+}
+
+func (b *OrderBuilder) JoinBid() *OrderBuilder {
+	b.orderType = models.OrderTypeJoinBid
+	return b
+}
+
+func (b *OrderBuilder) JoinAsk() *OrderBuilder {
+	b.orderType = models.OrderTypeJoinAsk
+	return b
+}
+
+func (b *OrderBuilder) Tag(customTag string) *OrderBuilder {
+	b.customTag = &customTag
+	return b
+}
+
+func (b *OrderBuilder) LinkedTo(orderID int32) *OrderBuilder {
+	b.linkedID = &orderID
+	return b
+}
+
+// RoundToTick rounds prices to the nearest multiple of the contract tick size
+// instead of rejecting them.
+func (b *OrderBuilder) RoundToTick() *OrderBuilder {
+	b.round = true
+	return b
+}
+
+// Build validates the order and returns the request for /api/Order/place.
+// Validation failures are returned as *OrderValidationError.
+func (b *OrderBuilder) Build() (*models.PlaceOrderRequest, error) {
+	var problems []string
+	addProblem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if b.contract.ID == "" {
+		addProblem("contract id is required")
+	}
+	if !b.sideSet {
+		addProblem("side is required, call Buy or Sell")
+	} else if b.size <= 0 {
+		addProblem("size must be positive, got %d", b.size)
+	}
+
+	needsLimit, needsStop, needsTrail := false, false, false
+	switch b.orderType {
+	case models.OrderTypeMarket, models.OrderTypeJoinBid, models.OrderTypeJoinAsk:
+	case models.OrderTypeLimit:
+		needsLimit = true
+	case models.OrderTypeStop:
+		needsStop = true
+	case models.OrderTypeStopLimit:
+		needsLimit, needsStop = true, true
+	case models.OrderTypeTrailingStop:
+		needsTrail = true
+	default:
+		addProblem("unsupported order type %s", b.orderType)
+	}
+
+	req := &models.PlaceOrderRequest{
+		AccountID:     b.accountID,
+		ContractID:    b.contract.ID,
+		Type:          b.orderType,
+		Side:          b.side,
+		Size:          b.size,
+		CustomTag:     b.customTag,
+		LinkedOrderID: b.linkedID,
+	}
+
+	price := func(name string, value *float64, needed bool) *float64 {
+		if !needed {
+			return nil
+		}
+		if value == nil {
+			addProblem("%s order requires a %s price", b.orderType, name)
+			return nil
+		}
+		p := *value
+		if p <= 0 || math.IsNaN(p) || math.IsInf(p, 0) {
+			addProblem("%s price must be positive, got %v", name, p)
+			return nil
+		}
+		if tick := b.contract.TickSize; tick > 0 && !onTickGrid(p, tick) {
+			if !b.round {
+				addProblem("%s price %v is not a multiple of tick size %v", name, p, tick)
+				return nil
+			}
+			p = roundToTick(p, tick)
+			if p <= 0 {
+				addProblem("%s price rounds to %v", name, p)
+				return nil
+			}
+		}
+		return &p
+	}
+
+	req.LimitPrice = price("limit", b.limitPrice, needsLimit)
+	req.StopPrice = price("stop", b.stopPrice, needsStop)
+	req.TrailPrice = price("trail", b.trailPrice, needsTrail)
+
+	if len(problems) > 0 {
+		return nil, &OrderValidationError{Problems: problems}
+	}
+	return req, nil
+}
+
+// Place builds the order and sends it with orders; nothing is sent when
+// validation fails.
+func (b *OrderBuilder) Place(ctx context.Context, orders *OrderService) (*models.PlaceOrderResponse, error) {
+	req, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+	return orders.PlaceOrder(ctx, req)
+}
+
+func onTickGrid(price, tickSize float64) bool {
+	ticks := price / tickSize
+	return math.Abs(ticks-math.Round(ticks)) < 1e-6
+}
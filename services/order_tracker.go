@@ -0,0 +1,354 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tradingiq/projectx-client/models"
+)
+
+var (
+	ErrIllegalTransition = errors.New("illegal order status transition")
+	ErrStaleOrderUpdate  = errors.New("stale order update")
+	ErrOrderUnresolved   = errors.New("order missing from open and past orders")
+)
+
+type OrderTransition struct {
+	Order models.OrderModel
+	From  models.OrderStatus
+	To    models.OrderStatus
+}
+
+type OrderTracker struct {
+	orders    *OrderService
+	userData  *UserDataWebSocketService
+	accountID int32
+
+	mu           sync.RWMutex
+	byID         map[int32]models.OrderModel
+	byTag        map[string]int32
+	errorHandler func(error)
+	unsubscribe  []func()
+	ctx          context.Context
+	cancel       context.CancelFunc
+
+	// dispatchMu serializes apply, so transitions reach handlers in the order
+	// they were applied.
+	dispatchMu  sync.Mutex
+	transitions *subscribers[func(OrderTransition)]
+}
+
+func NewOrderTracker(orders *OrderService, userData *UserDataWebSocketService, accountID int32) *OrderTracker {
+	return &OrderTracker{
+		orders:      orders,
+		userData:    userData,
+		accountID:   accountID,
+		byID:        make(map[int32]models.OrderModel),
+		byTag:       make(map[string]int32),
+		transitions: newSubscribers[func(OrderTransition)](),
+	}
+}
+
+// Start seeds the tracker from the open orders endpoint, applies order
+// events from the user hub and reconciles against REST after every reconnect.
+// The caller remains responsible for SubscribeOrders on the user hub.
+func (t *OrderTracker) Start(ctx context.Context) error {
+	t.mu.Lock()
+	if t.cancel != nil {
+		t.mu.Unlock()
+		return fmt.Errorf("order tracker already started")
+	}
+	t.ctx, t.cancel = context.WithCancel(ctx)
+	t.unsubscribe = append(t.unsubscribe,
+		t.userData.OnOrder(t.HandleOrderUpdate),
+		t.userData.OnConnectionState(t.handleConnectionState),
+	)
+	t.mu.Unlock()
+
+	return t.Reconcile(ctx)
+}
+
+func (t *OrderTracker) Stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, unsubscribe := range t.unsubscribe {
+		unsubscribe()
+	}
+	t.unsubscribe = nil
+
+	if t.cancel != nil {
+		t.cancel()
+		t.cancel = nil
+	}
+}
+
+func (t *OrderTracker) SetErrorHandler(handler func(error)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.errorHandler = handler
+}
+
+// OnTransition registers a callback for order status changes, including the
+// first time an order is seen, and returns a function that removes it.
+// Callbacks run one at a time in transition order and must not call
+// HandleOrderUpdate or Reconcile.
+func (t *OrderTracker) OnTransition(handler func(OrderTransition)) func() {
+	return t.transitions.add(subscribeAll, handler)
+}
+
+func (t *OrderTracker) handleConnectionState(state ConnectionState) {
+	if state != StateConnected {
+		return
+	}
+
+	t.mu.RLock()
+	ctx := t.ctx
+	t.mu.RUnlock()
+	if ctx == nil {
+		return
+	}
+
+	if err := t.Reconcile(ctx); err != nil && ctx.Err() == nil {
+		t.reportError(err)
+	}
+}
+
+func (t *OrderTracker) HandleOrderUpdate(update *models.OrderUpdateData) {
+	if update.Data.AccountID != t.accountID {
+		return
+	}
+	t.apply(update.Data.Order())
+}
+
+// Reconcile applies the open orders from REST and resolves the final state of
+// tracked orders that are no longer open, so fills missed while the websocket
+// was down are not lost. Working orders found in neither search are marked
+// expired and reported with ErrOrderUnresolved.
+func (t *OrderTracker) Reconcile(ctx context.Context) error {
+	resp, err := t.orders.SearchOpenOrders(ctx, &models.SearchOpenOrderRequest{AccountID: t.accountID})
+	if err != nil {
+		return fmt.Errorf("failed to search open orders: %w", err)
+	}
+	if !resp.Success {
+		return fmt.Errorf("failed to search open orders with error code: %v", resp.ErrorCode)
+	}
+
+	open := make(map[int32]bool, len(resp.Orders))
+	for _, order := range resp.Orders {
+		open[order.ID] = true
+		t.apply(order)
+	}
+
+	var since time.Time
+	missing := make(map[int32]bool)
+	t.mu.RLock()
+	for id, order := range t.byID {
+		if isTerminalOrderStatus(order.Status) || open[id] {
+			continue
+		}
+		missing[id] = true
+		if since.IsZero() || order.CreationTimestamp.Before(since) {
+			since = order.CreationTimestamp
+		}
+	}
+	t.mu.RUnlock()
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	history, err := t.orders.SearchOrders(ctx, &models.SearchOrderRequest{
+		AccountID:      t.accountID,
+		StartTimestamp: since.Add(-time.Minute),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to search orders: %w", err)
+	}
+	if !history.Success {
+		return fmt.Errorf("failed to search orders with error code: %v", history.ErrorCode)
+	}
+
+	for _, order := range history.Orders {
+		if missing[order.ID] {
+			delete(missing, order.ID)
+			t.apply(order)
+		}
+	}
+
+	// Orders the API no longer knows will never send another event; expire
+	// them so anything waiting on a terminal status is released.
+	for id := range missing {
+		order, ok := t.Order(id)
+		if !ok || isTerminalOrderStatus(order.Status) {
+			continue
+		}
+		order.Status = models.OrderStatusExpired
+		t.apply(order)
+		t.reportError(fmt.Errorf("order %d: %w, marked expired", id, ErrOrderUnresolved))
+	}
+
+	return nil
+}
+
+func (t *OrderTracker) apply(order models.OrderModel) {
+	t.dispatchMu.Lock()
+	defer t.dispatchMu.Unlock()
+
+	t.mu.Lock()
+
+	prev, exists := t.byID[order.ID]
+	if exists {
+		if isStaleOrder(prev, order) {
+			t.mu.Unlock()
+			t.reportError(fmt.Errorf("order %d: %w", order.ID, ErrStaleOrderUpdate))
+			return
+		}
+		if !isLegalOrderTransition(prev.Status, order.Status) {
+			t.mu.Unlock()
+			t.reportError(fmt.Errorf("order %d: %w from %s to %s", order.ID, ErrIllegalTransition, prev.Status, order.Status))
+			return
+		}
+		if order.CustomTag == nil {
+			order.CustomTag = prev.CustomTag
+		}
+	}
+
+	t.byID[order.ID] = order
+	if order.CustomTag != nil && *order.CustomTag != "" {
+		t.byTag[*order.CustomTag] = order.ID
+	}
+	t.mu.Unlock()
+
+	if exists && prev.Status == order.Status {
+		return
+	}
+
+	transition := OrderTransition{
+		Order: order,
+		From:  models.OrderStatusNone,
+		To:    order.Status,
+	}
+	if exists {
+		transition.From = prev.Status
+	}
+
+	for _, handler := range t.transitions.get(subscribeAll) {
+		handler(transition)
+	}
+}
+
+func (t *OrderTracker) reportError(err error) {
+	t.mu.RLock()
+	handler := t.errorHandler
+	t.mu.RUnlock()
+
+	if handler != nil {
+		handler(err)
+	}
+}
+
+func isStaleOrder(prev, next models.OrderModel) bool {
+	if prev.UpdateTimestamp == nil || next.UpdateTimestamp == nil {
+		return false
+	}
+	return next.UpdateTimestamp.Before(*prev.UpdateTimestamp)
+}
+
+func isTerminalOrderStatus(status models.OrderStatus) bool {
+	switch status {
+	case models.OrderStatusFilled, models.OrderStatusCancelled, models.OrderStatusExpired, models.OrderStatusRejected:
+		return true
+	default:
+		return false
+	}
+}
+
+// isLegalOrderTransition encodes the order lifecycle: an unknown order may
+// enter any status, Pending moves to Open or a terminal status, Open only to
+// a terminal status, and terminal statuses are final.
+func isLegalOrderTransition(from, to models.OrderStatus) bool {
+	if from == to || from == models.OrderStatusNone {
+		return true
+	}
+
+	switch from {
+	case models.OrderStatusPending:
+		return to == models.OrderStatusOpen || isTerminalOrderStatus(to)
+	case models.OrderStatusOpen:
+		return isTerminalOrderStatus(to)
+	default:
+		return false
+	}
+}
+
+func (t *OrderTracker) Order(orderID int32) (models.OrderModel, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	order, ok := t.byID[orderID]
+	return order, ok
+}
+
+func (t *OrderTracker) OrderByTag(customTag string) (models.OrderModel, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	id, ok := t.byTag[customTag]
+	if !ok {
+		return models.OrderModel{}, false
+	}
+	order, ok := t.byID[id]
+	return order, ok
+}
+
+func (t *OrderTracker) Orders() []models.OrderModel {
+	return t.filter(func(models.OrderModel) bool { return true })
+}
+
+func (t *OrderTracker) OpenOrders() []models.OrderModel {
+	return t.filter(func(order models.OrderModel) bool {
+		return !isTerminalOrderStatus(order.Status)
+	})
+}
+
+func (t *OrderTracker) filter(keep func(models.OrderModel) bool) []models.OrderModel {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	result := make([]models.OrderModel, 0, len(t.byID))
+	for _, order := range t.byID {
+		if keep(order) {
+			result = append(result, order)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// Prune forgets terminal orders last updated before cutoff.
+func (t *OrderTracker) Prune(cutoff time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for id, order := range t.byID {
+		if !isTerminalOrderStatus(order.Status) {
+			continue
+		}
+		updated := order.CreationTimestamp
+		if order.UpdateTimestamp != nil {
+			updated = *order.UpdateTimestamp
+		}
+		if updated.Before(cutoff) {
+			delete(t.byID, id)
+			if order.CustomTag != nil && t.byTag[*order.CustomTag] == id {
+				delete(t.byTag, *order.CustomTag)
+			}
+		}
+	}
+}
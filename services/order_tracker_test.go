@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tradingiq/projectx-client/models"
+)
+
+func trackedOrder(id int32, status models.OrderStatus) models.OrderModel {
+	return models.OrderModel{
+		ID:                id,
+		AccountID:         1,
+		ContractID:        riskTestContract,
+		CreationTimestamp: time.Date(2025, 3, 14, 14, 30, 0, 0, time.UTC),
+		Status:            status,
+		Type:              models.OrderTypeLimit,
+		Side:              models.OrderSideBid,
+		Size:              1,
+	}
+}
+
+func TestOrderTransitions(t *testing.T) {
+	tests := []struct {
+		from, to models.OrderStatus
+		legal    bool
+	}{
+		{models.OrderStatusNone, models.OrderStatusFilled, true},
+		{models.OrderStatusPending, models.OrderStatusOpen, true},
+		{models.OrderStatusPending, models.OrderStatusRejected, true},
+		{models.OrderStatusOpen, models.OrderStatusOpen, true},
+		{models.OrderStatusOpen, models.OrderStatusFilled, true},
+		{models.OrderStatusOpen, models.OrderStatusCancelled, true},
+		{models.OrderStatusOpen, models.OrderStatusPending, false},
+		{models.OrderStatusFilled, models.OrderStatusFilled, true},
+		{models.OrderStatusFilled, models.OrderStatusOpen, false},
+		{models.OrderStatusFilled, models.OrderStatusCancelled, false},
+		{models.OrderStatusCancelled, models.OrderStatusFilled, false},
+		{models.OrderStatusExpired, models.OrderStatusOpen, false},
+		{models.OrderStatusRejected, models.OrderStatusPending, false},
+	}
+	for _, test := range tests {
+		tracker := NewOrderTracker(nil, nil, 1)
+		var errs []error
+		tracker.SetErrorHandler(func(err error) { errs = append(errs, err) })
+		var transitions []OrderTransition
+		tracker.OnTransition(func(tr OrderTransition) { transitions = append(transitions, tr) })
+
+		if test.from != models.OrderStatusNone {
+			tracker.apply(trackedOrder(1, test.from))
+		}
+		transitions = nil
+		tracker.apply(trackedOrder(1, test.to))
+
+		got, _ := tracker.Order(1)
+		if test.legal {
+			if len(errs) != 0 || got.Status != test.to {
+				t.Errorf("%s -> %s: status %s, errors %v; want applied", test.from, test.to, got.Status, errs)
+			}
+			if test.from != test.to && (len(transitions) != 1 || transitions[0].From != test.from || transitions[0].To != test.to) {
+				t.Errorf("%s -> %s: transitions %+v", test.from, test.to, transitions)
+			}
+			continue
+		}
+		if len(errs) != 1 || !errors.Is(errs[0], ErrIllegalTransition) {
+			t.Errorf("%s -> %s: errors %v, want ErrIllegalTransition", test.from, test.to, errs)
+		}
+		if got.Status != test.from || len(transitions) != 0 {
+			t.Errorf("%s -> %s: status %s, transitions %+v; want unchanged", test.from, test.to, got.Status, transitions)
+		}
+	}
+}
+
+func TestOrderTrackerRejectsStaleUpdate(t *testing.T) {
+	tracker := NewOrderTracker(nil, nil, 1)
+	var errs []error
+	tracker.SetErrorHandler(func(err error) { errs = append(errs, err) })
+
+	filled := trackedOrder(1, models.OrderStatusFilled)
+	at := filled.CreationTimestamp.Add(time.Second)
+	filled.UpdateTimestamp = &at
+	tracker.apply(filled)
+
+	open := trackedOrder(1, models.OrderStatusOpen)
+	before := at.Add(-time.Millisecond)
+	open.UpdateTimestamp = &before
+	tracker.apply(open)
+
+	if got, _ := tracker.Order(1); got.Status != models.OrderStatusFilled {
+		t.Errorf("status %s, want FILLED", got.Status)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], ErrStaleOrderUpdate) {
+		t.Errorf("errors %v, want ErrStaleOrderUpdate", errs)
+	}
+}
+
+func TestOrderTrackerReconcile(t *testing.T) {
+	api, c := newTestAPI(t)
+	api.handle("/api/Order/searchOpen", func([]byte) interface{} {
+		return models.SearchOrderResponse{Success: true, Orders: []models.OrderModel{trackedOrder(1, models.OrderStatusOpen)}}
+	})
+	api.handle("/api/Order/search", func([]byte) interface{} {
+		return models.SearchOrderResponse{Success: true, Orders: []models.OrderModel{trackedOrder(2, models.OrderStatusFilled)}}
+	})
+
+	tracker := NewOrderTracker(NewOrderService(c), nil, 1)
+	var errs []error
+	tracker.SetErrorHandler(func(err error) { errs = append(errs, err) })
+	for _, id := range []int32{1, 2, 3} {
+		tracker.apply(trackedOrder(id, models.OrderStatusOpen))
+	}
+	terminal := make(map[int32]models.OrderStatus)
+	tracker.OnTransition(func(tr OrderTransition) {
+		if isTerminalOrderStatus(tr.To) {
+			terminal[tr.Order.ID] = tr.To
+		}
+	})
+
+	if err := tracker.Reconcile(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[int32]models.OrderStatus{2: models.OrderStatusFilled, 3: models.OrderStatusExpired}
+	if len(terminal) != len(want) || terminal[2] != want[2] || terminal[3] != want[3] {
+		t.Errorf("terminal transitions %v, want %v", terminal, want)
+	}
+	if open := tracker.OpenOrders(); len(open) != 1 || open[0].ID != 1 {
+		t.Errorf("open orders %+v, want only order 1", open)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], ErrOrderUnresolved) {
+		t.Errorf("errors %v, want ErrOrderUnresolved for order 3", errs)
+	}
+}
@@ -0,0 +1,296 @@
+package services
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tradingiq/projectx-client/models"
+)
+
+type BookLevel struct {
+	Price  float64
+	Volume float64
+}
+
+type BookSnapshot struct {
+	ContractID string
+	Bids       []BookLevel
+	Asks       []BookLevel
+	Timestamp  time.Time
+}
+
+type OrderBook struct {
+	mu         sync.RWMutex
+	contractID string
+	bids       map[float64]float64
+	asks       map[float64]float64
+	timestamp  time.Time
+}
+
+func NewOrderBook(contractID string) *OrderBook {
+	return &OrderBook{
+		contractID: contractID,
+		bids:       make(map[float64]float64),
+		asks:       make(map[float64]float64),
+	}
+}
+
+func (b *OrderBook) ContractID() string {
+	return b.contractID
+}
+
+// Apply updates the ladders from a depth message and reports whether the
+// book changed. Trade, fill, high and low entries do not affect the book.
+func (b *OrderBook) Apply(depth models.MarketDepthData) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	changed := false
+	for _, entry := range depth {
+		switch entry.Type {
+		case models.DepthTypeReset:
+			b.bids = make(map[float64]float64)
+			b.asks = make(map[float64]float64)
+		case models.DepthTypeBid:
+			setLevel(b.bids, entry.Price, entry.Volume)
+		case models.DepthTypeAsk:
+			setLevel(b.asks, entry.Price, entry.Volume)
+		case models.DepthTypeBestBid, models.DepthTypeNewBestBid:
+			for price := range b.bids {
+				if price > entry.Price {
+					delete(b.bids, price)
+				}
+			}
+			for price := range b.asks {
+				if price <= entry.Price {
+					delete(b.asks, price)
+				}
+			}
+			setLevel(b.bids, entry.Price, entry.Volume)
+		case models.DepthTypeBestAsk, models.DepthTypeNewBestAsk:
+			for price := range b.asks {
+				if price < entry.Price {
+					delete(b.asks, price)
+				}
+			}
+			for price := range b.bids {
+				if price >= entry.Price {
+					delete(b.bids, price)
+				}
+			}
+			setLevel(b.asks, entry.Price, entry.Volume)
+		default:
+			continue
+		}
+
+		changed = true
+		if entry.Timestamp.After(b.timestamp) {
+			b.timestamp = entry.Timestamp
+		}
+	}
+
+	return changed
+}
+
+func setLevel(levels map[float64]float64, price, volume float64) {
+	if volume <= 0 {
+		delete(levels, price)
+		return
+	}
+	levels[price] = volume
+}
+
+func (b *OrderBook) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bids = make(map[float64]float64)
+	b.asks = make(map[float64]float64)
+	b.timestamp = time.Time{}
+}
+
+func (b *OrderBook) Snapshot() BookSnapshot {
+	return b.TopLevels(0)
+}
+
+// TopLevels returns the best n levels per side, or the full book when n <= 0.
+// Bids are sorted descending and asks ascending.
+func (b *OrderBook) TopLevels(n int) BookSnapshot {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return BookSnapshot{
+		ContractID: b.contractID,
+		Bids:       sortedLevels(b.bids, n, true),
+		Asks:       sortedLevels(b.asks, n, false),
+		Timestamp:  b.timestamp,
+	}
+}
+
+func sortedLevels(levels map[float64]float64, n int, descending bool) []BookLevel {
+	result := make([]BookLevel, 0, len(levels))
+	for price, volume := range levels {
+		result = append(result, BookLevel{Price: price, Volume: volume})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if descending {
+			return result[i].Price > result[j].Price
+		}
+		return result[i].Price < result[j].Price
+	})
+
+	if n > 0 && len(result) > n {
+		result = result[:n]
+	}
+	return result
+}
+
+func (b *OrderBook) BestBid() (BookLevel, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return bestLevel(b.bids, true)
+}
+
+func (b *OrderBook) BestAsk() (BookLevel, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return bestLevel(b.asks, false)
+}
+
+func bestLevel(levels map[float64]float64, highest bool) (BookLevel, bool) {
+	var best BookLevel
+	found := false
+	for price, volume := range levels {
+		if !found || (highest && price > best.Price) || (!highest && price < best.Price) {
+			best = BookLevel{Price: price, Volume: volume}
+			found = true
+		}
+	}
+	return best, found
+}
+
+func (b *OrderBook) Spread() (float64, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	bid, okBid := bestLevel(b.bids, true)
+	ask, okAsk := bestLevel(b.asks, false)
+	if !okBid || !okAsk {
+		return 0, false
+	}
+	return ask.Price - bid.Price, true
+}
+
+func (b *OrderBook) Mid() (float64, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	bid, okBid := bestLevel(b.bids, true)
+	ask, okAsk := bestLevel(b.asks, false)
+	if !okBid || !okAsk {
+		return 0, false
+	}
+	return (ask.Price + bid.Price) / 2, true
+}
+
+// Imbalance returns (bidVolume - askVolume) / (bidVolume + askVolume) over the
+// best n levels per side, ranging from -1 (all asks) to 1 (all bids).
+func (b *OrderBook) Imbalance(n int) (float64, bool) {
+	snapshot := b.TopLevels(n)
+
+	var bidVolume, askVolume float64
+	for _, level := range snapshot.Bids {
+		bidVolume += level.Volume
+	}
+	for _, level := range snapshot.Asks {
+		askVolume += level.Volume
+	}
+
+	total := bidVolume + askVolume
+	if total == 0 {
+		return 0, false
+	}
+	return (bidVolume - askVolume) / total, true
+}
+
+// CumulativeDepth returns the best n levels per side with running volume
+// totals, as used for depth charts.
+func (b *OrderBook) CumulativeDepth(n int) BookSnapshot {
+	snapshot := b.TopLevels(n)
+	accumulate(snapshot.Bids)
+	accumulate(snapshot.Asks)
+	return snapshot
+}
+
+func accumulate(levels []BookLevel) {
+	var total float64
+	for i := range levels {
+		total += levels[i].Volume
+		levels[i].Volume = total
+	}
+}
+
+type OrderBooks struct {
+	mu       sync.RWMutex
+	books    map[string]*OrderBook
+	handlers *subscribers[func(string, *OrderBook)]
+}
+
+func NewOrderBooks() *OrderBooks {
+	return &OrderBooks{
+		books:    make(map[string]*OrderBook),
+		handlers: newSubscribers[func(string, *OrderBook)](),
+	}
+}
+
+// HandleDepth can be passed directly to MarketDataWebSocketService.OnDepth or
+// SetDepthHandler.
+func (o *OrderBooks) HandleDepth(contractID string, depth models.MarketDepthData) {
+	o.mu.Lock()
+	book, ok := o.books[contractID]
+	if !ok {
+		book = NewOrderBook(contractID)
+		o.books[contractID] = book
+	}
+	o.mu.Unlock()
+
+	if !book.Apply(depth) {
+		return
+	}
+
+	for _, handler := range o.handlers.get(contractID) {
+		handler(contractID, book)
+	}
+}
+
+func (o *OrderBooks) Book(contractID string) (*OrderBook, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	book, ok := o.books[contractID]
+	return book, ok
+}
+
+func (o *OrderBooks) Contracts() []string {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	contracts := make([]string, 0, len(o.books))
+	for contractID := range o.books {
+		contracts = append(contracts, contractID)
+	}
+	sort.Strings(contracts)
+	return contracts
+}
+
+func (o *OrderBooks) Remove(contractID string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.books, contractID)
+}
+
+// OnChange registers a callback for changes to the book of contractID, or of
+// every contract with AllContracts, and returns a function that removes it.
+func (o *OrderBooks) OnChange(contractID string, handler func(contractID string, book *OrderBook)) func() {
+	return o.handlers.add(contractID, handler)
+}
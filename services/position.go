@@ -18,7 +18,7 @@ func NewPositionService(c *client.Client) *PositionService {
 
 func (s *PositionService) SearchOpenPositions(ctx context.Context, req *models.SearchPositionRequest) (*models.SearchPositionResponse, error) {
 	var resp models.SearchPositionResponse
-	err := s.client.DoJSON(ctx, &client.Request{
+	raw, err := s.client.DoJSONResponse(ctx, &client.Request{
 		Method: http.MethodPost,
 		Path:   "/api/Position/searchOpen",
 		Body:   req,
@@ -27,12 +27,16 @@ func (s *PositionService) SearchOpenPositions(ctx context.Context, req *models.S
 		return nil, err
 	}
 
+	if err := checkResponse(s.client, raw, resp.Success, int(resp.ErrorCode), resp.ErrorMessage, searchPositionError(resp.ErrorCode)); err != nil {
+		return &resp, err
+	}
+
 	return &resp, nil
 }
 
 func (s *PositionService) CloseContractPosition(ctx context.Context, req *models.CloseContractPositionRequest) (*models.ClosePositionResponse, error) {
 	var resp models.ClosePositionResponse
-	err := s.client.DoJSON(ctx, &client.Request{
+	raw, err := s.client.DoJSONResponse(ctx, &client.Request{
 		Method: http.MethodPost,
 		Path:   "/api/Position/closeContract",
 		Body:   req,
@@ -41,12 +45,16 @@ func (s *PositionService) CloseContractPosition(ctx context.Context, req *models
 		return nil, err
 	}
 
+	if err := checkResponse(s.client, raw, resp.Success, int(resp.ErrorCode), resp.ErrorMessage, closePositionError(resp.ErrorCode)); err != nil {
+		return &resp, err
+	}
+
 	return &resp, nil
 }
 
 func (s *PositionService) PartialCloseContractPosition(ctx context.Context, req *models.PartialCloseContractPositionRequest) (*models.PartialClosePositionResponse, error) {
 	var resp models.PartialClosePositionResponse
-	err := s.client.DoJSON(ctx, &client.Request{
+	raw, err := s.client.DoJSONResponse(ctx, &client.Request{
 		Method: http.MethodPost,
 		Path:   "/api/Position/partialCloseContract",
 		Body:   req,
@@ -55,5 +63,9 @@ func (s *PositionService) PartialCloseContractPosition(ctx context.Context, req
 		return nil, err
 	}
 
+	if err := checkResponse(s.client, raw, resp.Success, int(resp.ErrorCode), resp.ErrorMessage, partialClosePositionError(resp.ErrorCode)); err != nil {
+		return &resp, err
+	}
+
 	return &resp, nil
 }
@@ -0,0 +1,421 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/tradingiq/projectx-client/models"
+)
+
+type PositionPnL struct {
+	Position models.PositionModel
+	// MarkPrice is the last traded price, or the bid/ask mid before the first
+	// trade. It is zero until a quote for the contract has been received.
+	MarkPrice     float64
+	TickSize      float64
+	TickValue     float64
+	Ticks         float64
+	UnrealizedPnL float64
+	// Priced reports whether both a mark price and the contract tick value
+	// were available, i.e. whether Ticks and UnrealizedPnL are meaningful.
+	Priced bool
+}
+
+type AccountPnL struct {
+	AccountID     int32
+	Positions     []PositionPnL
+	UnrealizedPnL float64
+	// Priced is false when at least one open position could not be priced.
+	Priced bool
+}
+
+type PositionBook struct {
+	positionService *PositionService
+	contractService *ContractService
+	userData        *UserDataWebSocketService
+	marketData      *MarketDataWebSocketService
+
+	mu           sync.RWMutex
+	accounts     map[int32]bool
+	positions    map[int32]map[string]models.PositionModel
+	contracts    map[string]models.ContractModel
+	loading      map[string]bool
+	marks        map[string]float64
+	errorHandler func(error)
+	unsubscribe  []func()
+	ctx          context.Context
+	cancel       context.CancelFunc
+
+	handlers *subscribers[func(PositionPnL)]
+}
+
+func NewPositionBook(positions *PositionService, contracts *ContractService, userData *UserDataWebSocketService, marketData *MarketDataWebSocketService) *PositionBook {
+	return &PositionBook{
+		positionService: positions,
+		contractService: contracts,
+		userData:        userData,
+		marketData:      marketData,
+		accounts:        make(map[int32]bool),
+		positions:       make(map[int32]map[string]models.PositionModel),
+		contracts:       make(map[string]models.ContractModel),
+		loading:         make(map[string]bool),
+		marks:           make(map[string]float64),
+		handlers:        newSubscribers[func(PositionPnL)](),
+	}
+}
+
+// Start loads the open positions of each account, then applies position
+// events from the user hub and quotes from the market hub, resyncing after a
+// user hub reconnect. The caller remains responsible for SubscribePositions
+// and for subscribing to quotes of the contracts that are held.
+func (b *PositionBook) Start(ctx context.Context, accountIDs ...int32) error {
+	b.mu.Lock()
+	if b.cancel != nil {
+		b.mu.Unlock()
+		return fmt.Errorf("position book already started")
+	}
+	b.ctx, b.cancel = context.WithCancel(ctx)
+	for _, accountID := range accountIDs {
+		b.accounts[accountID] = true
+	}
+	b.unsubscribe = append(b.unsubscribe,
+		b.userData.OnPosition(b.HandlePositionUpdate),
+		b.userData.OnConnectionState(b.handleConnectionState),
+		b.marketData.OnQuote(AllContracts, b.HandleQuote),
+	)
+	b.mu.Unlock()
+
+	for _, accountID := range accountIDs {
+		if err := b.Sync(ctx, accountID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *PositionBook) Stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, unsubscribe := range b.unsubscribe {
+		unsubscribe()
+	}
+	b.unsubscribe = nil
+
+	if b.cancel != nil {
+		b.cancel()
+		b.cancel = nil
+	}
+}
+
+func (b *PositionBook) SetErrorHandler(handler func(error)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.errorHandler = handler
+}
+
+// SetContract provides tick size and value for a contract so that it does not
+// have to be looked up when a position in it is first seen.
+func (b *PositionBook) SetContract(contract models.ContractModel) {
+	b.mu.Lock()
+	b.contracts[contract.ID] = contract
+	b.mu.Unlock()
+
+	b.notify(contract.ID)
+}
+
+// OnChange registers a callback for position or price changes of contractID,
+// or of every contract with AllContracts, and returns a function that removes
+// it. A position that was closed is reported once with a size of zero.
+func (b *PositionBook) OnChange(contractID string, handler func(PositionPnL)) func() {
+	return b.handlers.add(contractID, handler)
+}
+
+// Sync replaces the positions of accountID with the open positions from REST.
+func (b *PositionBook) Sync(ctx context.Context, accountID int32) error {
+	resp, err := b.positionService.SearchOpenPositions(ctx, &models.SearchPositionRequest{AccountID: accountID})
+	if err != nil {
+		return fmt.Errorf("failed to search open positions: %w", err)
+	}
+	if !resp.Success {
+		return fmt.Errorf("failed to search open positions with error code: %v", resp.ErrorCode)
+	}
+
+	for _, position := range resp.Positions {
+		if err := b.loadContract(ctx, position.ContractID); err != nil {
+			return err
+		}
+	}
+
+	open := make(map[string]models.PositionModel, len(resp.Positions))
+	for _, position := range resp.Positions {
+		open[position.ContractID] = position
+	}
+
+	b.mu.Lock()
+	b.accounts[accountID] = true
+	var closed []models.PositionModel
+	for contractID, position := range b.positions[accountID] {
+		if _, ok := open[contractID]; !ok {
+			closed = append(closed, position)
+		}
+	}
+	b.positions[accountID] = open
+	for contractID := range open {
+		b.seedMarkLocked(contractID)
+	}
+	b.mu.Unlock()
+
+	for _, position := range closed {
+		position.Size = 0
+		position.Type = models.PositionTypeFlat
+		b.emit(position)
+	}
+	for _, position := range open {
+		b.emit(position)
+	}
+
+	return nil
+}
+
+func (b *PositionBook) handleConnectionState(state ConnectionState) {
+	if state != StateConnected {
+		return
+	}
+
+	b.mu.RLock()
+	ctx := b.ctx
+	accountIDs := make([]int32, 0, len(b.accounts))
+	for accountID := range b.accounts {
+		accountIDs = append(accountIDs, accountID)
+	}
+	b.mu.RUnlock()
+	if ctx == nil {
+		return
+	}
+
+	for _, accountID := range accountIDs {
+		if err := b.Sync(ctx, accountID); err != nil && ctx.Err() == nil {
+			b.reportError(err)
+		}
+	}
+}
+
+func (b *PositionBook) HandlePositionUpdate(update *models.PositionUpdateData) {
+	position := update.Data.Position()
+
+	b.mu.Lock()
+	if !b.accounts[position.AccountID] {
+		b.mu.Unlock()
+		return
+	}
+
+	if position.Size == 0 || position.Type == models.PositionTypeFlat {
+		position.Size = 0
+		position.Type = models.PositionTypeFlat
+		delete(b.positions[position.AccountID], position.ContractID)
+	} else {
+		if b.positions[position.AccountID] == nil {
+			b.positions[position.AccountID] = make(map[string]models.PositionModel)
+		}
+		b.positions[position.AccountID][position.ContractID] = position
+		b.seedMarkLocked(position.ContractID)
+	}
+
+	_, known := b.contracts[position.ContractID]
+	load := !known && !b.loading[position.ContractID] && position.Size != 0 && b.ctx != nil
+	if load {
+		b.loading[position.ContractID] = true
+	}
+	ctx := b.ctx
+	b.mu.Unlock()
+
+	// Contract lookups go over REST and must not block the hub receive loop.
+	if load {
+		go func() {
+			if err := b.loadContract(ctx, position.ContractID); err != nil && ctx.Err() == nil {
+				b.reportError(err)
+				return
+			}
+			b.notify(position.ContractID)
+		}()
+	}
+
+	b.emit(position)
+}
+
+// HandleQuote can be passed to MarketDataWebSocketService.OnQuote when the
+// book is driven manually instead of through Start.
+func (b *PositionBook) HandleQuote(contractID string, quote models.Quote) {
+	mark := markPrice(quote)
+	if mark == 0 {
+		return
+	}
+
+	b.mu.Lock()
+	changed := b.marks[contractID] != mark
+	b.marks[contractID] = mark
+	b.mu.Unlock()
+
+	if changed {
+		b.notify(contractID)
+	}
+}
+
+func markPrice(quote models.Quote) float64 {
+	if quote.LastPrice != 0 {
+		return quote.LastPrice
+	}
+	if quote.BestBid != 0 && quote.BestAsk != 0 {
+		return (quote.BestBid + quote.BestAsk) / 2
+	}
+	return 0
+}
+
+func (b *PositionBook) seedMarkLocked(contractID string) {
+	if _, ok := b.marks[contractID]; ok || b.marketData == nil {
+		return
+	}
+	if quote, ok := b.marketData.LatestQuote(contractID); ok {
+		if mark := markPrice(quote); mark != 0 {
+			b.marks[contractID] = mark
+		}
+	}
+}
+
+func (b *PositionBook) loadContract(ctx context.Context, contractID string) error {
+	b.mu.RLock()
+	_, ok := b.contracts[contractID]
+	b.mu.RUnlock()
+	if ok {
+		return nil
+	}
+
+	resp, err := b.contractService.SearchContractByID(ctx, &models.SearchContractByIdRequest{ContractID: contractID})
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.loading, contractID)
+
+	if err != nil {
+		return fmt.Errorf("failed to load contract %s: %w", contractID, err)
+	}
+	if !resp.Success || resp.Contract == nil {
+		return fmt.Errorf("failed to load contract %s with error code: %v", contractID, resp.ErrorCode)
+	}
+	b.contracts[contractID] = *resp.Contract
+	return nil
+}
+
+func (b *PositionBook) notify(contractID string) {
+	b.mu.RLock()
+	var positions []models.PositionModel
+	for _, byContract := range b.positions {
+		if position, ok := byContract[contractID]; ok {
+			positions = append(positions, position)
+		}
+	}
+	b.mu.RUnlock()
+
+	for _, position := range positions {
+		b.emit(position)
+	}
+}
+
+func (b *PositionBook) emit(position models.PositionModel) {
+	handlers := b.handlers.get(position.ContractID)
+	if len(handlers) == 0 {
+		return
+	}
+
+	b.mu.RLock()
+	pnl := b.valueLocked(position)
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(pnl)
+	}
+}
+
+func (b *PositionBook) valueLocked(position models.PositionModel) PositionPnL {
+	pnl := PositionPnL{
+		Position:  position,
+		MarkPrice: b.marks[position.ContractID],
+	}
+	if contract, ok := b.contracts[position.ContractID]; ok {
+		pnl.TickSize = contract.TickSize
+		pnl.TickValue = contract.TickValue
+	}
+
+	if position.Size == 0 {
+		pnl.Priced = true
+		return pnl
+	}
+	if pnl.MarkPrice == 0 || pnl.TickSize == 0 {
+		return pnl
+	}
+
+	direction := 1.0
+	if position.Type == models.PositionTypeShort {
+		direction = -1
+	}
+	size := math.Abs(float64(position.Size))
+
+	pnl.Ticks = direction * (pnl.MarkPrice - position.AveragePrice) / pnl.TickSize
+	pnl.UnrealizedPnL = pnl.Ticks * pnl.TickValue * size
+	pnl.Priced = true
+	return pnl
+}
+
+func (b *PositionBook) reportError(err error) {
+	b.mu.RLock()
+	handler := b.errorHandler
+	b.mu.RUnlock()
+
+	if handler != nil {
+		handler(err)
+	}
+}
+
+func (b *PositionBook) Position(accountID int32, contractID string) (PositionPnL, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	position, ok := b.positions[accountID][contractID]
+	if !ok {
+		return PositionPnL{}, false
+	}
+	return b.valueLocked(position), true
+}
+
+func (b *PositionBook) Positions(accountID int32) []PositionPnL {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	result := make([]PositionPnL, 0, len(b.positions[accountID]))
+	for _, position := range b.positions[accountID] {
+		result = append(result, b.valueLocked(position))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Position.ContractID < result[j].Position.ContractID
+	})
+	return result
+}
+
+func (b *PositionBook) Account(accountID int32) AccountPnL {
+	account := AccountPnL{
+		AccountID: accountID,
+		Positions: b.Positions(accountID),
+		Priced:    true,
+	}
+	for _, pnl := range account.Positions {
+		account.UnrealizedPnL += pnl.UnrealizedPnL
+		if !pnl.Priced {
+			account.Priced = false
+		}
+	}
+	return account
+}
@@ -0,0 +1,303 @@
+package services
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	HubMarket = "market"
+	HubUser   = "user"
+
+	DefaultRecorderRotateSize    = 256 << 20
+	DefaultRecorderRotateEvery   = time.Hour
+	DefaultRecorderFlushInterval = time.Second
+	DefaultRecorderBuffer        = 4096
+
+	recordingExtension = ".jsonl.gz"
+)
+
+var ErrRecorderClosed = errors.New("recorder closed")
+
+// HubMessage is one message as received from a hub, before decoding.
+type HubMessage struct {
+	Hub        string          `json:"hub"`
+	Method     string          `json:"method"`
+	ContractID string          `json:"contractId,omitempty"`
+	AccountID  int32           `json:"accountId,omitempty"`
+	Payload    json.RawMessage `json:"payload"`
+	Received   time.Time       `json:"received"`
+	// Monotonic is the receive time on the monotonic clock, measured from the
+	// start of the recording, so that intervals survive wall clock jumps.
+	Monotonic time.Duration `json:"monotonic"`
+	// Session is the wall clock time the recording started, shared by every
+	// message whose Monotonic is measured from the same point.
+	Session time.Time `json:"session,omitzero"`
+}
+
+type HubRecorder interface {
+	RecordHubMessage(msg HubMessage)
+}
+
+// reportRecorderError passes err to the error handler of recorder when it is
+// a Recorder; other HubRecorder implementations have no way to receive it.
+func reportRecorderError(recorder HubRecorder, err error) {
+	if r, ok := recorder.(*Recorder); ok {
+		r.reportError(err)
+	}
+}
+
+type recorderConfig struct {
+	rotateSize    int64
+	rotateEvery   time.Duration
+	flushInterval time.Duration
+	buffer        int
+}
+
+type RecorderOption func(*recorderConfig)
+
+// WithRecorderRotation starts a new file once the current one holds size
+// uncompressed bytes or is older than every. Zero disables either limit.
+func WithRecorderRotation(size int64, every time.Duration) RecorderOption {
+	return func(c *recorderConfig) {
+		c.rotateSize = size
+		c.rotateEvery = every
+	}
+}
+
+// WithRecorderFlushInterval bounds how long messages stay buffered before
+// they reach the file.
+func WithRecorderFlushInterval(interval time.Duration) RecorderOption {
+	return func(c *recorderConfig) {
+		c.flushInterval = interval
+	}
+}
+
+// WithRecorderBuffer sets how many messages may queue for the writer before
+// RecordHubMessage blocks.
+func WithRecorderBuffer(n int) RecorderOption {
+	return func(c *recorderConfig) {
+		c.buffer = n
+	}
+}
+
+// Recorder writes hub messages to gzip-compressed JSON Lines files in a
+// directory, one HubMessage per line. Files are named after the prefix and
+// the time they were opened, so they sort in recording order. Messages are
+// written by a background goroutine; when it falls behind, RecordHubMessage
+// blocks rather than dropping messages.
+type Recorder struct {
+	dir    string
+	prefix string
+	cfg    recorderConfig
+	start  time.Time
+
+	messages chan HubMessage
+	done     chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+
+	handlerMu    sync.Mutex
+	errorHandler func(error)
+
+	file    *os.File
+	gzip    *gzip.Writer
+	buf     *bufio.Writer
+	opened  time.Time
+	written int64
+	seq     int
+}
+
+// NewRecorder creates dir if needed and starts recording. Pass it to
+// SetRecorder of MarketDataWebSocketService and UserDataWebSocketService;
+// both may share one recorder.
+func NewRecorder(dir, prefix string, opts ...RecorderOption) (*Recorder, error) {
+	cfg := recorderConfig{
+		rotateSize:    DefaultRecorderRotateSize,
+		rotateEvery:   DefaultRecorderRotateEvery,
+		flushInterval: DefaultRecorderFlushInterval,
+		buffer:        DefaultRecorderBuffer,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.flushInterval <= 0 {
+		cfg.flushInterval = DefaultRecorderFlushInterval
+	}
+	if cfg.buffer < 0 {
+		cfg.buffer = 0
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create recording directory: %w", err)
+	}
+
+	r := &Recorder{
+		dir:      dir,
+		prefix:   prefix,
+		cfg:      cfg,
+		start:    time.Now(),
+		messages: make(chan HubMessage, cfg.buffer),
+		done:     make(chan struct{}),
+	}
+	if err := r.rotate(); err != nil {
+		return nil, err
+	}
+
+	go r.run()
+	return r, nil
+}
+
+func (r *Recorder) SetErrorHandler(handler func(error)) {
+	r.handlerMu.Lock()
+	defer r.handlerMu.Unlock()
+	r.errorHandler = handler
+}
+
+func (r *Recorder) reportError(err error) {
+	r.handlerMu.Lock()
+	handler := r.errorHandler
+	r.handlerMu.Unlock()
+
+	if handler != nil {
+		handler(err)
+	}
+}
+
+// RecordHubMessage queues msg. Messages recorded after Close are dropped.
+func (r *Recorder) RecordHubMessage(msg HubMessage) {
+	if msg.Received.IsZero() {
+		msg.Received = time.Now()
+	}
+	msg.Monotonic = msg.Received.Sub(r.start)
+	msg.Session = r.start
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		return
+	}
+	r.messages <- msg
+}
+
+// Close writes the queued messages and closes the current file.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return ErrRecorderClosed
+	}
+	r.closed = true
+	close(r.messages)
+	r.mu.Unlock()
+
+	<-r.done
+	return r.closeFile()
+}
+
+func (r *Recorder) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.cfg.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case msg, ok := <-r.messages:
+			if !ok {
+				return
+			}
+			if err := r.write(msg); err != nil {
+				r.reportError(err)
+			}
+		case <-ticker.C:
+			if err := r.flush(); err != nil {
+				r.reportError(err)
+			}
+			if r.cfg.rotateEvery > 0 && time.Since(r.opened) >= r.cfg.rotateEvery && r.written > 0 {
+				if err := r.rotate(); err != nil {
+					r.reportError(err)
+				}
+			}
+		}
+	}
+}
+
+func (r *Recorder) write(msg HubMessage) error {
+	if r.buf == nil {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+	}
+
+	line, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s message: %w", msg.Method, err)
+	}
+	line = append(line, '\n')
+	if _, err := r.buf.Write(line); err != nil {
+		return fmt.Errorf("failed to write recording: %w", err)
+	}
+	r.written += int64(len(line))
+
+	if r.cfg.rotateSize > 0 && r.written >= r.cfg.rotateSize {
+		return r.rotate()
+	}
+	return nil
+}
+
+func (r *Recorder) flush() error {
+	if r.buf == nil {
+		return nil
+	}
+	if err := r.buf.Flush(); err != nil {
+		return fmt.Errorf("failed to flush recording: %w", err)
+	}
+	if err := r.gzip.Flush(); err != nil {
+		return fmt.Errorf("failed to flush recording: %w", err)
+	}
+	return nil
+}
+
+// rotate closes the current file and opens the next one. On failure the
+// recorder keeps no file open and retries on the next message.
+func (r *Recorder) rotate() error {
+	if err := r.closeFile(); err != nil {
+		return err
+	}
+
+	r.opened = time.Now()
+	r.seq++
+	name := fmt.Sprintf("%s-%s-%04d%s", r.prefix, r.opened.UTC().Format("20060102T150405"), r.seq, recordingExtension)
+	file, err := os.OpenFile(filepath.Join(r.dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create recording file: %w", err)
+	}
+
+	r.file = file
+	r.gzip = gzip.NewWriter(file)
+	r.buf = bufio.NewWriter(r.gzip)
+	r.written = 0
+	return nil
+}
+
+func (r *Recorder) closeFile() error {
+	if r.file == nil {
+		return nil
+	}
+
+	err := errors.Join(r.buf.Flush(), r.gzip.Close(), r.file.Close())
+	r.file, r.gzip, r.buf = nil, nil, nil
+	if err != nil {
+		return fmt.Errorf("failed to close recording file: %w", err)
+	}
+	return nil
+}
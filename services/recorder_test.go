@@ -0,0 +1,263 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// hubMessages is a HubRecorder that keeps messages in memory. While block is
+// open, RecordHubMessage signals entered and waits for it to close.
+type hubMessages struct {
+	mu       sync.Mutex
+	messages []HubMessage
+	entered  chan struct{}
+	block    chan struct{}
+}
+
+func (h *hubMessages) RecordHubMessage(msg HubMessage) {
+	if h.block != nil {
+		h.entered <- struct{}{}
+		<-h.block
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.messages = append(h.messages, msg)
+}
+
+func (h *hubMessages) recorded() []HubMessage {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]HubMessage(nil), h.messages...)
+}
+
+func testHubMessage(n int) HubMessage {
+	payload, _ := json.Marshal(map[string]int{"n": n})
+	return HubMessage{Hub: HubMarket, Method: "GatewayTrade", ContractID: riskTestContract, Payload: payload}
+}
+
+// writeRecording writes msgs as a gzip-compressed recording at path, flushed
+// before the last message like the recorder does between writes, and keeps
+// the first size(flushed, total) bytes of it.
+func writeRecording(t *testing.T, path string, msgs []HubMessage, size func(flushed, total int) int) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(gz)
+	flushed := 0
+	for i, msg := range msgs {
+		if i == len(msgs)-1 {
+			if err := gz.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			flushed = buf.Len()
+		}
+		if err := enc.Encode(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes()[:size(flushed, buf.Len())], 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func wholeFile(_, total int) int { return total }
+
+func readRecording(t *testing.T, paths ...string) []HubMessage {
+	t.Helper()
+
+	reader := OpenRecording(paths...)
+	defer reader.Close()
+	var msgs []HubMessage
+	for {
+		msg, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return msgs
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, msg)
+	}
+}
+
+func TestRecorderRotation(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []RecorderOption
+		pause time.Duration
+	}{
+		{"by size", []RecorderOption{WithRecorderRotation(1, 0)}, 0},
+		{"by age", []RecorderOption{WithRecorderRotation(0, 20*time.Millisecond), WithRecorderFlushInterval(5 * time.Millisecond)}, 60 * time.Millisecond},
+	}
+	for _, test := range tests {
+		dir := t.TempDir()
+		recorder, err := NewRecorder(dir, "hub", test.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for n := 0; n < 3; n++ {
+			recorder.RecordHubMessage(testHubMessage(n))
+			time.Sleep(test.pause)
+		}
+		if err := recorder.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		paths, err := RecordingFiles(dir, "hub")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []HubMessage
+		for _, path := range paths {
+			msgs := readRecording(t, path)
+			if len(msgs) > 1 {
+				t.Errorf("%s: %s holds %d messages, want one per file", test.name, filepath.Base(path), len(msgs))
+			}
+			got = append(got, msgs...)
+		}
+		if len(got) != 3 {
+			t.Fatalf("%s: read %d messages, want 3", test.name, len(got))
+		}
+		for n, msg := range got {
+			if !bytes.Equal(msg.Payload, testHubMessage(n).Payload) {
+				t.Errorf("%s: message %d has payload %s", test.name, n, msg.Payload)
+			}
+			if msg.Received.IsZero() || n > 0 && msg.Monotonic < got[n-1].Monotonic {
+				t.Errorf("%s: message %d received %v at offset %v", test.name, n, msg.Received, msg.Monotonic)
+			}
+		}
+	}
+}
+
+func TestRecordingReaderTruncated(t *testing.T) {
+	msgs := []HubMessage{testHubMessage(0), testHubMessage(1), testHubMessage(2)}
+	tests := []struct {
+		name string
+		size func(flushed, total int) int
+		want int
+	}{
+		{"intact", wholeFile, 3},
+		{"missing gzip trailer", func(_, total int) int { return total - 8 }, 3},
+		{"cut mid record", func(flushed, _ int) int { return flushed + 4 }, 2},
+		{"cut at flush", func(flushed, _ int) int { return flushed }, 2},
+	}
+	for _, test := range tests {
+		dir := t.TempDir()
+		first := filepath.Join(dir, "hub-1"+recordingExtension)
+		second := filepath.Join(dir, "hub-2"+recordingExtension)
+		writeRecording(t, first, msgs, test.size)
+		writeRecording(t, second, []HubMessage{testHubMessage(3)}, wholeFile)
+
+		got := readRecording(t, first, second)
+		if len(got) != test.want+1 {
+			t.Fatalf("%s: read %d messages, want %d", test.name, len(got), test.want+1)
+		}
+		for i, msg := range got[:test.want] {
+			if !bytes.Equal(msg.Payload, msgs[i].Payload) {
+				t.Errorf("%s: message %d has payload %s", test.name, i, msg.Payload)
+			}
+		}
+		if last := got[test.want]; !bytes.Equal(last.Payload, testHubMessage(3).Payload) {
+			t.Errorf("%s: next file starts with %s", test.name, last.Payload)
+		}
+	}
+}
+
+func TestUserDataReceiverRecord(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		data      interface{}
+		accountID int32
+		err       bool
+	}{
+		{"account uses id", "GatewayUserAccount", map[string]interface{}{"data": map[string]interface{}{"id": 7}}, 7, false},
+		{"order uses accountId", "GatewayUserOrder", map[string]interface{}{"data": map[string]interface{}{"id": 101, "accountId": 8}}, 8, false},
+		{"unexpected shape is kept", "GatewayUserTrade", []interface{}{1}, 0, true},
+		{"unencodable payload", "GatewayUserPosition", math.Inf(1), 0, true},
+	}
+	for _, test := range tests {
+		dir := t.TempDir()
+		recorder, err := NewRecorder(dir, "user")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var errs []error
+		recorder.SetErrorHandler(func(err error) { errs = append(errs, err) })
+
+		receiver := NewUserDataReceiver(nil)
+		receiver.SetRecorder(recorder)
+		switch test.method {
+		case "GatewayUserAccount":
+			receiver.GatewayUserAccount(test.data)
+		case "GatewayUserOrder":
+			receiver.GatewayUserOrder(test.data)
+		case "GatewayUserPosition":
+			receiver.GatewayUserPosition(test.data)
+		case "GatewayUserTrade":
+			receiver.GatewayUserTrade(test.data)
+		}
+		if err := recorder.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		if test.err != (len(errs) == 1) {
+			t.Errorf("%s: errors %v", test.name, errs)
+		}
+		paths, _ := RecordingFiles(dir, "user")
+		msgs := readRecording(t, paths...)
+		if test.data == math.Inf(1) {
+			if len(msgs) != 0 {
+				t.Errorf("%s: recorded %+v", test.name, msgs)
+			}
+			continue
+		}
+		if len(msgs) != 1 || msgs[0].Hub != HubUser || msgs[0].Method != test.method || msgs[0].AccountID != test.accountID {
+			t.Errorf("%s: recorded %+v, want account %d", test.name, msgs, test.accountID)
+		}
+	}
+}
+
+func TestUserDataReceiverRecordsWithoutLock(t *testing.T) {
+	recorder := &hubMessages{entered: make(chan struct{}), block: make(chan struct{})}
+	receiver := NewUserDataReceiver(nil)
+	receiver.SetRecorder(recorder)
+
+	done := make(chan struct{})
+	go func() {
+		receiver.GatewayUserOrder(map[string]interface{}{"data": map[string]interface{}{"accountId": 8}})
+		close(done)
+	}()
+	<-recorder.entered
+
+	// A recorder that is falling behind must not hold up handler changes.
+	changed := make(chan struct{})
+	go func() {
+		receiver.SetHandler("order", func(interface{}) {})
+		close(changed)
+	}()
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Fatal("SetHandler blocked while the recorder was busy")
+	}
+
+	close(recorder.block)
+	<-done
+	if msgs := recorder.recorded(); len(msgs) != 1 || msgs[0].AccountID != 8 {
+		t.Errorf("recorded %+v", msgs)
+	}
+}
@@ -0,0 +1,442 @@
+package services
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ReplayAsFastAsPossible dispatches messages without waiting between them.
+const ReplayAsFastAsPossible = 0
+
+// HubMessageSource yields recorded hub messages in order and io.EOF at the
+// end.
+type HubMessageSource interface {
+	Next() (HubMessage, error)
+}
+
+// RecordingFiles lists the files a Recorder with prefix wrote to dir, in
+// recording order.
+func RecordingFiles(dir, prefix string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, prefix+"-*"+recordingExtension))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// RecordingReader reads recording files one after another. A file that ends
+// mid-record, as happens when the recording process dies, ends at its last
+// complete message.
+type RecordingReader struct {
+	paths []string
+	file  *os.File
+	dec   *json.Decoder
+}
+
+func OpenRecording(paths ...string) *RecordingReader {
+	return &RecordingReader{paths: paths}
+}
+
+func (r *RecordingReader) Next() (HubMessage, error) {
+	for {
+		if r.dec == nil {
+			if len(r.paths) == 0 {
+				return HubMessage{}, io.EOF
+			}
+			if err := r.open(r.paths[0]); err != nil {
+				return HubMessage{}, err
+			}
+			r.paths = r.paths[1:]
+		}
+
+		var msg HubMessage
+		err := r.dec.Decode(&msg)
+		if err == nil {
+			return msg, nil
+		}
+		name := r.file.Name()
+		r.closeFile()
+		if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return HubMessage{}, fmt.Errorf("failed to read recording %s: %w", name, err)
+		}
+	}
+}
+
+func (r *RecordingReader) open(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open recording: %w", err)
+	}
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to open recording %s: %w", path, err)
+	}
+	r.file = file
+	r.dec = json.NewDecoder(gz)
+	return nil
+}
+
+func (r *RecordingReader) closeFile() {
+	if r.file != nil {
+		r.file.Close()
+	}
+	r.file, r.dec = nil, nil
+}
+
+// Close releases the file being read.
+func (r *RecordingReader) Close() error {
+	r.closeFile()
+	r.paths = nil
+	return nil
+}
+
+// Replayer feeds recorded hub messages through the receivers of a
+// MarketDataWebSocketService and a UserDataWebSocketService, so the handlers
+// and subscribers registered on them run exactly as they do live. Either
+// service may be nil to skip its messages, and neither needs to be
+// connected.
+//
+// The replay clock follows the recorded monotonic offsets, so pauses and wall
+// clock jumps during recording do not distort it. Speed 1 replays in real
+// time, higher speeds accelerate and ReplayAsFastAsPossible does not wait at
+// all.
+type Replayer struct {
+	source HubMessageSource
+	market *MarketDataWebSocketService
+	user   *UserDataWebSocketService
+
+	mu      sync.Mutex
+	speed   float64
+	paused  bool
+	changed chan struct{}
+
+	pending     *HubMessage
+	pendingTime time.Time
+	current     time.Time
+	baseWall    time.Time
+	baseMono    time.Duration
+	lastMono    time.Duration
+	session     time.Time
+	based       bool
+
+	running    bool
+	anchorWall time.Time
+	anchorTime time.Time
+
+	messageHandler func(HubMessage)
+	errorHandler   func(error)
+}
+
+func NewReplayer(source HubMessageSource, market *MarketDataWebSocketService, user *UserDataWebSocketService) *Replayer {
+	return &Replayer{
+		source:  source,
+		market:  market,
+		user:    user,
+		speed:   1,
+		changed: make(chan struct{}),
+	}
+}
+
+// SetSpeed changes the replay speed, taking effect for the message being
+// waited on.
+func (r *Replayer) SetSpeed(speed float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reanchor()
+	r.speed = max(speed, 0)
+	r.notify()
+}
+
+func (r *Replayer) Pause() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reanchor()
+	r.paused = true
+	r.notify()
+}
+
+func (r *Replayer) Resume() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.paused {
+		return
+	}
+	// The clock stood still at the time it was paused.
+	r.paused = false
+	r.anchorWall = time.Now()
+	r.notify()
+}
+
+// SetMessageHandler is called with every message after it was dispatched.
+func (r *Replayer) SetMessageHandler(handler func(HubMessage)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messageHandler = handler
+}
+
+func (r *Replayer) SetErrorHandler(handler func(error)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errorHandler = handler
+}
+
+func (r *Replayer) reportError(err error) {
+	r.mu.Lock()
+	handler := r.errorHandler
+	r.mu.Unlock()
+
+	if handler != nil {
+		handler(err)
+	}
+}
+
+// Now returns the replay clock. While handlers run it is the time of the
+// message being dispatched; while Run waits for the next message it advances
+// with the replay speed without passing that message.
+func (r *Replayer) Now() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.nowLocked()
+}
+
+// Clock returns Now as a function, for components that take a clock.
+func (r *Replayer) Clock() func() time.Time {
+	return r.Now
+}
+
+func (r *Replayer) nowLocked() time.Time {
+	if !r.running || r.speed <= 0 || r.anchorWall.IsZero() || r.pending == nil {
+		return r.current
+	}
+
+	now := r.anchorTime
+	if !r.paused {
+		now = now.Add(time.Duration(float64(time.Since(r.anchorWall)) * r.speed))
+	}
+	if now.After(r.pendingTime) {
+		now = r.pendingTime
+	}
+	if now.Before(r.current) {
+		now = r.current
+	}
+	return now
+}
+
+// reanchor must be called with r.mu held.
+func (r *Replayer) reanchor() {
+	r.anchorTime = r.nowLocked()
+	r.anchorWall = time.Now()
+}
+
+// notify must be called with r.mu held.
+func (r *Replayer) notify() {
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// Run replays until the source is exhausted or ctx is done.
+func (r *Replayer) Run(ctx context.Context) error {
+	return r.run(ctx, time.Time{})
+}
+
+// RunUntil replays the messages up to and including until on the replay
+// clock and leaves the rest for the next call.
+func (r *Replayer) RunUntil(ctx context.Context, until time.Time) error {
+	return r.run(ctx, until)
+}
+
+// Step dispatches the next message without waiting and returns it, or
+// io.EOF at the end of the source.
+func (r *Replayer) Step() (HubMessage, error) {
+	r.mu.Lock()
+	msg, at, err := r.peek()
+	if err != nil {
+		r.mu.Unlock()
+		return HubMessage{}, err
+	}
+	r.advance(at)
+	r.mu.Unlock()
+
+	r.dispatch(msg)
+	return msg, nil
+}
+
+func (r *Replayer) run(ctx context.Context, until time.Time) error {
+	r.mu.Lock()
+	if r.running {
+		r.mu.Unlock()
+		return fmt.Errorf("replay already running")
+	}
+	r.running = true
+	r.anchorWall = time.Time{}
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		r.running = false
+		r.mu.Unlock()
+	}()
+
+	for {
+		r.mu.Lock()
+		msg, at, err := r.peek()
+		if err != nil {
+			r.mu.Unlock()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if !until.IsZero() && at.After(until) {
+			r.current = until
+			r.mu.Unlock()
+			return nil
+		}
+		if r.anchorWall.IsZero() {
+			r.anchorWall = time.Now()
+			r.anchorTime = r.current
+			if r.anchorTime.IsZero() || r.anchorTime.After(at) {
+				r.anchorTime = at
+			}
+		}
+		r.mu.Unlock()
+
+		if err := r.wait(ctx, at); err != nil {
+			return err
+		}
+
+		r.mu.Lock()
+		r.advance(at)
+		r.mu.Unlock()
+		r.dispatch(msg)
+	}
+}
+
+// wait blocks until the replay clock reaches at.
+func (r *Replayer) wait(ctx context.Context, at time.Time) error {
+	for {
+		r.mu.Lock()
+		changed := r.changed
+		paused := r.paused
+		var delay time.Duration
+		if !paused && r.speed > 0 {
+			target := r.anchorWall.Add(time.Duration(float64(at.Sub(r.anchorTime)) / r.speed))
+			delay = time.Until(target)
+		}
+		r.mu.Unlock()
+
+		if !paused && delay <= 0 {
+			return ctx.Err()
+		}
+
+		var timer *time.Timer
+		var fired <-chan time.Time
+		if !paused {
+			timer = time.NewTimer(delay)
+			fired = timer.C
+		}
+		select {
+		case <-ctx.Done():
+			stopTimer(timer)
+			return ctx.Err()
+		case <-changed:
+			stopTimer(timer)
+		case <-fired:
+			return nil
+		}
+	}
+}
+
+func stopTimer(timer *time.Timer) {
+	if timer != nil {
+		timer.Stop()
+	}
+}
+
+// peek must be called with r.mu held. It returns the next message and its
+// time on the replay clock without consuming it.
+func (r *Replayer) peek() (HubMessage, time.Time, error) {
+	if r.pending == nil {
+		msg, err := r.source.Next()
+		if err != nil {
+			return HubMessage{}, time.Time{}, err
+		}
+
+		// Monotonic offsets restart with every recording session. Recordings
+		// without a session only show a new one when the offset goes back.
+		if !r.based || !msg.Session.Equal(r.session) || msg.Monotonic < r.lastMono {
+			r.baseWall = msg.Received
+			r.baseMono = msg.Monotonic
+			r.session = msg.Session
+			r.based = true
+		}
+		r.lastMono = msg.Monotonic
+		r.pending = &msg
+		r.pendingTime = r.baseWall.Add(msg.Monotonic - r.baseMono)
+	}
+	return *r.pending, r.pendingTime, nil
+}
+
+// advance must be called with r.mu held. The anchor is left alone so that
+// time spent in handlers is caught up on instead of accumulating as lag.
+func (r *Replayer) advance(at time.Time) {
+	r.pending = nil
+	if at.After(r.current) {
+		r.current = at
+	}
+}
+
+func (r *Replayer) dispatch(msg HubMessage) {
+	var data interface{}
+	if err := json.Unmarshal(msg.Payload, &data); err != nil {
+		r.reportError(fmt.Errorf("failed to decode %s message: %w", msg.Method, err))
+		return
+	}
+
+	switch msg.Hub {
+	case HubMarket:
+		if r.market == nil {
+			break
+		}
+		switch msg.Method {
+		case "GatewayQuote":
+			r.market.receiver.GatewayQuote(msg.ContractID, data)
+		case "GatewayTrade":
+			r.market.receiver.GatewayTrade(msg.ContractID, data)
+		case "GatewayDepth":
+			r.market.receiver.GatewayDepth(msg.ContractID, data)
+		}
+	case HubUser:
+		if r.user == nil {
+			break
+		}
+		switch msg.Method {
+		case "GatewayUserAccount":
+			r.user.receiver.GatewayUserAccount(data)
+		case "GatewayUserOrder":
+			r.user.receiver.GatewayUserOrder(data)
+		case "GatewayUserPosition":
+			r.user.receiver.GatewayUserPosition(data)
+		case "GatewayUserTrade":
+			r.user.receiver.GatewayUserTrade(data)
+		}
+	}
+
+	r.mu.Lock()
+	handler := r.messageHandler
+	r.mu.Unlock()
+	if handler != nil {
+		handler(msg)
+	}
+}
@@ -0,0 +1,272 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tradingiq/projectx-client/client"
+	"github.com/tradingiq/projectx-client/models"
+)
+
+var replayTestStart = time.Date(2025, 3, 14, 14, 0, 0, 0, time.UTC)
+
+// hubMessageSlice is a HubMessageSource over messages in memory.
+type hubMessageSlice []HubMessage
+
+func (s *hubMessageSlice) Next() (HubMessage, error) {
+	if len(*s) == 0 {
+		return HubMessage{}, io.EOF
+	}
+	msg := (*s)[0]
+	*s = (*s)[1:]
+	return msg, nil
+}
+
+func replayMessage(session, received time.Time, monotonic time.Duration) HubMessage {
+	return HubMessage{
+		Hub:       HubMarket,
+		Method:    "GatewayTrade",
+		Payload:   json.RawMessage(`[]`),
+		Received:  received,
+		Monotonic: monotonic,
+		Session:   session,
+	}
+}
+
+// replayEvents registers handlers on market and user that collect the decoded
+// events, each with the time clock returns while it is handled.
+func replayEvents(market *MarketDataWebSocketService, user *UserDataWebSocketService, clock func() time.Time) (*[]interface{}, *[]time.Time) {
+	var events []interface{}
+	var times []time.Time
+	market.OnTrade(riskTestContract, func(_ string, trades models.TradeData) {
+		events = append(events, trades)
+		times = append(times, clock())
+	})
+	user.OnOrder(func(update *models.OrderUpdateData) {
+		events = append(events, *update)
+		times = append(times, clock())
+	})
+	return &events, &times
+}
+
+func TestReplayRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := NewRecorder(dir, "hub", WithRecorderRotation(1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	market := NewMarketDataWebSocketService(client.NewClient())
+	user := NewUserDataWebSocketService(client.NewClient())
+	market.SetRecorder(recorder)
+	user.SetRecorder(recorder)
+	live, _ := replayEvents(market, user, time.Now)
+
+	trade := func(price float64) interface{} {
+		return []interface{}{map[string]interface{}{
+			"symbolId": "F.US.MES", "price": price, "timestamp": "2025-03-14T14:30:00.123Z", "type": 0, "volume": 2,
+		}}
+	}
+	market.receiver.GatewayTrade(riskTestContract, trade(5012.25))
+	time.Sleep(5 * time.Millisecond)
+	user.receiver.GatewayUserOrder(map[string]interface{}{
+		"action": 1,
+		"data":   map[string]interface{}{"id": 101, "accountId": 7, "contractId": riskTestContract, "status": 1, "type": 1, "side": 0, "size": 1, "limitPrice": 5010.5},
+	})
+	time.Sleep(5 * time.Millisecond)
+	market.receiver.GatewayTrade(riskTestContract, trade(5013.5))
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	paths, err := RecordingFiles(dir, "hub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) < 3 {
+		t.Fatalf("recorded %d files, want the recording rotated after every message", len(paths))
+	}
+	recorded := readRecording(t, paths...)
+	source := OpenRecording(paths...)
+	defer source.Close()
+
+	replayMarket := NewMarketDataWebSocketService(client.NewClient())
+	replayUser := NewUserDataWebSocketService(client.NewClient())
+	replay := NewReplayer(source, replayMarket, replayUser)
+	replay.SetSpeed(ReplayAsFastAsPossible)
+	replayed, replayedTimes := replayEvents(replayMarket, replayUser, replay.Now)
+
+	if err := replay.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(*replayed, *live) {
+		t.Fatalf("replayed %+v\nwant %+v", *replayed, *live)
+	}
+	// The replay clock follows the monotonic offsets, which may drift from
+	// the wall clock receive times by clock slewing only.
+	for i, at := range *replayedTimes {
+		if d := at.Sub(recorded[i].Received); d < -time.Millisecond || d > time.Millisecond {
+			t.Errorf("event %d replayed at %v, received at %v", i, at, recorded[i].Received)
+		}
+	}
+}
+
+func TestReplayerSessions(t *testing.T) {
+	start := replayTestStart
+	later := start.Add(time.Hour)
+	tests := []struct {
+		name     string
+		messages []HubMessage
+		times    []time.Time
+	}{
+		{
+			name: "wall clock jump within a session",
+			messages: []HubMessage{
+				replayMessage(start, start, 0),
+				replayMessage(start, start.Add(-10*time.Minute), 2*time.Second),
+			},
+			times: []time.Time{start, start.Add(2 * time.Second)},
+		},
+		{
+			name: "next session starts at a larger offset",
+			messages: []HubMessage{
+				replayMessage(start, start, 0),
+				replayMessage(start, start.Add(time.Second), time.Second),
+				replayMessage(later, later.Add(5*time.Second), 5*time.Second),
+			},
+			times: []time.Time{start, start.Add(time.Second), later.Add(5 * time.Second)},
+		},
+		{
+			name: "next session starts at a smaller offset",
+			messages: []HubMessage{
+				replayMessage(start, start.Add(time.Second), time.Second),
+				replayMessage(later, later, 0),
+			},
+			times: []time.Time{start.Add(time.Second), later},
+		},
+		{
+			name: "recordings without sessions",
+			messages: []HubMessage{
+				replayMessage(time.Time{}, start, 0),
+				replayMessage(time.Time{}, start.Add(time.Second), time.Second),
+				replayMessage(time.Time{}, later, 0),
+			},
+			times: []time.Time{start, start.Add(time.Second), later},
+		},
+	}
+	for _, test := range tests {
+		source := hubMessageSlice(test.messages)
+		replay := NewReplayer(&source, nil, nil)
+		for i, want := range test.times {
+			if _, err := replay.Step(); err != nil {
+				t.Fatalf("%s: step %d: %v", test.name, i, err)
+			}
+			if got := replay.Now(); !got.Equal(want) {
+				t.Errorf("%s: message %d at %v, want %v", test.name, i, got, want)
+			}
+		}
+	}
+}
+
+func TestReplayerReportsUndecodablePayload(t *testing.T) {
+	msg := replayMessage(replayTestStart, replayTestStart, 0)
+	msg.Payload = json.RawMessage(`{"price":`)
+	source := hubMessageSlice{msg}
+
+	replay := NewReplayer(&source, NewMarketDataWebSocketService(client.NewClient()), nil)
+	var errs []error
+	replay.SetErrorHandler(func(err error) { errs = append(errs, err) })
+	if _, err := replay.Step(); err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 1 {
+		t.Errorf("reported %v, want one decode error", errs)
+	}
+}
+
+func TestReplayerPauseResume(t *testing.T) {
+	source := hubMessageSlice{
+		replayMessage(replayTestStart, replayTestStart, 0),
+		replayMessage(replayTestStart, replayTestStart.Add(10*time.Second), 10*time.Second),
+	}
+	replay := NewReplayer(&source, nil, nil)
+	replay.SetSpeed(1000)
+	dispatched := make(chan HubMessage, len(source))
+	replay.SetMessageHandler(func(msg HubMessage) { dispatched <- msg })
+
+	replay.Pause()
+	done := make(chan error, 1)
+	go func() { done <- replay.Run(context.Background()) }()
+
+	time.Sleep(30 * time.Millisecond)
+	if len(dispatched) != 0 {
+		t.Fatal("dispatched while paused")
+	}
+	if now := replay.Now(); !now.Equal(replayTestStart) {
+		t.Errorf("clock at %v while paused, want %v", now, replayTestStart)
+	}
+
+	replay.Resume()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("replay did not finish after Resume")
+	}
+	if len(dispatched) != 2 {
+		t.Errorf("dispatched %d messages, want 2", len(dispatched))
+	}
+	if want := replayTestStart.Add(10 * time.Second); !replay.Now().Equal(want) {
+		t.Errorf("clock at %v, want %v", replay.Now(), want)
+	}
+}
+
+func TestReplayerSetSpeed(t *testing.T) {
+	end := replayTestStart.Add(time.Hour)
+	source := hubMessageSlice{
+		replayMessage(replayTestStart, replayTestStart, 0),
+		replayMessage(replayTestStart, end, time.Hour),
+	}
+	replay := NewReplayer(&source, nil, nil)
+	dispatched := make(chan HubMessage, len(source))
+	replay.SetMessageHandler(func(msg HubMessage) { dispatched <- msg })
+
+	done := make(chan error, 1)
+	go func() { done <- replay.Run(context.Background()) }()
+	<-dispatched
+
+	// In real time the clock moves on, short of the next message.
+	time.Sleep(20 * time.Millisecond)
+	now := replay.Now()
+	if !now.After(replayTestStart) || !now.Before(replayTestStart.Add(time.Second)) {
+		t.Errorf("clock at %v, want just after %v", now, replayTestStart)
+	}
+
+	replay.Pause()
+	paused := replay.Now()
+	time.Sleep(20 * time.Millisecond)
+	if now := replay.Now(); !now.Equal(paused) {
+		t.Errorf("clock moved from %v to %v while paused", paused, now)
+	}
+	replay.Resume()
+
+	replay.SetSpeed(ReplayAsFastAsPossible)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("replay still waiting after SetSpeed")
+	}
+	if !replay.Now().Equal(end) {
+		t.Errorf("clock at %v, want %v", replay.Now(), end)
+	}
+}
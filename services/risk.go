@@ -0,0 +1,830 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/tradingiq/projectx-client/models"
+)
+
+var ErrRiskRejected = errors.New("rejected by risk check")
+
+// RiskRejection describes why a rule rejected an order. It is returned as the
+// error from PlaceOrder or ModifyOrder and matches ErrRiskRejected.
+type RiskRejection struct {
+	Rule       string
+	AccountID  int32
+	ContractID string
+	// OrderID is set when a modification was rejected.
+	OrderID int32
+	Reason  string
+	Limit   float64
+	Actual  float64
+	Time    time.Time
+}
+
+func (r *RiskRejection) Error() string {
+	return fmt.Sprintf("%v: %s: account %d %s: %s", ErrRiskRejected, r.Rule, r.AccountID, r.ContractID, r.Reason)
+}
+
+func (r *RiskRejection) Unwrap() error {
+	return ErrRiskRejected
+}
+
+// RiskOrder is the order a rule is asked to approve. For modifications it is
+// the existing order with the requested changes applied.
+type RiskOrder struct {
+	AccountID  int32
+	ContractID string
+	Type       models.OrderType
+	Side       models.OrderSide
+	Size       int32
+	LimitPrice *float64
+	StopPrice  *float64
+	Modify     bool
+	OrderID    int32
+}
+
+// Price is the limit or stop price of the order, whichever applies.
+func (o *RiskOrder) Price() (float64, bool) {
+	if o.LimitPrice != nil {
+		return *o.LimitPrice, true
+	}
+	if o.StopPrice != nil {
+		return *o.StopPrice, true
+	}
+	return 0, false
+}
+
+// RiskState is what the guard knows about the account when a rule runs.
+type RiskState struct {
+	Now      time.Time
+	Contract *models.ContractModel
+	Quote    *models.Quote
+	// Positions is empty when the guard has no position book.
+	Positions  []PositionPnL
+	OpenOrders []models.OrderModel
+	// OCOGroups is empty when the guard has no OCO manager.
+	OCOGroups     []OCOGroup
+	RealizedPnL   float64
+	UnrealizedPnL float64
+}
+
+// NetPosition is the signed size held in contractID, positive when long.
+func (s *RiskState) NetPosition(contractID string) int32 {
+	for _, pnl := range s.Positions {
+		if pnl.Position.ContractID == contractID {
+			return signedPositionSize(pnl.Position)
+		}
+	}
+	return 0
+}
+
+// Projected is the worst case signed position in the order's contract with
+// the order working alongside the other working orders. Orders that each fit
+// inside the position built by the opposite side count as its exits rather
+// than as new exposure, so the stop loss and take profit of a bracket do not
+// add up. Members of an OCO group on the same side count as their largest
+// member. When either side could be the exits, the reading with the smaller
+// exposure is used.
+func (s *RiskState) Projected(order *RiskOrder) int32 {
+	group := make(map[int32]string)
+	for _, g := range s.OCOGroups {
+		for _, member := range g.Members {
+			group[member.OrderID] = g.ID
+		}
+	}
+
+	// Sizes per side, with the members of an OCO group merged into one.
+	var buys, sells []int32
+	merged := make(map[string]int)
+	add := func(orderID int32, side models.OrderSide, size int32) {
+		if size <= 0 {
+			return
+		}
+		sizes := &buys
+		if side == models.OrderSideAsk {
+			sizes = &sells
+		}
+		groupID, grouped := group[orderID]
+		key := fmt.Sprintf("%s/%d", groupID, side)
+		if i, ok := merged[key]; grouped && ok {
+			(*sizes)[i] = max((*sizes)[i], size)
+			return
+		}
+		if grouped {
+			merged[key] = len(*sizes)
+		}
+		*sizes = append(*sizes, size)
+	}
+
+	for _, open := range s.OpenOrders {
+		if open.ContractID != order.ContractID || (order.Modify && open.ID == order.OrderID) {
+			continue
+		}
+		add(open.ID, open.Side, open.Size-open.FillVolume)
+	}
+	orderID := int32(0)
+	if order.Modify {
+		orderID = order.OrderID
+	}
+	add(orderID, order.Side, order.Size)
+
+	net := s.NetPosition(order.ContractID)
+
+	// Buys open, sells that fit inside the long they build are its exits.
+	long := net + sum32(buys)
+	buysOpen := worse(long, net-unprotected(sells, long))
+
+	// Sells open, buys that fit inside the short they build are its exits.
+	short := net - sum32(sells)
+	sellsOpen := worse(net+unprotected(buys, -short), short)
+
+	if abs32(sellsOpen) < abs32(buysOpen) {
+		return sellsOpen
+	}
+	return buysOpen
+}
+
+// Reduces reports whether filling the order on its own would only shrink the
+// current position.
+func (s *RiskState) Reduces(order *RiskOrder) bool {
+	net := s.NetPosition(order.ContractID)
+	after := net + signedOrderSize(order.Side, order.Size)
+	return net != 0 && abs32(after) < abs32(net) && (after == 0 || (after > 0) == (net > 0))
+}
+
+type RiskRule interface {
+	Name() string
+	// Check returns nil to approve the order.
+	Check(order *RiskOrder, state *RiskState) *RiskRejection
+}
+
+type riskRuleFunc struct {
+	name  string
+	check func(order *RiskOrder, state *RiskState) *RiskRejection
+}
+
+func (r riskRuleFunc) Name() string {
+	return r.name
+}
+
+func (r riskRuleFunc) Check(order *RiskOrder, state *RiskState) *RiskRejection {
+	return r.check(order, state)
+}
+
+// RiskRuleFunc adapts a function to a RiskRule. The guard fills in the rule
+// name, account, contract and time of rejections it returns.
+func RiskRuleFunc(name string, check func(order *RiskOrder, state *RiskState) *RiskRejection) RiskRule {
+	return riskRuleFunc{name: name, check: check}
+}
+
+// MaxContractsPerInstrument limits the worst case position a contract could
+// reach with the order working, as computed by RiskState.Projected.
+func MaxContractsPerInstrument(max int32) RiskRule {
+	return RiskRuleFunc("max_contracts_per_instrument", func(order *RiskOrder, state *RiskState) *RiskRejection {
+		projected := abs32(state.Projected(order))
+		if projected <= max || projected <= abs32(state.NetPosition(order.ContractID)) {
+			return nil
+		}
+		return &RiskRejection{
+			Reason: fmt.Sprintf("projected position %d exceeds %d contracts", projected, max),
+			Limit:  float64(max),
+			Actual: float64(projected),
+		}
+	})
+}
+
+// MaxContractsPerAccount limits the sum of absolute positions across all
+// contracts of the account, using the projected position for the order's
+// contract.
+func MaxContractsPerAccount(max int32) RiskRule {
+	return RiskRuleFunc("max_contracts_per_account", func(order *RiskOrder, state *RiskState) *RiskRejection {
+		total := abs32(state.Projected(order))
+		current := int32(0)
+		for _, pnl := range state.Positions {
+			size := abs32(signedPositionSize(pnl.Position))
+			current += size
+			if pnl.Position.ContractID != order.ContractID {
+				total += size
+			}
+		}
+		if total <= max || total <= current {
+			return nil
+		}
+		return &RiskRejection{
+			Reason: fmt.Sprintf("projected account exposure %d exceeds %d contracts", total, max),
+			Limit:  float64(max),
+			Actual: float64(total),
+		}
+	})
+}
+
+// MaxOpenOrders limits the number of working orders; modifications are not
+// counted as new orders.
+func MaxOpenOrders(max int) RiskRule {
+	return RiskRuleFunc("max_open_orders", func(order *RiskOrder, state *RiskState) *RiskRejection {
+		if order.Modify || len(state.OpenOrders)+1 <= max {
+			return nil
+		}
+		return &RiskRejection{
+			Reason: fmt.Sprintf("%d open orders, limit is %d", len(state.OpenOrders), max),
+			Limit:  float64(max),
+			Actual: float64(len(state.OpenOrders) + 1),
+		}
+	})
+}
+
+// MaxNotional limits the value of a single order, price times size times the
+// contract point value (tick value / tick size). Market orders are valued at
+// the last quote; orders that cannot be valued are rejected.
+func MaxNotional(max float64) RiskRule {
+	return RiskRuleFunc("max_notional", func(order *RiskOrder, state *RiskState) *RiskRejection {
+		if state.Contract == nil || state.Contract.TickSize <= 0 {
+			return &RiskRejection{Reason: "contract tick size unknown, cannot value order", Limit: max}
+		}
+		price, ok := order.Price()
+		if !ok && state.Quote != nil {
+			price = markPrice(*state.Quote)
+		}
+		if price == 0 {
+			return &RiskRejection{Reason: "no price or quote, cannot value order", Limit: max}
+		}
+
+		notional := math.Abs(price) * float64(order.Size) * state.Contract.TickValue / state.Contract.TickSize
+		if notional <= max {
+			return nil
+		}
+		return &RiskRejection{
+			Reason: fmt.Sprintf("notional %.2f exceeds %.2f", notional, max),
+			Limit:  max,
+			Actual: notional,
+		}
+	})
+}
+
+// PriceCollar rejects limit and stop prices more than maxTicks away from the
+// last quote. Orders with a price are rejected when no quote is available.
+func PriceCollar(maxTicks float64) RiskRule {
+	return RiskRuleFunc("price_collar", func(order *RiskOrder, state *RiskState) *RiskRejection {
+		price, ok := order.Price()
+		if !ok {
+			return nil
+		}
+		if state.Contract == nil || state.Contract.TickSize <= 0 {
+			return &RiskRejection{Reason: "contract tick size unknown", Limit: maxTicks}
+		}
+		reference := 0.0
+		if state.Quote != nil {
+			reference = markPrice(*state.Quote)
+		}
+		if reference == 0 {
+			return &RiskRejection{Reason: "no quote to check price against", Limit: maxTicks}
+		}
+
+		ticks := math.Abs(price-reference) / state.Contract.TickSize
+		if ticks <= maxTicks+1e-9 {
+			return nil
+		}
+		return &RiskRejection{
+			Reason: fmt.Sprintf("price %v is %.1f ticks from %v", price, ticks, reference),
+			Limit:  maxTicks,
+			Actual: ticks,
+		}
+	})
+}
+
+// DailyLossLimit rejects orders that do not reduce a position once realized
+// plus unrealized P&L reaches -maxLoss.
+func DailyLossLimit(maxLoss float64) RiskRule {
+	return RiskRuleFunc("daily_loss_limit", func(order *RiskOrder, state *RiskState) *RiskRejection {
+		pnl := state.RealizedPnL + state.UnrealizedPnL
+		if pnl > -maxLoss || state.Reduces(order) {
+			return nil
+		}
+		return &RiskRejection{
+			Reason: fmt.Sprintf("daily P&L %.2f breaches loss limit %.2f", pnl, maxLoss),
+			Limit:  -maxLoss,
+			Actual: pnl,
+		}
+	})
+}
+
+// TradingWindow is a daily session from Start to End, both offsets from
+// midnight. A window whose End is not after Start runs past midnight. Days
+// lists the weekdays on which the window opens; empty means every day.
+type TradingWindow struct {
+	Days  []time.Weekday
+	Start time.Duration
+	End   time.Duration
+}
+
+func (w TradingWindow) opensOn(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+func (w TradingWindow) contains(t time.Time) bool {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	offset := t.Sub(midnight)
+	if w.Start < w.End {
+		return w.opensOn(t.Weekday()) && offset >= w.Start && offset < w.End
+	}
+	return (w.opensOn(t.Weekday()) && offset >= w.Start) ||
+		(w.opensOn(midnight.AddDate(0, 0, -1).Weekday()) && offset < w.End)
+}
+
+// TradingHours rejects orders outside every window, evaluated in loc.
+func TradingHours(loc *time.Location, windows ...TradingWindow) RiskRule {
+	return RiskRuleFunc("trading_hours", func(order *RiskOrder, state *RiskState) *RiskRejection {
+		now := state.Now.In(loc)
+		for _, window := range windows {
+			if window.contains(now) {
+				return nil
+			}
+		}
+		return &RiskRejection{
+			Reason: fmt.Sprintf("%s is outside trading hours", now.Format("Mon 15:04 MST")),
+		}
+	})
+}
+
+// RiskGuard runs per-account rules before orders are placed or modified.
+// Install it with OrderService.SetPreTradeCheck. Positions and unrealized
+// P&L come from the position book, working orders from an order tracker when
+// one is registered for the account and from REST otherwise.
+type RiskGuard struct {
+	orders     *OrderService
+	contracts  *ContractService
+	trades     *TradeService
+	marketData *MarketDataWebSocketService
+	positions  *PositionBook
+
+	mu           sync.RWMutex
+	defaultRules []RiskRule
+	rules        map[int32][]RiskRule
+	trackers     map[int32]*OrderTracker
+	oco          *OCOManager
+	realized     map[int32]float64
+	realizedFrom map[int32]time.Time
+	// realizedSeq orders the realized P&L updates of an account, so that a
+	// slow sync cannot overwrite a newer value.
+	realizedSeq  map[int32]uint64
+	contractInfo map[string]models.ContractModel
+	errorHandler func(error)
+	now          func() time.Time
+
+	rejections *subscribers[func(RiskRejection)]
+}
+
+// NewRiskGuard creates a guard; contracts, trades, marketData and positions
+// may be nil, in which case the rules see no contract metadata, realized P&L,
+// quotes or positions respectively.
+func NewRiskGuard(orders *OrderService, contracts *ContractService, trades *TradeService, marketData *MarketDataWebSocketService, positions *PositionBook) *RiskGuard {
+	return &RiskGuard{
+		orders:       orders,
+		contracts:    contracts,
+		trades:       trades,
+		marketData:   marketData,
+		positions:    positions,
+		rules:        make(map[int32][]RiskRule),
+		trackers:     make(map[int32]*OrderTracker),
+		realized:     make(map[int32]float64),
+		realizedFrom: make(map[int32]time.Time),
+		realizedSeq:  make(map[int32]uint64),
+		contractInfo: make(map[string]models.ContractModel),
+		now:          time.Now,
+		rejections:   newSubscribers[func(RiskRejection)](),
+	}
+}
+
+// SetDefaultRules applies rules to accounts without rules of their own.
+func (g *RiskGuard) SetDefaultRules(rules ...RiskRule) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.defaultRules = rules
+}
+
+func (g *RiskGuard) SetRules(accountID int32, rules ...RiskRule) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.rules[accountID] = rules
+}
+
+// TrackOrders uses tracker for the working orders of accountID instead of
+// searching open orders on every check.
+func (g *RiskGuard) TrackOrders(accountID int32, tracker *OrderTracker) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.trackers[accountID] = tracker
+}
+
+// TrackOCO lets the position rules count working orders grouped in m as one
+// order per side instead of adding them up.
+func (g *RiskGuard) TrackOCO(m *OCOManager) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.oco = m
+}
+
+// SetContract provides contract metadata so that it does not have to be
+// looked up on the first order.
+func (g *RiskGuard) SetContract(contract models.ContractModel) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.contractInfo[contract.ID] = contract
+}
+
+func (g *RiskGuard) SetErrorHandler(handler func(error)) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.errorHandler = handler
+}
+
+// SetRealizedPnL sets the realized P&L of accountID. Trade events followed
+// with FollowTrades replace it once SyncRealizedPnL has been called.
+func (g *RiskGuard) SetRealizedPnL(accountID int32, pnl float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.realizedSeq[accountID]++
+	g.realized[accountID] = pnl
+}
+
+// SyncRealizedPnL sets the realized P&L of accountID to the net profit of its
+// half-turn trades since the start of the trading day. Trade events followed
+// with FollowTrades resync from the same start; call it again with the new
+// start when a trading day begins.
+func (g *RiskGuard) SyncRealizedPnL(ctx context.Context, accountID int32, since time.Time) error {
+	if g.trades == nil {
+		return fmt.Errorf("risk guard has no trade service")
+	}
+
+	g.mu.Lock()
+	g.realizedFrom[accountID] = since
+	g.mu.Unlock()
+
+	return g.syncRealized(ctx, accountID)
+}
+
+func (g *RiskGuard) syncRealized(ctx context.Context, accountID int32) error {
+	g.mu.Lock()
+	since := g.realizedFrom[accountID]
+	g.realizedSeq[accountID]++
+	seq := g.realizedSeq[accountID]
+	g.mu.Unlock()
+
+	resp, err := g.trades.SearchHalfTurnTrades(ctx, &models.SearchTradeRequest{
+		AccountID:      accountID,
+		StartTimestamp: &since,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to search trades: %w", err)
+	}
+	if !resp.Success {
+		return fmt.Errorf("failed to search trades with error code: %v", resp.ErrorCode)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.realizedSeq[accountID] == seq {
+		g.realized[accountID] = realizedPnL(resp.Trades)
+	}
+	return nil
+}
+
+// FollowTrades resyncs the realized P&L of every account set up with
+// SyncRealizedPnL after each of its user hub trade events, so DailyLossLimit
+// sees closed trades as they happen. Trade events do not carry P&L, so the
+// half-turn trades are reloaded off the hub receive loop; failures go to the
+// error handler. The caller remains responsible for SubscribeTrades on the
+// user hub. It returns a function that stops following.
+func (g *RiskGuard) FollowTrades(ctx context.Context, userData *UserDataWebSocketService) func() {
+	return userData.OnTrade(func(update *models.TradeUpdateData) {
+		g.handleTradeUpdate(ctx, update)
+	})
+}
+
+func (g *RiskGuard) handleTradeUpdate(ctx context.Context, update *models.TradeUpdateData) {
+	accountID := update.Data.AccountID
+
+	g.mu.RLock()
+	_, synced := g.realizedFrom[accountID]
+	g.mu.RUnlock()
+	if !synced || g.trades == nil || ctx.Err() != nil {
+		return
+	}
+
+	go func() {
+		if err := g.syncRealized(ctx, accountID); err != nil && ctx.Err() == nil {
+			g.reportError(err)
+		}
+	}()
+}
+
+func (g *RiskGuard) reportError(err error) {
+	g.mu.RLock()
+	handler := g.errorHandler
+	g.mu.RUnlock()
+
+	if handler != nil {
+		handler(err)
+	}
+}
+
+// OnReject registers a callback for every rejection and returns a function
+// that removes it.
+func (g *RiskGuard) OnReject(handler func(RiskRejection)) func() {
+	return g.rejections.add(subscribeAll, handler)
+}
+
+func (g *RiskGuard) CheckPlace(ctx context.Context, req *models.PlaceOrderRequest) error {
+	return g.Check(ctx, &RiskOrder{
+		AccountID:  req.AccountID,
+		ContractID: req.ContractID,
+		Type:       req.Type,
+		Side:       req.Side,
+		Size:       req.Size,
+		LimitPrice: req.LimitPrice,
+		StopPrice:  req.StopPrice,
+	})
+}
+
+// CheckModify runs the rules only for modifies that add risk: a larger size
+// or a limit or stop price closer to the market. Size reductions and prices
+// moved away from the market are always allowed.
+func (g *RiskGuard) CheckModify(ctx context.Context, req *models.ModifyOrderRequest) error {
+	existing, err := g.workingOrder(ctx, req.AccountID, req.OrderID)
+	if err != nil {
+		return err
+	}
+
+	order := &RiskOrder{
+		AccountID:  req.AccountID,
+		ContractID: existing.ContractID,
+		Type:       existing.Type,
+		Side:       existing.Side,
+		Size:       existing.Size - existing.FillVolume,
+		LimitPrice: existing.LimitPrice,
+		StopPrice:  existing.StopPrice,
+		Modify:     true,
+		OrderID:    req.OrderID,
+	}
+	if req.Size != nil {
+		order.Size = *req.Size
+	}
+	if req.LimitPrice != nil {
+		order.LimitPrice = req.LimitPrice
+	}
+	if req.StopPrice != nil {
+		order.StopPrice = req.StopPrice
+	}
+	if !modifyAddsRisk(existing, order) {
+		return nil
+	}
+	return g.Check(ctx, order)
+}
+
+// modifyAddsRisk reports whether changing existing into order grows the
+// remaining size or moves a price toward the market, where it is more likely
+// to fill. Other modifies, such as reducing an OCO sibling or moving a stop
+// further away, are not checked so they stay possible at a limit.
+func modifyAddsRisk(existing models.OrderModel, order *RiskOrder) bool {
+	if order.Size > existing.Size-existing.FillVolume {
+		return true
+	}
+
+	// A buy limit sits below the market and a buy stop above it; sells are
+	// the other way around.
+	buy := existing.Side == models.OrderSideBid
+	toward := func(from, to *float64, up bool) bool {
+		switch {
+		case to == nil:
+			return false
+		case from == nil:
+			return true
+		case up:
+			return *to > *from
+		default:
+			return *to < *from
+		}
+	}
+	return toward(existing.LimitPrice, order.LimitPrice, buy) ||
+		toward(existing.StopPrice, order.StopPrice, !buy)
+}
+
+// Check runs the rules of the order's account and returns the first
+// rejection as a *RiskRejection.
+func (g *RiskGuard) Check(ctx context.Context, order *RiskOrder) error {
+	g.mu.RLock()
+	rules, ok := g.rules[order.AccountID]
+	if !ok {
+		rules = g.defaultRules
+	}
+	g.mu.RUnlock()
+	if len(rules) == 0 {
+		return nil
+	}
+
+	state, err := g.state(ctx, order)
+	if err != nil {
+		return err
+	}
+
+	for _, rule := range rules {
+		rejection := rule.Check(order, state)
+		if rejection == nil {
+			continue
+		}
+		if rejection.Rule == "" {
+			rejection.Rule = rule.Name()
+		}
+		rejection.AccountID = order.AccountID
+		rejection.ContractID = order.ContractID
+		rejection.OrderID = order.OrderID
+		rejection.Time = state.Now
+
+		for _, handler := range g.rejections.get(subscribeAll) {
+			handler(*rejection)
+		}
+		return rejection
+	}
+	return nil
+}
+
+func (g *RiskGuard) state(ctx context.Context, order *RiskOrder) (*RiskState, error) {
+	g.mu.RLock()
+	state := &RiskState{
+		Now:         g.now(),
+		RealizedPnL: g.realized[order.AccountID],
+	}
+	tracker := g.trackers[order.AccountID]
+	oco := g.oco
+	g.mu.RUnlock()
+
+	contract, err := g.contract(ctx, order.ContractID)
+	if err != nil {
+		return nil, err
+	}
+	state.Contract = contract
+
+	if g.marketData != nil {
+		if quote, ok := g.marketData.LatestQuote(order.ContractID); ok {
+			state.Quote = &quote
+		}
+	}
+
+	if g.positions != nil {
+		account := g.positions.Account(order.AccountID)
+		state.Positions = account.Positions
+		state.UnrealizedPnL = account.UnrealizedPnL
+	}
+
+	if oco != nil {
+		for _, group := range oco.Groups() {
+			if group.AccountID == order.AccountID {
+				state.OCOGroups = append(state.OCOGroups, group)
+			}
+		}
+	}
+
+	if tracker != nil {
+		state.OpenOrders = tracker.OpenOrders()
+	} else {
+		resp, err := g.orders.SearchOpenOrders(ctx, &models.SearchOpenOrderRequest{AccountID: order.AccountID})
+		if err != nil {
+			return nil, fmt.Errorf("failed to search open orders: %w", err)
+		}
+		if !resp.Success {
+			return nil, fmt.Errorf("failed to search open orders with error code: %v", resp.ErrorCode)
+		}
+		state.OpenOrders = resp.Orders
+	}
+
+	return state, nil
+}
+
+func (g *RiskGuard) contract(ctx context.Context, contractID string) (*models.ContractModel, error) {
+	g.mu.RLock()
+	contract, ok := g.contractInfo[contractID]
+	g.mu.RUnlock()
+	if ok {
+		return &contract, nil
+	}
+	if g.contracts == nil {
+		return nil, nil
+	}
+
+	resp, err := g.contracts.SearchContractByID(ctx, &models.SearchContractByIdRequest{ContractID: contractID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load contract %s: %w", contractID, err)
+	}
+	if !resp.Success || resp.Contract == nil {
+		return nil, fmt.Errorf("failed to load contract %s with error code: %v", contractID, resp.ErrorCode)
+	}
+
+	g.mu.Lock()
+	g.contractInfo[contractID] = *resp.Contract
+	g.mu.Unlock()
+	return resp.Contract, nil
+}
+
+func (g *RiskGuard) workingOrder(ctx context.Context, accountID, orderID int32) (models.OrderModel, error) {
+	g.mu.RLock()
+	tracker := g.trackers[accountID]
+	g.mu.RUnlock()
+
+	if tracker != nil {
+		if order, ok := tracker.Order(orderID); ok {
+			return order, nil
+		}
+	}
+
+	resp, err := g.orders.SearchOpenOrders(ctx, &models.SearchOpenOrderRequest{AccountID: accountID})
+	if err != nil {
+		return models.OrderModel{}, fmt.Errorf("failed to search open orders: %w", err)
+	}
+	if !resp.Success {
+		return models.OrderModel{}, fmt.Errorf("failed to search open orders with error code: %v", resp.ErrorCode)
+	}
+	for _, order := range resp.Orders {
+		if order.ID == orderID {
+			return order, nil
+		}
+	}
+	return models.OrderModel{}, fmt.Errorf("order %d: %w", orderID, ErrOrderNotFound)
+}
+
+func realizedPnL(trades []models.HalfTradeModel) float64 {
+	total := 0.0
+	for _, trade := range trades {
+		if trade.Voided {
+			continue
+		}
+		if trade.ProfitAndLoss != nil {
+			total += *trade.ProfitAndLoss
+		}
+		total -= trade.Fees
+	}
+	return total
+}
+
+func signedOrderSize(side models.OrderSide, size int32) int32 {
+	if side == models.OrderSideAsk {
+		return -size
+	}
+	return size
+}
+
+func signedPositionSize(position models.PositionModel) int32 {
+	size := abs32(position.Size)
+	if position.Type == models.PositionTypeShort {
+		return -size
+	}
+	return size
+}
+
+func sum32(values []int32) int32 {
+	total := int32(0)
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
+
+// unprotected sums the sizes that do not fit inside cover, the position on
+// the other side they could close.
+func unprotected(sizes []int32, cover int32) int32 {
+	total := int32(0)
+	for _, size := range sizes {
+		if cover <= 0 || size > cover {
+			total += size
+		}
+	}
+	return total
+}
+
+// worse returns whichever of two signed positions is further from flat.
+func worse(a, b int32) int32 {
+	if abs32(b) > abs32(a) {
+		return b
+	}
+	return a
+}
+
+func abs32(v int32) int32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
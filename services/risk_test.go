@@ -0,0 +1,223 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/tradingiq/projectx-client/models"
+)
+
+const riskTestContract = "CON.F.US.MES.Z25"
+
+func workingOrder(id int32, side models.OrderSide, orderType models.OrderType, size int32) models.OrderModel {
+	return models.OrderModel{
+		ID:         id,
+		AccountID:  1,
+		ContractID: riskTestContract,
+		Status:     models.OrderStatusOpen,
+		Type:       orderType,
+		Side:       side,
+		Size:       size,
+	}
+}
+
+func newRiskOrder(side models.OrderSide, orderType models.OrderType, size int32) *RiskOrder {
+	return &RiskOrder{AccountID: 1, ContractID: riskTestContract, Type: orderType, Side: side, Size: size}
+}
+
+func longPosition(size int32) []PositionPnL {
+	return []PositionPnL{{Position: models.PositionModel{
+		AccountID:  1,
+		ContractID: riskTestContract,
+		Type:       models.PositionTypeLong,
+		Size:       size,
+	}}}
+}
+
+// checkPositionRules runs both position rules with limit max and reports
+// whether the order was approved by each.
+func checkPositionRules(t *testing.T, max int32, order *RiskOrder, state *RiskState) (instrument, account bool) {
+	t.Helper()
+	return MaxContractsPerInstrument(max).Check(order, state) == nil,
+		MaxContractsPerAccount(max).Check(order, state) == nil
+}
+
+func TestPositionRulesApproveLimitEntryBracket(t *testing.T) {
+	entry := workingOrder(10, models.OrderSideBid, models.OrderTypeLimit, 1)
+	stopLoss := workingOrder(11, models.OrderSideAsk, models.OrderTypeStop, 1)
+
+	steps := []struct {
+		name  string
+		order *RiskOrder
+		open  []models.OrderModel
+	}{
+		{"entry", newRiskOrder(models.OrderSideBid, models.OrderTypeLimit, 1), nil},
+		{"stop loss", newRiskOrder(models.OrderSideAsk, models.OrderTypeStop, 1), []models.OrderModel{entry}},
+		{"take profit", newRiskOrder(models.OrderSideAsk, models.OrderTypeLimit, 1), []models.OrderModel{entry, stopLoss}},
+	}
+	for _, step := range steps {
+		state := &RiskState{OpenOrders: step.open}
+		instrument, account := checkPositionRules(t, 1, step.order, state)
+		if !instrument || !account {
+			t.Errorf("%s: approved per instrument %v, per account %v; want both approved (projected %d)",
+				step.name, instrument, account, state.Projected(step.order))
+		}
+	}
+}
+
+func TestPositionRulesApproveMarketEntryBracket(t *testing.T) {
+	stopLoss := workingOrder(11, models.OrderSideAsk, models.OrderTypeStop, 2)
+
+	state := &RiskState{Positions: longPosition(2)}
+	order := newRiskOrder(models.OrderSideAsk, models.OrderTypeStop, 2)
+	if instrument, account := checkPositionRules(t, 2, order, state); !instrument || !account {
+		t.Errorf("stop loss: approved per instrument %v, per account %v", instrument, account)
+	}
+
+	state.OpenOrders = []models.OrderModel{stopLoss}
+	order = newRiskOrder(models.OrderSideAsk, models.OrderTypeLimit, 2)
+	if instrument, account := checkPositionRules(t, 2, order, state); !instrument || !account {
+		t.Errorf("take profit: approved per instrument %v, per account %v (projected %d)",
+			instrument, account, state.Projected(order))
+	}
+}
+
+func TestPositionRulesApproveOCOPair(t *testing.T) {
+	// A breakout straddle: buy stop above, sell stop below, one fills.
+	buyStop := workingOrder(20, models.OrderSideBid, models.OrderTypeStop, 1)
+	state := &RiskState{OpenOrders: []models.OrderModel{buyStop}}
+	order := newRiskOrder(models.OrderSideAsk, models.OrderTypeStop, 1)
+	if instrument, account := checkPositionRules(t, 1, order, state); !instrument || !account {
+		t.Errorf("straddle: approved per instrument %v, per account %v (projected %d)",
+			instrument, account, state.Projected(order))
+	}
+
+	// Two entries on the same side grouped in an OCO manager count once.
+	buyLimit := workingOrder(21, models.OrderSideBid, models.OrderTypeLimit, 1)
+	state = &RiskState{
+		OpenOrders: []models.OrderModel{buyStop, buyLimit},
+		OCOGroups: []OCOGroup{{
+			ID:        "1-20",
+			AccountID: 1,
+			Members:   []OCOMember{{OrderID: 20, Size: 1}, {OrderID: 21, Size: 1}},
+		}},
+	}
+	price := 5000.0
+	modify := newRiskOrder(models.OrderSideBid, models.OrderTypeLimit, 1)
+	modify.Modify = true
+	modify.OrderID = 21
+	modify.LimitPrice = &price
+	if instrument, account := checkPositionRules(t, 1, modify, state); !instrument || !account {
+		t.Errorf("grouped same side: approved per instrument %v, per account %v (projected %d)",
+			instrument, account, state.Projected(modify))
+	}
+
+	state.OCOGroups = nil
+	if instrument, account := checkPositionRules(t, 1, modify, state); instrument || account {
+		t.Errorf("ungrouped same side: approved per instrument %v, per account %v; want both rejected", instrument, account)
+	}
+}
+
+func TestPositionRulesRejectNewExposure(t *testing.T) {
+	tests := []struct {
+		name      string
+		max       int32
+		order     *RiskOrder
+		positions []PositionPnL
+		open      []models.OrderModel
+		projected int32
+	}{
+		{
+			name:      "order larger than limit",
+			max:       1,
+			order:     newRiskOrder(models.OrderSideBid, models.OrderTypeMarket, 2),
+			projected: 2,
+		},
+		{
+			name:      "second entry on the same side",
+			max:       1,
+			order:     newRiskOrder(models.OrderSideBid, models.OrderTypeLimit, 1),
+			open:      []models.OrderModel{workingOrder(30, models.OrderSideBid, models.OrderTypeStop, 1)},
+			projected: 2,
+		},
+		{
+			name:      "adding to a position",
+			max:       2,
+			order:     newRiskOrder(models.OrderSideBid, models.OrderTypeMarket, 1),
+			positions: longPosition(2),
+			projected: 3,
+		},
+		{
+			name:      "reversal larger than the position",
+			max:       2,
+			order:     newRiskOrder(models.OrderSideAsk, models.OrderTypeMarket, 5),
+			positions: longPosition(1),
+			projected: -4,
+		},
+		{
+			name:  "short entry with exits larger than limit",
+			max:   2,
+			order: newRiskOrder(models.OrderSideAsk, models.OrderTypeLimit, 3),
+			open: []models.OrderModel{
+				workingOrder(31, models.OrderSideBid, models.OrderTypeStop, 3),
+				workingOrder(32, models.OrderSideBid, models.OrderTypeLimit, 3),
+			},
+			projected: -3,
+		},
+	}
+	for _, test := range tests {
+		state := &RiskState{Positions: test.positions, OpenOrders: test.open}
+		if projected := state.Projected(test.order); projected != test.projected {
+			t.Errorf("%s: projected %d, want %d", test.name, projected, test.projected)
+		}
+		if instrument, account := checkPositionRules(t, test.max, test.order, state); instrument || account {
+			t.Errorf("%s: approved per instrument %v, per account %v; want both rejected", test.name, instrument, account)
+		}
+	}
+}
+
+func TestCheckModifyAllowsRiskReduction(t *testing.T) {
+	stopPrice, limitPrice := 4990.0, 4995.0
+	stopLoss := workingOrder(40, models.OrderSideAsk, models.OrderTypeStop, 3)
+	stopLoss.StopPrice = &stopPrice
+	entry := workingOrder(41, models.OrderSideBid, models.OrderTypeLimit, 2)
+	entry.LimitPrice = &limitPrice
+
+	tracker := NewOrderTracker(nil, nil, 1)
+	tracker.apply(stopLoss)
+	tracker.apply(entry)
+
+	// Past the loss limit and without quotes every rule that runs rejects.
+	guard := NewRiskGuard(nil, nil, nil, nil, nil)
+	guard.SetDefaultRules(DailyLossLimit(500), PriceCollar(4))
+	guard.SetRealizedPnL(1, -1000)
+	guard.TrackOrders(1, tracker)
+
+	size := func(v int32) *int32 { return &v }
+	price := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name    string
+		req     models.ModifyOrderRequest
+		allowed bool
+	}{
+		{"reduce oco sibling", models.ModifyOrderRequest{OrderID: 40, Size: size(1)}, true},
+		{"same size", models.ModifyOrderRequest{OrderID: 40, Size: size(3)}, true},
+		{"widen stop loss", models.ModifyOrderRequest{OrderID: 40, StopPrice: price(4985)}, true},
+		{"lower buy limit", models.ModifyOrderRequest{OrderID: 41, LimitPrice: price(4990)}, true},
+		{"increase size", models.ModifyOrderRequest{OrderID: 40, Size: size(4)}, false},
+		{"tighten stop loss", models.ModifyOrderRequest{OrderID: 40, StopPrice: price(4994)}, false},
+		{"raise buy limit", models.ModifyOrderRequest{OrderID: 41, LimitPrice: price(5000)}, false},
+		{"reduce and raise buy limit", models.ModifyOrderRequest{OrderID: 41, Size: size(1), LimitPrice: price(5000)}, false},
+	}
+	for _, test := range tests {
+		test.req.AccountID = 1
+		err := guard.CheckModify(context.Background(), &test.req)
+		if allowed := err == nil; allowed != test.allowed {
+			t.Errorf("%s: allowed %v, want %v: %v", test.name, allowed, test.allowed, err)
+		}
+		if err != nil && !errors.Is(err, ErrRiskRejected) {
+			t.Errorf("%s: %v is not a risk rejection", test.name, err)
+		}
+	}
+}
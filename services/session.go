@@ -0,0 +1,295 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tradingiq/projectx-client/client"
+	"github.com/tradingiq/projectx-client/models"
+)
+
+const (
+	DefaultRefreshMargin   = 30 * time.Minute
+	DefaultRefreshInterval = 6 * time.Hour
+	DefaultRefreshRetry    = 30 * time.Second
+)
+
+var ErrNoLoginMethod = errors.New("session has no login method, call LoginKey or LoginApp first")
+
+type SessionManager struct {
+	client *client.Client
+	auth   *AuthService
+
+	mu              sync.Mutex
+	login           func(ctx context.Context) (*models.LoginResponse, error)
+	expiry          time.Time
+	lastRefresh     time.Time
+	refreshMargin   time.Duration
+	refreshInterval time.Duration
+	errorHandler    func(error)
+	cancel          context.CancelFunc
+
+	refreshMu sync.Mutex
+}
+
+func NewSessionManager(c *client.Client, auth *AuthService) *SessionManager {
+	return &SessionManager{
+		client:          c,
+		auth:            auth,
+		refreshMargin:   DefaultRefreshMargin,
+		refreshInterval: DefaultRefreshInterval,
+	}
+}
+
+func (m *SessionManager) LoginKey(ctx context.Context, req *models.LoginApiKeyRequest) (*models.LoginResponse, error) {
+	loginReq := *req
+	return m.start(ctx, func(ctx context.Context) (*models.LoginResponse, error) {
+		return m.auth.LoginKey(ctx, &loginReq)
+	})
+}
+
+func (m *SessionManager) LoginApp(ctx context.Context, req *models.LoginAppRequest) (*models.LoginResponse, error) {
+	loginReq := *req
+	return m.start(ctx, func(ctx context.Context) (*models.LoginResponse, error) {
+		return m.auth.LoginApp(ctx, &loginReq)
+	})
+}
+
+func (m *SessionManager) start(ctx context.Context, login func(ctx context.Context) (*models.LoginResponse, error)) (*models.LoginResponse, error) {
+	resp, err := login(ctx)
+	if err != nil {
+		return resp, err
+	}
+	if !resp.Success {
+		return resp, loginFailure(resp)
+	}
+
+	m.mu.Lock()
+	m.login = login
+	m.lastRefresh = time.Now()
+	m.updateExpiryLocked()
+	m.mu.Unlock()
+
+	m.client.SetUnauthorizedHandler(m.handleUnauthorized)
+
+	return resp, nil
+}
+
+func (m *SessionManager) SetRefreshMargin(margin time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.refreshMargin = margin
+}
+
+// SetRefreshInterval controls how often the session is revalidated when the
+// token expiry cannot be decoded.
+func (m *SessionManager) SetRefreshInterval(interval time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.refreshInterval = interval
+}
+
+func (m *SessionManager) SetErrorHandler(handler func(error)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.errorHandler = handler
+}
+
+func (m *SessionManager) Expiry() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.expiry
+}
+
+// Refresh revalidates the current token and falls back to a fresh login when
+// the session has expired or is no longer known to the server.
+func (m *SessionManager) Refresh(ctx context.Context) error {
+	m.refreshMu.Lock()
+	defer m.refreshMu.Unlock()
+
+	return m.refresh(ctx)
+}
+
+func (m *SessionManager) refresh(ctx context.Context) error {
+	m.mu.Lock()
+	login := m.login
+	m.mu.Unlock()
+
+	if login == nil {
+		return ErrNoLoginMethod
+	}
+
+	if m.client.GetToken() != "" {
+		resp, err := m.auth.Validate(ctx)
+		if err == nil && resp.Success {
+			m.mu.Lock()
+			m.lastRefresh = time.Now()
+			m.updateExpiryLocked()
+			// Validate may keep the old token; once it is inside the margin
+			// only a fresh login moves the expiry.
+			renewed := m.expiry.IsZero() || time.Until(m.expiry) > m.refreshMargin
+			m.mu.Unlock()
+			if renewed {
+				return nil
+			}
+		}
+		if err != nil && resp == nil && !isUnauthorized(err) {
+			return err
+		}
+	}
+
+	resp, err := login(ctx)
+	if err != nil {
+		return fmt.Errorf("session re-login failed: %w", err)
+	}
+	if !resp.Success {
+		return fmt.Errorf("session re-login failed: %w", loginFailure(resp))
+	}
+
+	m.mu.Lock()
+	m.lastRefresh = time.Now()
+	m.updateExpiryLocked()
+	m.mu.Unlock()
+
+	return nil
+}
+
+func (m *SessionManager) handleUnauthorized(ctx context.Context) error {
+	m.refreshMu.Lock()
+	defer m.refreshMu.Unlock()
+
+	// Another request already refreshed the session while this one waited.
+	m.mu.Lock()
+	recent := time.Since(m.lastRefresh) < time.Second
+	m.mu.Unlock()
+	if recent {
+		return nil
+	}
+
+	return m.refresh(ctx)
+}
+
+// Start revalidates the session in the background shortly before the token
+// expires until ctx is cancelled or Stop is called.
+func (m *SessionManager) Start(ctx context.Context) {
+	m.mu.Lock()
+	if m.cancel != nil {
+		m.cancel()
+	}
+	ctx, m.cancel = context.WithCancel(ctx)
+	m.mu.Unlock()
+
+	go m.run(ctx)
+}
+
+func (m *SessionManager) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.cancel != nil {
+		m.cancel()
+		m.cancel = nil
+	}
+}
+
+func (m *SessionManager) run(ctx context.Context) {
+	delay := m.nextRefresh()
+
+	for {
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if err := m.Refresh(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			m.mu.Lock()
+			handler := m.errorHandler
+			m.mu.Unlock()
+			if handler != nil {
+				handler(err)
+			}
+
+			delay = DefaultRefreshRetry
+			continue
+		}
+
+		delay = m.nextRefresh()
+	}
+}
+
+func (m *SessionManager) nextRefresh() time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.expiry.IsZero() {
+		return m.refreshInterval
+	}
+
+	// A token that stays inside the margin after a refresh must not turn the
+	// loop into back-to-back requests.
+	delay := time.Until(m.expiry) - m.refreshMargin
+	if delay < DefaultRefreshRetry {
+		return DefaultRefreshRetry
+	}
+	return delay
+}
+
+func (m *SessionManager) updateExpiryLocked() {
+	expiry, err := TokenExpiry(m.client.GetToken())
+	if err != nil {
+		m.expiry = time.Time{}
+		return
+	}
+	m.expiry = expiry
+}
+
+// TokenExpiry decodes the exp claim of a JWT without verifying its signature.
+func TokenExpiry(token string) (time.Time, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, fmt.Errorf("token is not a JWT")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to decode token payload: %w", err)
+	}
+
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse token claims: %w", err)
+	}
+	if claims.Exp == 0 {
+		return time.Time{}, fmt.Errorf("token has no exp claim")
+	}
+
+	return time.Unix(claims.Exp, 0), nil
+}
+
+func isUnauthorized(err error) bool {
+	var httpErr *client.HTTPError
+	return errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusUnauthorized
+}
+
+func loginFailure(resp *models.LoginResponse) error {
+	if resp.ErrorMessage != nil {
+		return fmt.Errorf("login failed: %s", *resp.ErrorMessage)
+	}
+	return fmt.Errorf("login failed with error code: %v", resp.ErrorCode)
+}
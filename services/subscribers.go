@@ -0,0 +1,61 @@
+package services
+
+import "sync"
+
+const subscribeAll = "*"
+
+// AllContracts subscribes a handler to events for every contract.
+const AllContracts = subscribeAll
+
+type subscribers[F any] struct {
+	mu     sync.RWMutex
+	nextID uint64
+	byKey  map[string]map[uint64]F
+}
+
+func newSubscribers[F any]() *subscribers[F] {
+	return &subscribers[F]{
+		byKey: make(map[string]map[uint64]F),
+	}
+}
+
+func (s *subscribers[F]) add(key string, fn F) func() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := s.nextID
+	s.nextID++
+	if s.byKey[key] == nil {
+		s.byKey[key] = make(map[uint64]F)
+	}
+	s.byKey[key][id] = fn
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			delete(s.byKey[key], id)
+			if len(s.byKey[key]) == 0 {
+				delete(s.byKey, key)
+			}
+		})
+	}
+}
+
+// get returns the handlers registered for key followed by the wildcard ones.
+func (s *subscribers[F]) get(key string) []F {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]F, 0, len(s.byKey[key])+len(s.byKey[subscribeAll]))
+	for _, fn := range s.byKey[key] {
+		result = append(result, fn)
+	}
+	if key != subscribeAll {
+		for _, fn := range s.byKey[subscribeAll] {
+			result = append(result, fn)
+		}
+	}
+	return result
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/tradingiq/projectx-client/client"
+)
+
+// testAPI serves canned JSON responses by request path and records the
+// request bodies it received.
+type testAPI struct {
+	t        *testing.T
+	mu       sync.Mutex
+	handlers map[string]func(body []byte) interface{}
+	requests []testRequest
+}
+
+type testRequest struct {
+	Path string
+	Body []byte
+}
+
+func newTestAPI(t *testing.T) (*testAPI, *client.Client) {
+	t.Helper()
+	api := &testAPI{t: t, handlers: make(map[string]func([]byte) interface{})}
+	server := httptest.NewServer(http.HandlerFunc(api.serve))
+	t.Cleanup(server.Close)
+	return api, client.NewClient(client.WithBaseURL(server.URL))
+}
+
+func (a *testAPI) handle(path string, handler func(body []byte) interface{}) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.handlers[path] = handler
+}
+
+func (a *testAPI) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	a.mu.Lock()
+	a.requests = append(a.requests, testRequest{Path: r.URL.Path, Body: body})
+	handler, ok := a.handlers[r.URL.Path]
+	a.mu.Unlock()
+
+	if !ok {
+		a.t.Errorf("unexpected request to %s", r.URL.Path)
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(handler(body))
+}
+
+// bodies returns the request bodies sent to path, oldest first.
+func (a *testAPI) bodies(path string) [][]byte {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var bodies [][]byte
+	for _, req := range a.requests {
+		if req.Path == path {
+			bodies = append(bodies, req.Body)
+		}
+	}
+	return bodies
+}
@@ -18,7 +18,7 @@ func NewTradeService(c *client.Client) *TradeService {
 
 func (s *TradeService) SearchHalfTurnTrades(ctx context.Context, req *models.SearchTradeRequest) (*models.SearchHalfTradeResponse, error) {
 	var resp models.SearchHalfTradeResponse
-	err := s.client.DoJSON(ctx, &client.Request{
+	raw, err := s.client.DoJSONResponse(ctx, &client.Request{
 		Method: http.MethodPost,
 		Path:   "/api/Trade/search",
 		Body:   req,
@@ -27,5 +27,9 @@ func (s *TradeService) SearchHalfTurnTrades(ctx context.Context, req *models.Sea
 		return nil, err
 	}
 
+	if err := checkResponse(s.client, raw, resp.Success, int(resp.ErrorCode), resp.ErrorMessage, searchTradeError(resp.ErrorCode)); err != nil {
+		return &resp, err
+	}
+
 	return &resp, nil
 }
@@ -3,8 +3,9 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
+	"sort"
 	"sync"
 	"time"
 
@@ -17,36 +18,48 @@ const (
 	UserHubURL = "https://rtc.projectx.com/hubs/user?access_token=%s"
 )
 
+var ErrNoSubscriptions = errors.New("no order, position or trade subscriptions")
+
 type UserDataWebSocketService struct {
 	client            *client.Client
 	conn              signalr.Client
 	receiver          *UserDataReceiver
 	mu                sync.Mutex
 	state             ConnectionState
-	accountID         int
-	subscriptions     map[string]bool
+	accountUpdates    bool
+	subscriptions     map[int]map[string]bool
 	ctx               context.Context
 	cancel            context.CancelFunc
 	reconnectChan     chan struct{}
 	connectionHandler func(ConnectionState)
+	stateSubscribers  *subscribers[func(ConnectionState)]
 	maxReconnectDelay time.Duration
 	reconnectAttempts int
 }
 
 type UserDataReceiver struct {
-	handlers        map[string]func(interface{})
-	accountHandler  func(*models.AccountUpdateData)
-	orderHandler    func(*models.OrderUpdateData)
-	positionHandler func(*models.PositionUpdateData)
-	tradeHandler    func(*models.TradeUpdateData)
-	mu              sync.RWMutex
-	service         *UserDataWebSocketService
+	handlers            map[string]func(interface{})
+	accountHandler      func(*models.AccountUpdateData)
+	orderHandler        func(*models.OrderUpdateData)
+	positionHandler     func(*models.PositionUpdateData)
+	tradeHandler        func(*models.TradeUpdateData)
+	recorder            HubRecorder
+	accountSubscribers  *subscribers[func(*models.AccountUpdateData)]
+	orderSubscribers    *subscribers[func(*models.OrderUpdateData)]
+	positionSubscribers *subscribers[func(*models.PositionUpdateData)]
+	tradeSubscribers    *subscribers[func(*models.TradeUpdateData)]
+	mu                  sync.RWMutex
+	service             *UserDataWebSocketService
 }
 
 func NewUserDataReceiver(service *UserDataWebSocketService) *UserDataReceiver {
 	return &UserDataReceiver{
-		handlers: make(map[string]func(interface{})),
-		service:  service,
+		handlers:            make(map[string]func(interface{})),
+		accountSubscribers:  newSubscribers[func(*models.AccountUpdateData)](),
+		orderSubscribers:    newSubscribers[func(*models.OrderUpdateData)](),
+		positionSubscribers: newSubscribers[func(*models.PositionUpdateData)](),
+		tradeSubscribers:    newSubscribers[func(*models.TradeUpdateData)](),
+		service:             service,
 	}
 }
 
@@ -62,18 +75,63 @@ func (r *UserDataReceiver) RemoveHandler(event string) {
 	delete(r.handlers, event)
 }
 
+// SetRecorder passes every hub message to recorder before it is decoded.
+// Pass nil to stop recording.
+func (r *UserDataReceiver) SetRecorder(recorder HubRecorder) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.recorder = recorder
+}
+
+// record passes data to the recorder, if any. It must be called without r.mu
+// held, as RecordHubMessage may block while the recorder catches up.
+func (r *UserDataReceiver) record(method string, data interface{}) {
+	r.mu.RLock()
+	recorder := r.recorder
+	r.mu.RUnlock()
+	if recorder == nil {
+		return
+	}
+	received := time.Now()
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		reportRecorderError(recorder, fmt.Errorf("failed to encode %s message: %w", method, err))
+		return
+	}
+
+	// Account updates carry the account as id, the others as accountId.
+	var envelope struct {
+		Data struct {
+			ID        float64 `json:"id"`
+			AccountID float64 `json:"accountId"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		reportRecorderError(recorder, fmt.Errorf("failed to read account of %s message: %w", method, err))
+	}
+	accountID := int32(envelope.Data.AccountID)
+	if method == "GatewayUserAccount" {
+		accountID = int32(envelope.Data.ID)
+	}
+
+	recorder.RecordHubMessage(HubMessage{
+		Hub:       HubUser,
+		Method:    method,
+		AccountID: accountID,
+		Payload:   payload,
+		Received:  received,
+	})
+}
+
 func (r *UserDataReceiver) GatewayUserAccount(data interface{}) {
+	r.record("GatewayUserAccount", data)
+
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	if r.accountHandler != nil {
-		var accountData models.AccountUpdateData
-		if jsonBytes, err := json.Marshal(data); err == nil {
-			if err := json.Unmarshal(jsonBytes, &accountData); err == nil {
-				r.accountHandler(&accountData)
-				return
-			}
-		}
+	if dispatchUserEvent(data, r.accountHandler, r.accountSubscribers.get(subscribeAll)) {
+		return
 	}
 
 	if handler, ok := r.handlers["account"]; ok {
@@ -82,18 +140,13 @@ func (r *UserDataReceiver) GatewayUserAccount(data interface{}) {
 }
 
 func (r *UserDataReceiver) GatewayUserOrder(data interface{}) {
+	r.record("GatewayUserOrder", data)
+
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	if r.orderHandler != nil {
-
-		var orderData models.OrderUpdateData
-		if jsonBytes, err := json.Marshal(data); err == nil {
-			if err := json.Unmarshal(jsonBytes, &orderData); err == nil {
-				r.orderHandler(&orderData)
-				return
-			}
-		}
+	if dispatchUserEvent(data, r.orderHandler, r.orderSubscribers.get(subscribeAll)) {
+		return
 	}
 
 	if handler, ok := r.handlers["order"]; ok {
@@ -102,17 +155,13 @@ func (r *UserDataReceiver) GatewayUserOrder(data interface{}) {
 }
 
 func (r *UserDataReceiver) GatewayUserPosition(data interface{}) {
+	r.record("GatewayUserPosition", data)
+
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	if r.positionHandler != nil {
-		var positionData models.PositionUpdateData
-		if jsonBytes, err := json.Marshal(data); err == nil {
-			if err := json.Unmarshal(jsonBytes, &positionData); err == nil {
-				r.positionHandler(&positionData)
-				return
-			}
-		}
+	if dispatchUserEvent(data, r.positionHandler, r.positionSubscribers.get(subscribeAll)) {
+		return
 	}
 
 	if handler, ok := r.handlers["position"]; ok {
@@ -121,17 +170,13 @@ func (r *UserDataReceiver) GatewayUserPosition(data interface{}) {
 }
 
 func (r *UserDataReceiver) GatewayUserTrade(data interface{}) {
+	r.record("GatewayUserTrade", data)
+
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	if r.tradeHandler != nil {
-		var tradeData models.TradeUpdateData
-		if jsonBytes, err := json.Marshal(data); err == nil {
-			if err := json.Unmarshal(jsonBytes, &tradeData); err == nil {
-				r.tradeHandler(&tradeData)
-				return
-			}
-		}
+	if dispatchUserEvent(data, r.tradeHandler, r.tradeSubscribers.get(subscribeAll)) {
+		return
 	}
 
 	if handler, ok := r.handlers["trade"]; ok {
@@ -139,6 +184,33 @@ func (r *UserDataReceiver) GatewayUserTrade(data interface{}) {
 	}
 }
 
+// dispatchUserEvent decodes data for the typed handler and the subscribers and
+// reports whether a typed handler consumed the event.
+func dispatchUserEvent[T any](data interface{}, handler func(*T), subscribers []func(*T)) bool {
+	if handler == nil && len(subscribers) == 0 {
+		return false
+	}
+
+	jsonBytes, err := json.Marshal(data)
+	if err != nil {
+		return false
+	}
+
+	var event T
+	if err := json.Unmarshal(jsonBytes, &event); err != nil {
+		return false
+	}
+
+	if handler != nil {
+		handler(&event)
+	}
+	for _, subscriber := range subscribers {
+		subscriber(&event)
+	}
+
+	return handler != nil
+}
+
 func (r *UserDataReceiver) ConnectionClosed() {
 	if r.service != nil {
 		r.service.mu.Lock()
@@ -159,7 +231,8 @@ func (r *UserDataReceiver) ConnectionClosed() {
 func NewUserDataWebSocketService(c *client.Client) *UserDataWebSocketService {
 	s := &UserDataWebSocketService{
 		client:            c,
-		subscriptions:     make(map[string]bool),
+		subscriptions:     make(map[int]map[string]bool),
+		stateSubscribers:  newSubscribers[func(ConnectionState)](),
 		state:             StateDisconnected,
 		maxReconnectDelay: 30 * time.Second,
 		reconnectChan:     make(chan struct{}, 1),
@@ -179,20 +252,18 @@ func (s *UserDataWebSocketService) Connect(ctx context.Context) error {
 	s.ctx, s.cancel = context.WithCancel(ctx)
 	s.setState(StateConnecting)
 
-	token := s.client.GetToken()
+	token, err := s.client.Token(s.ctx)
+	if err != nil {
+		s.setState(StateDisconnected)
+		return fmt.Errorf("failed to get token: %w", err)
+	}
 	if token == "" {
 		s.setState(StateDisconnected)
 		return fmt.Errorf("authentication token not set")
 	}
 
 	conn, err := signalr.NewClient(s.ctx,
-		signalr.WithHttpConnection(s.ctx, fmt.Sprintf(UserHubURL, token),
-			signalr.WithHTTPHeaders(func() http.Header {
-				headers := http.Header{}
-				headers.Set("Authorization", "Bearer "+token)
-				return headers
-			}),
-		),
+		signalr.WithConnector(hubConnector(s.ctx, s.client, UserHubURL)),
 		signalr.WithReceiver(s.receiver),
 		signalr.Logger(newNoopLogger(), false),
 	)
@@ -230,8 +301,8 @@ func (s *UserDataWebSocketService) Disconnect() error {
 	}
 
 	s.setState(StateDisconnected)
-	s.subscriptions = make(map[string]bool)
-	s.accountID = 0
+	s.subscriptions = make(map[int]map[string]bool)
+	s.accountUpdates = false
 
 	return nil
 }
@@ -254,18 +325,65 @@ func (s *UserDataWebSocketService) SetConnectionHandler(handler func(ConnectionS
 	s.connectionHandler = handler
 }
 
+func (s *UserDataWebSocketService) OnConnectionState(handler func(ConnectionState)) func() {
+	return s.stateSubscribers.add(subscribeAll, handler)
+}
+
 func (s *UserDataWebSocketService) setState(state ConnectionState) {
 	s.state = state
 	if s.connectionHandler != nil {
 
 		go s.connectionHandler(state)
 	}
+	for _, subscriber := range s.stateSubscribers.get(subscribeAll) {
+		go subscriber(state)
+	}
 }
 
-func (s *UserDataWebSocketService) SetAccountID(accountID int) {
+func (s *UserDataWebSocketService) addSubscription(accountID int, kind string) {
+	if s.subscriptions[accountID] == nil {
+		s.subscriptions[accountID] = make(map[string]bool)
+	}
+	s.subscriptions[accountID][kind] = true
+}
+
+func (s *UserDataWebSocketService) removeSubscription(accountID int, kind string) {
+	if s.subscriptions[accountID] != nil {
+		delete(s.subscriptions[accountID], kind)
+		if len(s.subscriptions[accountID]) == 0 {
+			delete(s.subscriptions, accountID)
+		}
+	}
+}
+
+// SetAccountID moves every tracked order, position and trade subscription
+// onto accountID, so that all three kinds are restored for that one account
+// after a reconnect, as when only a single account could be tracked.
+// Subscriptions of other accounts are dropped from the restore list; the
+// server side subscriptions are left as they are. It returns
+// ErrNoSubscriptions when nothing is tracked yet, since there is nothing to
+// move.
+//
+// Deprecated: subscriptions are tracked per account. Pass the account ID to
+// SubscribeOrders, SubscribePositions, SubscribeTrades or SubscribeAll.
+func (s *UserDataWebSocketService) SetAccountID(accountID int) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.accountID = accountID
+
+	kinds := make(map[string]bool)
+	for _, tracked := range s.subscriptions {
+		for kind, subscribed := range tracked {
+			if subscribed {
+				kinds[kind] = true
+			}
+		}
+	}
+	if len(kinds) == 0 {
+		return ErrNoSubscriptions
+	}
+	clear(s.subscriptions)
+	s.subscriptions[accountID] = kinds
+	return nil
 }
 
 func (s *UserDataWebSocketService) SubscribeAccounts() error {
@@ -281,7 +399,7 @@ func (s *UserDataWebSocketService) SubscribeAccounts() error {
 		return fmt.Errorf("failed to subscribe to accounts: %w", result.Error)
 	}
 
-	s.subscriptions["accounts"] = true
+	s.accountUpdates = true
 	return nil
 }
 
@@ -298,7 +416,7 @@ func (s *UserDataWebSocketService) UnsubscribeAccounts() error {
 		return fmt.Errorf("failed to unsubscribe from accounts: %w", result.Error)
 	}
 
-	delete(s.subscriptions, "accounts")
+	s.accountUpdates = false
 	return nil
 }
 
@@ -315,8 +433,7 @@ func (s *UserDataWebSocketService) SubscribeOrders(accountID int) error {
 		return fmt.Errorf("failed to subscribe to orders: %w", result.Error)
 	}
 
-	s.subscriptions["orders"] = true
-	s.accountID = accountID
+	s.addSubscription(accountID, "orders")
 	return nil
 }
 
@@ -333,7 +450,7 @@ func (s *UserDataWebSocketService) UnsubscribeOrders(accountID int) error {
 		return fmt.Errorf("failed to unsubscribe from orders: %w", result.Error)
 	}
 
-	delete(s.subscriptions, "orders")
+	s.removeSubscription(accountID, "orders")
 	return nil
 }
 
@@ -350,8 +467,7 @@ func (s *UserDataWebSocketService) SubscribePositions(accountID int) error {
 		return fmt.Errorf("failed to subscribe to positions: %w", result.Error)
 	}
 
-	s.subscriptions["positions"] = true
-	s.accountID = accountID
+	s.addSubscription(accountID, "positions")
 	return nil
 }
 
@@ -368,7 +484,7 @@ func (s *UserDataWebSocketService) UnsubscribePositions(accountID int) error {
 		return fmt.Errorf("failed to unsubscribe from positions: %w", result.Error)
 	}
 
-	delete(s.subscriptions, "positions")
+	s.removeSubscription(accountID, "positions")
 	return nil
 }
 
@@ -385,8 +501,7 @@ func (s *UserDataWebSocketService) SubscribeTrades(accountID int) error {
 		return fmt.Errorf("failed to subscribe to trades: %w", result.Error)
 	}
 
-	s.subscriptions["trades"] = true
-	s.accountID = accountID
+	s.addSubscription(accountID, "trades")
 	return nil
 }
 
@@ -403,7 +518,7 @@ func (s *UserDataWebSocketService) UnsubscribeTrades(accountID int) error {
 		return fmt.Errorf("failed to unsubscribe from trades: %w", result.Error)
 	}
 
-	delete(s.subscriptions, "trades")
+	s.removeSubscription(accountID, "trades")
 	return nil
 }
 
@@ -423,26 +538,70 @@ func (s *UserDataWebSocketService) SubscribeAll(accountID int) error {
 	return nil
 }
 
+// UnsubscribeAccount removes every order, position and trade subscription
+// for accountID.
+func (s *UserDataWebSocketService) UnsubscribeAccount(accountID int) error {
+	s.mu.Lock()
+	kinds := make(map[string]bool, len(s.subscriptions[accountID]))
+	for kind, subscribed := range s.subscriptions[accountID] {
+		kinds[kind] = subscribed
+	}
+	s.mu.Unlock()
+
+	var errs []error
+	if kinds["orders"] {
+		errs = append(errs, s.UnsubscribeOrders(accountID))
+	}
+	if kinds["positions"] {
+		errs = append(errs, s.UnsubscribePositions(accountID))
+	}
+	if kinds["trades"] {
+		errs = append(errs, s.UnsubscribeTrades(accountID))
+	}
+	return errors.Join(errs...)
+}
+
+// UnsubscribeAll removes the account subscription and every per-account
+// subscription, continuing past failures and returning them joined.
 func (s *UserDataWebSocketService) UnsubscribeAll() error {
 	s.mu.Lock()
-	accountID := s.accountID
+	accountUpdates := s.accountUpdates
+	accountIDs := make([]int, 0, len(s.subscriptions))
+	for accountID := range s.subscriptions {
+		accountIDs = append(accountIDs, accountID)
+	}
 	s.mu.Unlock()
 
-	if err := s.UnsubscribeAccounts(); err != nil {
-		return err
+	sort.Ints(accountIDs)
+
+	var errs []error
+	if accountUpdates {
+		errs = append(errs, s.UnsubscribeAccounts())
 	}
-	if accountID > 0 {
-		if err := s.UnsubscribeOrders(accountID); err != nil {
-			return err
-		}
-		if err := s.UnsubscribePositions(accountID); err != nil {
-			return err
-		}
-		if err := s.UnsubscribeTrades(accountID); err != nil {
-			return err
+	for _, accountID := range accountIDs {
+		errs = append(errs, s.UnsubscribeAccount(accountID))
+	}
+	return errors.Join(errs...)
+}
+
+func (s *UserDataWebSocketService) GetSubscriptions() map[int]map[string]bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make(map[int]map[string]bool)
+	for accountID, kinds := range s.subscriptions {
+		result[accountID] = make(map[string]bool)
+		for kind, subscribed := range kinds {
+			result[accountID][kind] = subscribed
 		}
 	}
-	return nil
+	return result
+}
+
+func (s *UserDataWebSocketService) IsSubscribedToAccounts() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.accountUpdates
 }
 
 func (s *UserDataWebSocketService) SetAccountHandler(handler func(*models.AccountUpdateData)) {
@@ -469,6 +628,30 @@ func (s *UserDataWebSocketService) SetTradeHandler(handler func(*models.TradeUpd
 	s.receiver.tradeHandler = handler
 }
 
+// OnAccount registers an additional account update handler alongside
+// SetAccountHandler and returns a function that removes it.
+func (s *UserDataWebSocketService) OnAccount(handler func(*models.AccountUpdateData)) func() {
+	return s.receiver.accountSubscribers.add(subscribeAll, handler)
+}
+
+func (s *UserDataWebSocketService) OnOrder(handler func(*models.OrderUpdateData)) func() {
+	return s.receiver.orderSubscribers.add(subscribeAll, handler)
+}
+
+func (s *UserDataWebSocketService) OnPosition(handler func(*models.PositionUpdateData)) func() {
+	return s.receiver.positionSubscribers.add(subscribeAll, handler)
+}
+
+func (s *UserDataWebSocketService) OnTrade(handler func(*models.TradeUpdateData)) func() {
+	return s.receiver.tradeSubscribers.add(subscribeAll, handler)
+}
+
+// SetRecorder passes every raw hub message to recorder, alongside the
+// handlers.
+func (s *UserDataWebSocketService) SetRecorder(recorder HubRecorder) {
+	s.receiver.SetRecorder(recorder)
+}
+
 func (s *UserDataWebSocketService) handleReconnection() {
 
 	for {
@@ -553,19 +736,13 @@ func (s *UserDataWebSocketService) reconnect() {
 		}
 		s.mu.Unlock()
 
-		token := s.client.GetToken()
-		if token == "" {
+		token, err := s.client.Token(s.ctx)
+		if err != nil || token == "" {
 			continue
 		}
 
 		conn, err := signalr.NewClient(s.ctx,
-			signalr.WithHttpConnection(s.ctx, fmt.Sprintf(UserHubURL, token),
-				signalr.WithHTTPHeaders(func() http.Header {
-					headers := http.Header{}
-					headers.Set("Authorization", "Bearer "+token)
-					return headers
-				}),
-			),
+			signalr.WithConnector(hubConnector(s.ctx, s.client, UserHubURL)),
 			signalr.WithReceiver(s.receiver),
 			signalr.Logger(newNoopLogger(), false),
 		)
@@ -608,23 +785,28 @@ func (s *UserDataWebSocketService) reconnect() {
 
 func (s *UserDataWebSocketService) resubscribe() {
 	s.mu.Lock()
-	subs := make(map[string]bool)
-	for k, v := range s.subscriptions {
-		subs[k] = v
+	accountUpdates := s.accountUpdates
+	subs := make(map[int]map[string]bool)
+	for accountID, kinds := range s.subscriptions {
+		subs[accountID] = make(map[string]bool)
+		for kind, subscribed := range kinds {
+			subs[accountID][kind] = subscribed
+		}
 	}
-	accountID := s.accountID
 	s.mu.Unlock()
 
-	if subs["accounts"] {
+	if accountUpdates {
 		<-s.conn.Send("SubscribeAccounts")
 	}
-	if subs["orders"] && accountID > 0 {
-		<-s.conn.Send("SubscribeOrders", accountID)
-	}
-	if subs["positions"] && accountID > 0 {
-		<-s.conn.Send("SubscribePositions", accountID)
-	}
-	if subs["trades"] && accountID > 0 {
-		<-s.conn.Send("SubscribeTrades", accountID)
+	for accountID, kinds := range subs {
+		if kinds["orders"] {
+			<-s.conn.Send("SubscribeOrders", accountID)
+		}
+		if kinds["positions"] {
+			<-s.conn.Send("SubscribePositions", accountID)
+		}
+		if kinds["trades"] {
+			<-s.conn.Send("SubscribeTrades", accountID)
+		}
 	}
 }
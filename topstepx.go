@@ -13,6 +13,7 @@ type Client struct {
 	client *client.Client
 
 	Auth       *services.AuthService
+	Session    *services.SessionManager
 	Account    *services.AccountService
 	Contract   *services.ContractService
 	Order      *services.OrderService
@@ -26,10 +27,12 @@ type Client struct {
 
 func NewClient(httpOpts ...client.Option) *Client {
 	c := client.NewClient(httpOpts...)
+	auth := services.NewAuthService(c)
 
 	return &Client{
 		client:     c,
-		Auth:       services.NewAuthService(c),
+		Auth:       auth,
+		Session:    services.NewSessionManager(c, auth),
 		Account:    services.NewAccountService(c),
 		Contract:   services.NewContractService(c),
 		Order:      services.NewOrderService(c),